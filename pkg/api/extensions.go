@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/containers/kubernetes-mcp-server/pkg/api"
+	internalk8s "github.com/containers/kubernetes-mcp-server/pkg/kubernetes"
 )
 
 // ResourceProvider is an optional interface that toolsets can implement to expose MCP resources.
@@ -15,3 +16,43 @@ type ResourceProvider interface {
 	// This method is called during server initialization if the toolset implements this interface.
 	RegisterResources(registerFunc func(uri, name, mimeType string, handler func(context.Context) (string, error)) error) error
 }
+
+// ToolsetConfig is the typed configuration of a ConfigurableToolset, decoded from the
+// [toolset_configs.<name>] section of the config file.
+type ToolsetConfig interface {
+	// Validate checks the configuration for errors.
+	Validate() error
+}
+
+// ConfigurableToolset is an optional interface that toolsets can implement to receive their own
+// configuration section. The tools are built with the configuration instead of calling GetTools.
+type ConfigurableToolset interface {
+	api.Toolset
+	// DefaultConfig returns a new configuration initialized with the defaults, the config file section
+	// is decoded into it so it must be a pointer to a struct with toml tags.
+	DefaultConfig() ToolsetConfig
+	// GetConfiguredTools returns the tools of the toolset built with the validated configuration.
+	GetConfiguredTools(o internalk8s.Openshift, cfg ToolsetConfig) []api.ServerTool
+}
+
+// Permission is a Kubernetes API permission required by tools of a toolset.
+type Permission struct {
+	// Verb is the API verb (e.g. get, list, create, delete).
+	Verb string
+	// Group is the API group of the resource, empty for the core group.
+	Group string
+	// Resource is the resource (e.g. pods).
+	Resource string
+	// Subresource is the subresource (e.g. log), if any.
+	Subresource string
+	// Tools are the tools requiring the permission, it's only needed if any of them is enabled.
+	Tools []string
+}
+
+// PermissionsProvider is an optional interface that toolsets can implement to declare the Kubernetes API
+// permissions required by their tools, checked with SelfSubjectAccessReviews by the doctor command.
+type PermissionsProvider interface {
+	api.Toolset
+	// RequiredPermissions returns the permissions required by the tools of the toolset.
+	RequiredPermissions() []Permission
+}
@@ -0,0 +1,244 @@
+// Package auth provides the static API key and mTLS client certificate authentication
+// for the HTTP transport, an alternative to OAuth for internal deployments.
+//
+// The authenticated Principal and the address of the client are propagated to the MCP tool handlers through
+// the HTTP request context, whose values the MCP SDK passes on to the handlers of the sessions it connects.
+package auth
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	k8sapi "github.com/containers/kubernetes-mcp-server/pkg/api"
+	"k8s.io/utils/ptr"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+)
+
+// contextKey is the type of the keys of the request information in the request context.
+type contextKey string
+
+// Keys of the request information in the request context.
+const (
+	principalKey     contextKey = "principal"
+	remoteAddressKey contextKey = "remote_address"
+)
+
+// Authentication methods of a Principal.
+const (
+	MethodAPIKey = "api-key"
+	MethodMTLS   = "mtls"
+)
+
+// Principal is a client authenticated with an API key or a client certificate.
+type Principal struct {
+	// Name identifies the API key or the certificate subject.
+	Name string `json:"name"`
+	// Method is the authentication method (api-key or mtls).
+	Method string `json:"method"`
+	// User and Groups are the identity of the principal, impersonated if impersonation is enabled.
+	User   string   `json:"user"`
+	Groups []string `json:"groups,omitempty"`
+	// Scopes restrict the tools the principal may call, all tools if empty.
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+func (p *Principal) String() string {
+	return fmt.Sprintf("%s %s (user: %s)", p.Method, p.Name, p.User)
+}
+
+// WithPrincipal returns the request with the principal in its context.
+func WithPrincipal(r *http.Request, p *Principal) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), principalKey, p))
+}
+
+// WithRemoteAddress returns the request with the network address of the client in its context, identifying
+// the unauthenticated clients (e.g. for the rate limits).
+func WithRemoteAddress(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), remoteAddressKey, r.RemoteAddr))
+}
+
+// PrincipalFromContext returns the principal of the request context, or nil if none.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey).(*Principal)
+	return principal
+}
+
+// RemoteAddressFromContext returns the network address of the client of the request context, or an empty
+// string if none.
+func RemoteAddressFromContext(ctx context.Context) string {
+	address, _ := ctx.Value(remoteAddressKey).(string)
+	return address
+}
+
+// ToolScope returns the scope required to call the tool.
+func ToolScope(tool k8sapi.ServerTool) string {
+	switch {
+	case ptr.Deref(tool.Tool.Annotations.ReadOnlyHint, false):
+		return config.ScopeRead
+	case ptr.Deref(tool.Tool.Annotations.DestructiveHint, false):
+		return config.ScopeDestructive
+	default:
+		return config.ScopeWrite
+	}
+}
+
+// CanCall checks whether the principal scopes allow calling the tool.
+func (p *Principal) CanCall(tool k8sapi.ServerTool) error {
+	if len(p.Scopes) == 0 {
+		return nil
+	}
+	if scope := ToolScope(tool); !slices.Contains(p.Scopes, scope) {
+		return fmt.Errorf("tool %s requires the %q scope, which is not granted to %s %s", tool.Tool.Name, scope, p.Method, p.Name)
+	}
+	return nil
+}
+
+// Authenticator authenticates HTTP requests with the configured API keys and client certificates.
+type Authenticator struct {
+	apiKeys  []apiKey
+	mtls     *config.MTLSConfig
+	subjects []subject
+}
+
+type apiKey struct {
+	config.APIKeyConfig
+	hash []byte
+}
+
+type subject struct {
+	config.MTLSSubjectConfig
+	pattern *regexp.Regexp
+}
+
+// NewAuthenticator creates the authenticator for the configuration, or nil if neither API keys nor mTLS are configured.
+func NewAuthenticator(cfg config.AuthenticationConfig) (*Authenticator, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	a := &Authenticator{}
+	for _, key := range cfg.APIKeys {
+		hash, err := hex.DecodeString(strings.TrimPrefix(key.Hash, "sha256:"))
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("invalid hash for authentication api key %q", key.Name)
+		}
+		a.apiKeys = append(a.apiKeys, apiKey{APIKeyConfig: key, hash: hash})
+	}
+	if cfg.MTLS.Enabled {
+		a.mtls = &cfg.MTLS
+		for _, s := range cfg.MTLS.Subjects {
+			pattern, err := regexp.Compile(s.Subject)
+			if err != nil {
+				return nil, fmt.Errorf("invalid authentication mtls subject %q: %w", s.Subject, err)
+			}
+			a.subjects = append(a.subjects, subject{MTLSSubjectConfig: s, pattern: pattern})
+		}
+	}
+	return a, nil
+}
+
+// LoadClientCAs loads the PEM encoded CA bundle used to verify client certificates.
+func LoadClientCAs(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file %s: %w", path, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("failed to append client CA certificates from %s", path)
+	}
+	return pool, nil
+}
+
+// Authenticate returns the principal authenticated by the request API key or client certificate.
+//
+// A nil principal (and no error) is returned if the request carries neither a known API key nor a
+// verified client certificate, the request may still be authenticated by OAuth.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && len(a.apiKeys) > 0 {
+		hash := sha256.Sum256([]byte(token))
+		for _, key := range a.apiKeys {
+			if subtle.ConstantTimeCompare(hash[:], key.hash) == 1 {
+				return &Principal{
+					Name:   key.Name,
+					Method: MethodAPIKey,
+					User:   cmp.Or(key.User, key.Name),
+					Groups: key.Groups,
+					Scopes: key.Scopes,
+				}, nil
+			}
+		}
+	}
+	if a.mtls != nil && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		return a.authenticateCertificate(r.TLS.VerifiedChains[0][0])
+	}
+	return nil, nil
+}
+
+func (a *Authenticator) authenticateCertificate(cert *x509.Certificate) (*Principal, error) {
+	principal := &Principal{
+		Name:   cert.Subject.String(),
+		Method: MethodMTLS,
+		User:   certificateUser(cert, a.mtls.UserField),
+		Groups: certificateGroups(cert, a.mtls.GroupsField),
+		Scopes: a.mtls.Scopes,
+	}
+	if len(a.subjects) > 0 {
+		index := slices.IndexFunc(a.subjects, func(s subject) bool { return s.pattern.MatchString(principal.Name) })
+		if index < 0 {
+			return nil, fmt.Errorf("client certificate subject %q is not allowed", principal.Name)
+		}
+		s := a.subjects[index]
+		principal.User = cmp.Or(s.User, principal.User)
+		if s.Groups != nil {
+			principal.Groups = s.Groups
+		}
+		if s.Scopes != nil {
+			principal.Scopes = s.Scopes
+		}
+	}
+	if principal.User == "" {
+		return nil, fmt.Errorf("client certificate %q has no %s to map to a user", principal.Name, a.mtls.UserField)
+	}
+	return principal, nil
+}
+
+func certificateUser(cert *x509.Certificate, field string) string {
+	var values []string
+	switch field {
+	case "cn":
+		values = []string{cert.Subject.CommonName}
+	case "email":
+		values = cert.EmailAddresses
+	case "uri":
+		for _, uri := range cert.URIs {
+			values = append(values, uri.String())
+		}
+	case "dns":
+		values = cert.DNSNames
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func certificateGroups(cert *x509.Certificate, field string) []string {
+	switch field {
+	case "o":
+		return cert.Subject.Organization
+	case "ou":
+		return cert.Subject.OrganizationalUnit
+	}
+	return nil
+}
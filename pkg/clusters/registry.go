@@ -0,0 +1,313 @@
+// Package clusters provides the cluster registry, a cluster provider strategy whose clusters are defined by the
+// config file, by directories of kubeconfig files and by Secrets of a management cluster (Cluster API or Argo CD
+// style) instead of the contexts of a single kubeconfig, with labels per cluster.
+package clusters
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/containers/kubernetes-mcp-server/pkg/config"
+	internalk8s "github.com/containers/kubernetes-mcp-server/pkg/kubernetes"
+	authenticationv1api "k8s.io/api/authentication/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/klog/v2"
+
+	localconfig "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+)
+
+// TargetParameterName is the parameter of the tools selecting the cluster of the registry.
+const TargetParameterName = "cluster"
+
+func init() {
+	internalk8s.RegisterProvider(localconfig.ClusterProviderRegistry, newProvider)
+}
+
+// Registry is the cluster provider of the registry strategy. Its clusters are loaded once, the sources are
+// then reloaded every refresh interval and the server is notified (through WatchTargets) when the clusters
+// changed, replacing the registry with a new one.
+type Registry struct {
+	staticConfig    *config.StaticConfig
+	refreshInterval time.Duration
+	sources         []Source
+	clusters        map[string]*Cluster
+	names           []string
+	defaultCluster  string
+	fingerprint     string
+
+	mu       sync.Mutex
+	managers map[string]*internalk8s.Manager
+	closed   bool
+	stop     chan struct{}
+}
+
+var _ internalk8s.Provider = &Registry{}
+
+func newProvider(staticConfig *config.StaticConfig) (internalk8s.Provider, error) {
+	providerConfig, ok := staticConfig.GetProviderConfig(localconfig.ClusterProviderRegistry)
+	if !ok {
+		return nil, fmt.Errorf("the %s cluster provider strategy requires the [cluster_provider_configs.%s] section",
+			localconfig.ClusterProviderRegistry, localconfig.ClusterProviderRegistry)
+	}
+	cfg := providerConfig.(*localconfig.ClusterRegistryConfig)
+	sources := []Source{ConfigSource(cfg.Clusters)}
+	for _, dir := range cfg.KubeconfigDirs {
+		sources = append(sources, KubeconfigDirSource(dir))
+	}
+	for _, secrets := range cfg.Secrets {
+		restConfig, err := managementClusterConfig(staticConfig, secrets)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the management cluster configuration: %w", err)
+		}
+		client, err := kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the management cluster client: %w", err)
+		}
+		sources = append(sources, SecretsSource(client, secrets))
+	}
+	return NewRegistry(staticConfig, cfg, sources...)
+}
+
+// managementClusterConfig returns the configuration of the management cluster holding the Secrets: the kubeconfig
+// of the secrets configuration, or the kubeconfig (or in-cluster configuration) of the server.
+func managementClusterConfig(staticConfig *config.StaticConfig, secrets localconfig.ClusterSecretsConfig) (*rest.Config, error) {
+	if secrets.Kubeconfig == "" && internalk8s.IsInCluster(staticConfig) {
+		return internalk8s.InClusterConfig()
+	}
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = secrets.Kubeconfig
+	if loadingRules.ExplicitPath == "" {
+		loadingRules.ExplicitPath = staticConfig.KubeConfig
+	}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules,
+		&clientcmd.ConfigOverrides{CurrentContext: secrets.Context}).ClientConfig()
+}
+
+// NewRegistry creates the registry of the clusters of the sources, a cluster defined by several sources is
+// taken from the first one. An error is returned if a source can't be read.
+func NewRegistry(staticConfig *config.StaticConfig, cfg *localconfig.ClusterRegistryConfig, sources ...Source) (*Registry, error) {
+	refreshInterval, err := cfg.RefreshIntervalDuration()
+	if err != nil {
+		return nil, err
+	}
+	r := &Registry{
+		staticConfig:    staticConfig,
+		refreshInterval: refreshInterval,
+		sources:         sources,
+		managers:        make(map[string]*internalk8s.Manager),
+		stop:            make(chan struct{}),
+	}
+	if r.clusters, err = r.load(context.Background()); err != nil {
+		return nil, err
+	}
+	if len(r.clusters) == 0 {
+		return nil, errors.New("the cluster registry has no clusters")
+	}
+	r.names = slices.Sorted(maps.Keys(r.clusters))
+	r.fingerprint = fingerprint(r.clusters)
+	r.defaultCluster = cfg.DefaultCluster
+	if r.defaultCluster == "" {
+		r.defaultCluster = r.names[0]
+	} else if _, ok := r.clusters[r.defaultCluster]; !ok {
+		return nil, fmt.Errorf("the default cluster %q is not in the cluster registry", r.defaultCluster)
+	}
+	return r, nil
+}
+
+// load returns the clusters of the sources by name.
+func (r *Registry) load(ctx context.Context) (map[string]*Cluster, error) {
+	clusters := make(map[string]*Cluster)
+	for _, source := range r.sources {
+		sourceClusters, err := source.Clusters(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, cluster := range sourceClusters {
+			if err := localconfig.ValidateClusterLabels(cluster.Labels); err != nil {
+				klog.Warningf("Skipping the cluster %s of the %s: %v", cluster.Name, cluster.Source, err)
+				continue
+			}
+			if existing, ok := clusters[cluster.Name]; ok {
+				klog.Warningf("Skipping the cluster %s of the %s, already defined by the %s", cluster.Name, cluster.Source, existing.Source)
+				continue
+			}
+			clusters[cluster.Name] = cluster
+		}
+	}
+	return clusters, nil
+}
+
+// fingerprint returns a digest of the clusters, their labels and credentials, changing if any of them changed.
+func fingerprint(clusters map[string]*Cluster) string {
+	hash := sha256.New()
+	for _, name := range slices.Sorted(maps.Keys(clusters)) {
+		cluster := clusters[name]
+		_, _ = fmt.Fprintf(hash, "%s\x00%s\x00", name, cluster.Source)
+		for _, key := range slices.Sorted(maps.Keys(cluster.Labels)) {
+			_, _ = fmt.Fprintf(hash, "%s=%s\x00", key, cluster.Labels[key])
+		}
+		kubeconfig, err := clientcmd.Write(*cluster.Kubeconfig)
+		if err != nil {
+			// never equal, the registry is reloaded
+			kubeconfig = []byte(time.Now().String())
+		}
+		_, _ = hash.Write(kubeconfig)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Clusters returns the clusters of the registry sorted by name.
+func (r *Registry) Clusters() []*Cluster {
+	clusters := make([]*Cluster, 0, len(r.names))
+	for _, name := range r.names {
+		clusters = append(clusters, r.clusters[name])
+	}
+	return clusters
+}
+
+// Kubeconfig returns a copy of the kubeconfig of the cluster (the default one if empty) with the server
+// credentials, its current context selecting the cluster.
+func (r *Registry) Kubeconfig(target string) (*clientcmdapi.Config, error) {
+	cluster, err := r.cluster(target)
+	if err != nil {
+		return nil, err
+	}
+	return cluster.Kubeconfig.DeepCopy(), nil
+}
+
+func (r *Registry) cluster(target string) (*Cluster, error) {
+	if target == "" {
+		target = r.defaultCluster
+	}
+	cluster, ok := r.clusters[target]
+	if !ok {
+		return nil, fmt.Errorf("unknown cluster %q", target)
+	}
+	return cluster, nil
+}
+
+// manager returns the manager of the cluster, created on first use from its kubeconfig.
+func (r *Registry) manager(target string) (*internalk8s.Manager, error) {
+	cluster, err := r.cluster(target)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, errors.New("the cluster registry is closed")
+	}
+	if m, ok := r.managers[cluster.Name]; ok {
+		return m, nil
+	}
+	file, err := os.CreateTemp("", "extendable-k8s-mcp-cluster-*.kubeconfig")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the kubeconfig of the cluster %q: %w", cluster.Name, err)
+	}
+	_ = file.Close()
+	// The manager loads the kubeconfig eagerly, the file is no longer needed once it's created
+	defer func() { _ = os.Remove(file.Name()) }()
+	if err := clientcmd.WriteToFile(*cluster.Kubeconfig, file.Name()); err != nil {
+		return nil, fmt.Errorf("failed to write the kubeconfig of the cluster %q: %w", cluster.Name, err)
+	}
+	staticConfig := *r.staticConfig
+	staticConfig.KubeConfig = file.Name()
+	m, err := internalk8s.NewKubeconfigManager(&staticConfig, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the client of the cluster %q: %w", cluster.Name, err)
+	}
+	r.managers[cluster.Name] = m
+	return m, nil
+}
+
+func (r *Registry) IsOpenShift(ctx context.Context) bool {
+	m, err := r.manager("")
+	if err != nil {
+		return false
+	}
+	return m.IsOpenShift(ctx)
+}
+
+func (r *Registry) VerifyToken(ctx context.Context, cluster, token, audience string) (*authenticationv1api.UserInfo, []string, error) {
+	m, err := r.manager(cluster)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m.VerifyToken(ctx, token, audience)
+}
+
+func (r *Registry) GetTargets(context.Context) ([]string, error) {
+	return slices.Clone(r.names), nil
+}
+
+func (r *Registry) GetDerivedKubernetes(ctx context.Context, cluster string) (*internalk8s.Kubernetes, error) {
+	m, err := r.manager(cluster)
+	if err != nil {
+		return nil, err
+	}
+	return m.Derived(ctx)
+}
+
+func (r *Registry) GetDefaultTarget() string {
+	return r.defaultCluster
+}
+
+func (r *Registry) GetTargetParameterName() string {
+	return TargetParameterName
+}
+
+// WatchTargets reloads the sources every refresh interval, calling onTargetsChanged once the clusters changed.
+// The sources that can't be read are retried on the next interval.
+func (r *Registry) WatchTargets(onTargetsChanged func() error) {
+	if r.refreshInterval == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(r.refreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+			}
+			clusters, err := r.load(context.Background())
+			if err != nil {
+				klog.Warningf("Failed to reload the cluster registry: %v", err)
+				continue
+			}
+			if fingerprint(clusters) == r.fingerprint {
+				continue
+			}
+			klog.V(1).Infof("The cluster registry changed, reloading the clusters")
+			if err := onTargetsChanged(); err != nil {
+				klog.Errorf("Failed to reload the cluster registry: %v", err)
+			}
+		}
+	}()
+}
+
+// Close stops watching the sources and closes the clients of the clusters.
+func (r *Registry) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	r.closed = true
+	close(r.stop)
+	for _, m := range r.managers {
+		m.Close()
+	}
+}
@@ -0,0 +1,286 @@
+package clusters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/klog/v2"
+
+	localconfig "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+)
+
+// Cluster is a cluster of the registry.
+type Cluster struct {
+	// Name is the name of the cluster, the value of the cluster argument of the tools.
+	Name string
+	// Labels are the labels of the cluster, matched by the cluster selectors.
+	Labels map[string]string
+	// Source describes where the cluster is defined (e.g. the kubeconfig file or the Secret).
+	Source string
+	// Kubeconfig holds the server credentials of the cluster, its current context selects the cluster.
+	Kubeconfig *clientcmdapi.Config
+}
+
+// Source provides clusters to the registry.
+type Source interface {
+	// Clusters returns the clusters of the source. The invalid definitions are skipped with a warning,
+	// an error is returned if the source can't be read.
+	Clusters(ctx context.Context) ([]*Cluster, error)
+}
+
+// Keys of the supported cluster Secrets.
+const (
+	// capiKubeconfigKey holds the kubeconfig of the Cluster API kubeconfig Secrets (<cluster>-kubeconfig).
+	capiKubeconfigKey = "value"
+	// capiClusterNameLabel is the label of the Cluster API Secrets naming their cluster.
+	capiClusterNameLabel = "cluster.x-k8s.io/cluster-name"
+	// kubeconfigKey holds the kubeconfig of the generic cluster Secrets.
+	kubeconfigKey = "kubeconfig"
+)
+
+// ConfigSource returns the source of the clusters defined in the config file.
+func ConfigSource(clusters []localconfig.ClusterConfig) Source {
+	return configSource(clusters)
+}
+
+type configSource []localconfig.ClusterConfig
+
+func (s configSource) Clusters(context.Context) ([]*Cluster, error) {
+	clusters := make([]*Cluster, 0, len(s))
+	for _, cfg := range s {
+		kubeconfig, err := clusterKubeconfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %q: %w", cfg.Name, err)
+		}
+		clusters = append(clusters, &Cluster{Name: cfg.Name, Labels: cfg.Labels, Source: "config", Kubeconfig: kubeconfig})
+	}
+	return clusters, nil
+}
+
+// clusterKubeconfig returns the kubeconfig of a cluster defined in the config file.
+func clusterKubeconfig(cfg localconfig.ClusterConfig) (*clientcmdapi.Config, error) {
+	if cfg.Kubeconfig != "" {
+		return loadKubeconfigFile(cfg.Kubeconfig, cfg.Context)
+	}
+	kubeconfig := clientcmdapi.NewConfig()
+	kubeconfig.Clusters[cfg.Name] = &clientcmdapi.Cluster{
+		Server:                cfg.Server,
+		CertificateAuthority:  cfg.CertificateAuthority,
+		InsecureSkipTLSVerify: cfg.InsecureSkipTLSVerify,
+	}
+	kubeconfig.AuthInfos[cfg.Name] = &clientcmdapi.AuthInfo{TokenFile: cfg.TokenFile}
+	kubeconfig.Contexts[cfg.Name] = &clientcmdapi.Context{Cluster: cfg.Name, AuthInfo: cfg.Name}
+	kubeconfig.CurrentContext = cfg.Name
+	return kubeconfig, nil
+}
+
+// loadKubeconfigFile loads the kubeconfig file reduced to the context (the current one if empty),
+// with its relative paths resolved.
+func loadKubeconfigFile(path, kubeconfigContext string) (*clientcmdapi.Config, error) {
+	kubeconfig, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := clientcmd.ResolveLocalPaths(kubeconfig); err != nil {
+		return nil, err
+	}
+	return minify(kubeconfig, kubeconfigContext)
+}
+
+// minify reduces the kubeconfig to the context, the current one if empty.
+func minify(kubeconfig *clientcmdapi.Config, kubeconfigContext string) (*clientcmdapi.Config, error) {
+	if kubeconfigContext != "" {
+		kubeconfig.CurrentContext = kubeconfigContext
+	}
+	if _, ok := kubeconfig.Contexts[kubeconfig.CurrentContext]; !ok {
+		return nil, fmt.Errorf("context %q not found in the kubeconfig", kubeconfig.CurrentContext)
+	}
+	if err := clientcmdapi.MinifyConfig(kubeconfig); err != nil {
+		return nil, err
+	}
+	return kubeconfig, nil
+}
+
+// KubeconfigDirSource returns the source of the clusters of a directory of kubeconfig files.
+func KubeconfigDirSource(dir localconfig.ClusterKubeconfigDirConfig) Source {
+	return kubeconfigDirSource(dir)
+}
+
+type kubeconfigDirSource localconfig.ClusterKubeconfigDirConfig
+
+func (s kubeconfigDirSource) Clusters(context.Context) ([]*Cluster, error) {
+	entries, err := os.ReadDir(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the kubeconfig directory: %w", err)
+	}
+	clusters := make([]*Cluster, 0, len(entries))
+	for _, entry := range entries {
+		// hidden files include the ..data links of the mounted Secrets and ConfigMaps
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(s.Path, entry.Name())
+		if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if err := localconfig.ValidateClusterName(name); err != nil {
+			klog.Warningf("Skipping the kubeconfig file %s: %v", path, err)
+			continue
+		}
+		kubeconfig, err := loadKubeconfigFile(path, "")
+		if err != nil {
+			klog.Warningf("Skipping the kubeconfig file %s: %v", path, err)
+			continue
+		}
+		clusters = append(clusters, &Cluster{Name: name, Labels: s.Labels, Source: "kubeconfig file " + path, Kubeconfig: kubeconfig})
+	}
+	return clusters, nil
+}
+
+// SecretsSource returns the source of the clusters of the Secrets of a management cluster.
+func SecretsSource(client kubernetes.Interface, secrets localconfig.ClusterSecretsConfig) Source {
+	return &secretsSource{client: client, config: secrets}
+}
+
+type secretsSource struct {
+	client kubernetes.Interface
+	config localconfig.ClusterSecretsConfig
+}
+
+func (s *secretsSource) Clusters(ctx context.Context) ([]*Cluster, error) {
+	list, err := s.client.CoreV1().Secrets(s.config.Namespace).List(ctx, metav1.ListOptions{LabelSelector: s.config.LabelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the cluster Secrets: %w", err)
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].Namespace+"/"+list.Items[i].Name < list.Items[j].Namespace+"/"+list.Items[j].Name
+	})
+	clusters := make([]*Cluster, 0, len(list.Items))
+	for i := range list.Items {
+		secret := &list.Items[i]
+		cluster, err := clusterFromSecret(secret)
+		if err == nil {
+			err = localconfig.ValidateClusterName(cluster.Name)
+		}
+		if err != nil {
+			klog.Warningf("Skipping the Secret %s/%s: %v", secret.Namespace, secret.Name, err)
+			continue
+		}
+		cluster.Labels = make(map[string]string, len(secret.Labels)+len(s.config.Labels))
+		for key, value := range secret.Labels {
+			cluster.Labels[key] = value
+		}
+		for key, value := range s.config.Labels {
+			cluster.Labels[key] = value
+		}
+		cluster.Source = fmt.Sprintf("Secret %s/%s", secret.Namespace, secret.Name)
+		clusters = append(clusters, cluster)
+	}
+	return clusters, nil
+}
+
+// clusterFromSecret returns the cluster of an Argo CD cluster Secret, of a Cluster API kubeconfig Secret
+// or of a Secret with a kubeconfig key (named after the Secret).
+func clusterFromSecret(secret *corev1.Secret) (*Cluster, error) {
+	switch {
+	case len(secret.Data["server"]) > 0 && len(secret.Data["config"]) > 0:
+		return argoCDCluster(secret)
+	case len(secret.Data[capiKubeconfigKey]) > 0:
+		name := secret.Labels[capiClusterNameLabel]
+		if name == "" {
+			name = strings.TrimSuffix(secret.Name, "-kubeconfig")
+		}
+		kubeconfig, err := loadKubeconfig(secret.Data[capiKubeconfigKey])
+		return &Cluster{Name: name, Kubeconfig: kubeconfig}, err
+	case len(secret.Data[kubeconfigKey]) > 0:
+		kubeconfig, err := loadKubeconfig(secret.Data[kubeconfigKey])
+		return &Cluster{Name: secret.Name, Kubeconfig: kubeconfig}, err
+	default:
+		return nil, fmt.Errorf("no server and config (Argo CD), %s (Cluster API) or %s key", capiKubeconfigKey, kubeconfigKey)
+	}
+}
+
+func loadKubeconfig(data []byte) (*clientcmdapi.Config, error) {
+	kubeconfig, err := clientcmd.Load(data)
+	if err != nil {
+		return nil, err
+	}
+	return minify(kubeconfig, "")
+}
+
+// argoCDConfig is the config key of the Argo CD cluster Secrets.
+type argoCDConfig struct {
+	Username        string `json:"username,omitempty"`
+	Password        string `json:"password,omitempty"`
+	BearerToken     string `json:"bearerToken,omitempty"`
+	TLSClientConfig struct {
+		Insecure   bool   `json:"insecure,omitempty"`
+		ServerName string `json:"serverName,omitempty"`
+		CAData     []byte `json:"caData,omitempty"`
+		CertData   []byte `json:"certData,omitempty"`
+		KeyData    []byte `json:"keyData,omitempty"`
+	} `json:"tlsClientConfig"`
+	ExecProviderConfig *struct {
+		Command     string            `json:"command"`
+		Args        []string          `json:"args,omitempty"`
+		Env         map[string]string `json:"env,omitempty"`
+		APIVersion  string            `json:"apiVersion,omitempty"`
+		InstallHint string            `json:"installHint,omitempty"`
+	} `json:"execProviderConfig,omitempty"`
+}
+
+func argoCDCluster(secret *corev1.Secret) (*Cluster, error) {
+	var cfg argoCDConfig
+	if err := json.Unmarshal(secret.Data["config"], &cfg); err != nil {
+		return nil, fmt.Errorf("invalid Argo CD cluster config: %w", err)
+	}
+	name := string(secret.Data["name"])
+	if name == "" {
+		name = secret.Name
+	}
+	authInfo := &clientcmdapi.AuthInfo{
+		Token:                 cfg.BearerToken,
+		Username:              cfg.Username,
+		Password:              cfg.Password,
+		ClientCertificateData: cfg.TLSClientConfig.CertData,
+		ClientKeyData:         cfg.TLSClientConfig.KeyData,
+	}
+	if exec := cfg.ExecProviderConfig; exec != nil {
+		authInfo.Exec = &clientcmdapi.ExecConfig{
+			Command:         exec.Command,
+			Args:            exec.Args,
+			APIVersion:      exec.APIVersion,
+			InstallHint:     exec.InstallHint,
+			InteractiveMode: clientcmdapi.NeverExecInteractiveMode,
+		}
+		for key, value := range exec.Env {
+			authInfo.Exec.Env = append(authInfo.Exec.Env, clientcmdapi.ExecEnvVar{Name: key, Value: value})
+		}
+		sort.Slice(authInfo.Exec.Env, func(i, j int) bool { return authInfo.Exec.Env[i].Name < authInfo.Exec.Env[j].Name })
+	}
+	if authInfo.Token == "" && authInfo.Username == "" && len(authInfo.ClientCertificateData) == 0 && authInfo.Exec == nil {
+		return nil, fmt.Errorf("unsupported Argo CD cluster credentials, a bearer token, a client certificate, a username or an exec provider is expected")
+	}
+	kubeconfig := clientcmdapi.NewConfig()
+	kubeconfig.Clusters[name] = &clientcmdapi.Cluster{
+		Server:                   string(secret.Data["server"]),
+		TLSServerName:            cfg.TLSClientConfig.ServerName,
+		CertificateAuthorityData: cfg.TLSClientConfig.CAData,
+		InsecureSkipTLSVerify:    cfg.TLSClientConfig.Insecure,
+	}
+	kubeconfig.AuthInfos[name] = authInfo
+	kubeconfig.Contexts[name] = &clientcmdapi.Context{Cluster: name, AuthInfo: name}
+	kubeconfig.CurrentContext = name
+	return &Cluster{Name: name, Kubeconfig: kubeconfig}, nil
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var callExamples = templates.Examples(`
+# list the pods of a namespace
+extendable-k8s-mcp call pods_list_in_namespace --arg namespace=default
+
+# arguments are converted to the types of the tool input schema
+extendable-k8s-mcp call pods_log --arg name=my-pod --arg tail=10 --arg previous=true
+
+# pass the arguments as a JSON object, or read them from the standard input with --json -
+extendable-k8s-mcp call resources_get --json '{"apiVersion":"v1","kind":"ConfigMap","name":"cm","namespace":"default"}'
+echo '{"namespace":"default"}' | extendable-k8s-mcp call events_list --json -
+`)
+
+func newCallCommand(o *ExtendableMCPServerOptions) *cobra.Command {
+	var arguments []string
+	var jsonArguments string
+	cmd := &cobra.Command{
+		Use:   "call <tool>",
+		Short: "Call a tool in-process against the configured cluster and print the result",
+		Long: "Build the server from the configuration (config files, drop-in directory, profile, environment variables and flags) " +
+			"and call the tool through an in-process MCP session, so the same gating applies as for a real session " +
+			"(read-only, disabled tools, namespaces, redaction, rate limits).",
+		Example:      callExamples,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			ctx := c.Context()
+			server, session, err := o.connectInProcess(ctx, c)
+			if err != nil {
+				return err
+			}
+			defer server.Close()
+			defer func() { _ = session.Close() }()
+
+			var toolArguments map[string]any
+			if jsonArguments != "" {
+				toolArguments, err = o.parseJSONArguments(jsonArguments)
+			} else {
+				toolArguments, err = parseArguments(ctx, session, args[0], arguments)
+			}
+			if err != nil {
+				return err
+			}
+			result, err := session.CallTool(ctx, &mcp.CallToolParams{Name: args[0], Arguments: toolArguments})
+			if err != nil {
+				return fmt.Errorf("failed to call tool %s: %w", args[0], err)
+			}
+			text := toolResultText(result)
+			if result.IsError {
+				return fmt.Errorf("tool %s failed: %s", args[0], text)
+			}
+			_, err = fmt.Fprintln(o.Out, text)
+			return err
+		},
+	}
+	cmd.Flags().StringArrayVar(&arguments, "arg", arguments,
+		"Tool argument as key=value, may be repeated. The values are converted to the type of the argument in the tool input schema.")
+	cmd.Flags().StringVar(&jsonArguments, "json", jsonArguments, "Tool arguments as a JSON object, - to read them from the standard input")
+	cmd.MarkFlagsMutuallyExclusive("arg", "json")
+	return cmd
+}
+
+// parseJSONArguments parses the JSON object of the tool arguments, read from the standard input for -.
+func (m *ExtendableMCPServerOptions) parseJSONArguments(value string) (map[string]any, error) {
+	data := []byte(value)
+	if value == "-" {
+		var err error
+		if data, err = io.ReadAll(m.In); err != nil {
+			return nil, fmt.Errorf("failed to read the tool arguments: %w", err)
+		}
+	}
+	arguments := make(map[string]any)
+	if err := json.Unmarshal(data, &arguments); err != nil {
+		return nil, fmt.Errorf("invalid --json tool arguments, a JSON object is expected: %w", err)
+	}
+	return arguments, nil
+}
+
+// parseArguments parses the key=value tool arguments, converting the values to the types of the
+// tool input schema (strings are used for the arguments the schema doesn't describe).
+func parseArguments(ctx context.Context, session *mcp.ClientSession, toolName string, values []string) (map[string]any, error) {
+	arguments := make(map[string]any, len(values))
+	if len(values) == 0 {
+		return arguments, nil
+	}
+	var tool *mcp.Tool
+	for t, err := range session.Tools(ctx, nil) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the tools: %w", err)
+		}
+		if t.Name == toolName {
+			tool = t
+			break
+		}
+	}
+	types := make(map[string]string)
+	if tool != nil {
+		for _, parameter := range toolParameters(tool) {
+			types[parameter.name] = parameter.typ
+		}
+	}
+	for _, value := range values {
+		key, raw, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --arg %q, key=value is expected", value)
+		}
+		converted, err := convertArgument(types[key], raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --arg %s: %w", key, err)
+		}
+		arguments[key] = converted
+	}
+	return arguments, nil
+}
+
+// convertArgument converts the command line value to the JSON Schema type.
+func convertArgument(typ, value string) (any, error) {
+	switch typ {
+	case "boolean":
+		return strconv.ParseBool(value)
+	case "integer":
+		return strconv.ParseInt(value, 10, 64)
+	case "number":
+		return strconv.ParseFloat(value, 64)
+	case "object", "array":
+		var converted any
+		if err := json.Unmarshal([]byte(value), &converted); err != nil {
+			return nil, fmt.Errorf("a JSON %s is expected: %w", typ, err)
+		}
+		return converted, nil
+	default:
+		return value, nil
+	}
+}
+
+// toolResultText returns the text contents of the tool call result, and the structured content if any.
+func toolResultText(result *mcp.CallToolResult) string {
+	texts := make([]string, 0, len(result.Content))
+	for _, content := range result.Content {
+		if text, ok := content.(*mcp.TextContent); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	if result.StructuredContent != nil {
+		if data, err := json.MarshalIndent(result.StructuredContent, "", "  "); err == nil {
+			texts = append(texts, string(data))
+		}
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	localconfig "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+)
+
+var (
+	configExamples = templates.Examples(`
+# check the merged config files, environment variables and flags for errors
+extendable-k8s-mcp config validate --config base.toml --config overlay.toml
+
+# print the effective configuration of the prod-readonly profile
+extendable-k8s-mcp config print --config config.toml --profile prod-readonly
+
+# write the JSON Schema of the config file for editors and linters
+extendable-k8s-mcp config schema > config.schema.json
+`)
+
+	bareKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// redactedValue replaces the values of the secret settings in the printed configuration.
+const redactedValue = "<redacted>"
+
+func newConfigCommand(o *ExtendableMCPServerOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "config",
+		Short:   "Validate and print the configuration and its schema",
+		Long:    "Load the configuration the same way the server does (config files, drop-in directory, profile, environment variables and flags).",
+		Example: configExamples,
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:          "validate",
+		Short:        "Check the configuration and report all the errors found",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, _ []string) error {
+			if err := o.completeConfiguration(c); err != nil {
+				return fmt.Errorf("invalid configuration: %w", err)
+			}
+			if err := o.Validate(); err != nil {
+				return fmt.Errorf("invalid configuration:\n%s", formatErrors(err))
+			}
+			_, _ = fmt.Fprintln(o.Out, "Configuration is valid")
+			return nil
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:          "print",
+		Short:        "Print the effective configuration and where each value comes from",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, _ []string) error {
+			if err := o.completeConfiguration(c); err != nil {
+				return fmt.Errorf("invalid configuration: %w", err)
+			}
+			return o.printConfiguration(o.Out)
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:          "schema",
+		Short:        "Print the JSON Schema of the config file, including the toolset sections",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(*cobra.Command, []string) error {
+			schema, err := localconfig.Schema()
+			if err != nil {
+				return fmt.Errorf("failed to generate the configuration schema: %w", err)
+			}
+			_, err = fmt.Fprintln(o.Out, string(schema))
+			return err
+		},
+	})
+	return cmd
+}
+
+// formatErrors lists the joined errors, one per line.
+func formatErrors(err error) string {
+	var lines []string
+	for _, line := range strings.Split(err.Error(), "\n") {
+		if line != "" {
+			lines = append(lines, " - "+line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// configEntry is a setting of the effective configuration.
+type configEntry struct {
+	key   string
+	value reflect.Value
+}
+
+// printConfiguration prints the effective configuration as TOML, each setting with a comment describing
+// where its value comes from (default, config file, profile, environment variable or flag).
+func (m *ExtendableMCPServerOptions) printConfiguration(out io.Writer) error {
+	sources, err := m.configSources()
+	if err != nil {
+		return err
+	}
+	var entries []configEntry
+	flattenValue("", reflect.ValueOf(m.StaticConfig), &entries)
+	flattenValue("", reflect.ValueOf(m.ExtensionsConfig), &entries)
+
+	var buf bytes.Buffer
+	_, _ = fmt.Fprintln(&buf, "# Effective configuration, the comments show where each value comes from")
+	if m.ExtensionsConfig.Profile != "" {
+		_, _ = fmt.Fprintf(&buf, "# Profile: %s\n", m.ExtensionsConfig.Profile)
+	}
+	for _, entry := range entries {
+		value := formatValue(entry.value)
+		if isSecretKey(entry.key) && entry.value.Kind() == reflect.String && entry.value.String() != "" {
+			value = strconv.Quote(redactedValue)
+		}
+		_, _ = fmt.Fprintf(&buf, "%s = %s # %s\n", entry.key, value, sourceOf(sources, entry.key))
+	}
+	_, err = out.Write(buf.Bytes())
+	return err
+}
+
+// configSources returns the source of each config key set by the config files, the profile or the flags.
+func (m *ExtendableMCPServerOptions) configSources() (map[string]string, error) {
+	sources := make(map[string]string)
+	files, err := m.configFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		document := make(map[string]any)
+		if _, err := toml.Decode(string(data), &document); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", file, err)
+		}
+		var entries []configEntry
+		flattenValue("", reflect.ValueOf(document), &entries)
+		for _, entry := range entries {
+			sources[entry.key] = "file " + file
+		}
+	}
+	if profile, ok := m.ExtensionsConfig.Profiles[m.ExtensionsConfig.Profile]; ok {
+		var entries []configEntry
+		flattenValue("", reflect.ValueOf(profile), &entries)
+		for _, entry := range entries {
+			// empty profile settings keep the configured value
+			if entry.key != "path" && !entry.value.IsZero() {
+				sources[entry.key] = "profile " + m.ExtensionsConfig.Profile
+			}
+		}
+	}
+	for key, source := range m.flagSources {
+		sources[key] = source
+	}
+	return sources, nil
+}
+
+// sourceOf returns the source of the key, or of the closest table containing it.
+func sourceOf(sources map[string]string, key string) string {
+	for {
+		if source, ok := sources[key]; ok {
+			return source
+		}
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			return "default"
+		}
+		key = key[:i]
+	}
+}
+
+func isSecretKey(key string) bool {
+	return strings.Contains(strings.ToLower(key[strings.LastIndex(key, ".")+1:]), "secret")
+}
+
+// flattenValue appends the settings of the value with their dotted keys, tables (structs and maps)
+// are flattened while any other value (including arrays) is a single setting. Unset (nil) values are skipped.
+func flattenValue(prefix string, v reflect.Value, entries *[]configEntry) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.IsNil() {
+		return
+	}
+	if v.Type() == reflect.TypeOf(toml.Primitive{}) {
+		// undecoded sections (e.g. cluster_provider_configs) can't be printed
+		return
+	}
+	switch {
+	case v.Kind() == reflect.Struct:
+		for i := range v.NumField() {
+			if name := tomlName(v.Type().Field(i)); name != "" {
+				flattenValue(joinKey(prefix, name), v.Field(i), entries)
+			}
+		}
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return strings.Compare(a.String(), b.String()) })
+		for _, key := range keys {
+			flattenValue(joinKey(prefix, key.String()), v.MapIndex(key), entries)
+		}
+	default:
+		*entries = append(*entries, configEntry{key: prefix, value: v})
+	}
+}
+
+// tomlName returns the TOML key of the struct field, or an empty string if it's not serialized.
+func tomlName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+func joinKey(prefix, key string) string {
+	if !bareKeyRegexp.MatchString(key) {
+		key = strconv.Quote(key)
+	}
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// formatValue returns the TOML inline representation of the value.
+func formatValue(v reflect.Value) string {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return `""`
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		_ = encoder.Encode(v.String())
+		return strings.TrimSpace(buf.String())
+	case reflect.Float32, reflect.Float64:
+		formatted := strconv.FormatFloat(v.Float(), 'f', -1, 64)
+		if !strings.ContainsAny(formatted, ".eE") {
+			formatted += ".0"
+		}
+		return formatted
+	case reflect.Slice, reflect.Array:
+		items := make([]string, 0, v.Len())
+		for i := range v.Len() {
+			items = append(items, formatValue(v.Index(i)))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case reflect.Struct, reflect.Map:
+		var entries []configEntry
+		flattenValue("", v, &entries)
+		items := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			items = append(items, entry.key+" = "+formatValue(entry.value))
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
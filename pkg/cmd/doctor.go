@@ -0,0 +1,377 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	internalk8s "github.com/containers/kubernetes-mcp-server/pkg/kubernetes"
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/repo"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	localapi "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/api"
+	localmcp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/mcp"
+	localtoolsets "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/toolsets"
+)
+
+var doctorExamples = templates.Examples(`
+# check the environment of the server started with the same options
+extendable-k8s-mcp doctor --config config.toml --port 8080
+`)
+
+// doctorTimeout is the default timeout of each of the doctor checks reaching a remote server.
+const doctorTimeout = 10 * time.Second
+
+func newDoctorCommand(o *ExtendableMCPServerOptions) *cobra.Command {
+	timeout := doctorTimeout
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose the environment of the server",
+		Long: "Check the configuration, the kubeconfig, the connectivity, authentication and RBAC permissions for each " +
+			"cluster context, the Helm repositories, the OIDC discovery and the port or socket availability, using the same " +
+			"options as the server. The Kubernetes checks use the server credentials.",
+		Example:      doctorExamples,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, _ []string) error {
+			d := &doctor{options: o, out: o.Out, timeout: timeout}
+			d.run(c)
+			if d.failed > 0 {
+				return fmt.Errorf("%d checks failed", d.failed)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().DurationVar(&timeout, "timeout", timeout, "Timeout of each check reaching a remote server")
+	return cmd
+}
+
+// doctor runs the diagnostics and prints their results.
+type doctor struct {
+	options *ExtendableMCPServerOptions
+	out     io.Writer
+	timeout time.Duration
+	failed  int
+	warned  int
+}
+
+func (d *doctor) section(name string) {
+	_, _ = fmt.Fprintf(d.out, "%s\n", name)
+}
+
+func (d *doctor) ok(format string, args ...any) {
+	_, _ = fmt.Fprintf(d.out, "  [OK]   %s\n", fmt.Sprintf(format, args...))
+}
+
+func (d *doctor) warn(format string, args ...any) {
+	d.warned++
+	_, _ = fmt.Fprintf(d.out, "  [WARN] %s\n", fmt.Sprintf(format, args...))
+}
+
+func (d *doctor) fail(format string, args ...any) {
+	d.failed++
+	_, _ = fmt.Fprintf(d.out, "  [FAIL] %s\n", fmt.Sprintf(format, args...))
+}
+
+func (d *doctor) run(cmd *cobra.Command) {
+	m := d.options
+	d.section("Configuration")
+	if err := m.completeConfiguration(cmd); err != nil {
+		d.fail("failed to load the configuration: %v", err)
+		return
+	}
+	if err := m.Validate(); err != nil {
+		for _, line := range strings.Split(err.Error(), "\n") {
+			d.fail("%s", line)
+		}
+	} else {
+		d.ok("configuration is valid")
+	}
+	quietLogging()
+
+	ctx := cmd.Context()
+	d.checkClusters(ctx)
+	if slices.Contains(m.StaticConfig.Toolsets, "helm") {
+		d.checkHelm()
+	}
+	if m.StaticConfig.AuthorizationURL != "" {
+		d.checkOIDC(ctx)
+	}
+	if m.StaticConfig.Port != "" {
+		d.checkPort()
+	}
+	if m.ExtensionsConfig.Listen.Enabled() {
+		d.checkSocket()
+	}
+	_, _ = fmt.Fprintf(d.out, "\n%d failed, %d warnings\n", d.failed, d.warned)
+}
+
+// checkClusters checks the kubeconfig loading and, for each cluster context, the connectivity,
+// the authentication and the permissions required by the enabled tools.
+func (d *doctor) checkClusters(ctx context.Context) {
+	m := d.options
+	d.section("Kubeconfig")
+	// the checks use the server credentials, not the credentials of the OAuth users
+	staticConfig := *m.StaticConfig
+	staticConfig.RequireOAuth = false
+	provider, err := internalk8s.NewProvider(&staticConfig)
+	if err != nil {
+		d.fail("failed to load the kubeconfig: %v", err)
+		return
+	}
+	defer provider.Close()
+	targets, err := provider.GetTargets(ctx)
+	if err != nil {
+		d.fail("failed to list the cluster contexts: %v", err)
+		return
+	}
+	strategy := staticConfig.ClusterProviderStrategy
+	if strategy == "" {
+		strategy = "auto-detected"
+	}
+	d.ok("loaded with the %s cluster provider strategy: %d contexts, default %q", strategy, len(targets), provider.GetDefaultTarget())
+
+	enabledTools := []string{}
+	server, err := localmcp.NewServer(localmcp.Configuration{StaticConfig: &staticConfig, Extensions: m.ExtensionsConfig})
+	if err != nil {
+		d.fail("failed to initialize the MCP server: %v", err)
+	} else {
+		enabledTools = server.GetEnabledTools()
+		server.Close()
+	}
+	for _, target := range targets {
+		name := target
+		if name == "" {
+			name = "(in-cluster)"
+		}
+		d.section("Cluster context " + name)
+		k, err := provider.GetDerivedKubernetes(ctx, target)
+		if err != nil {
+			d.fail("failed to create the client: %v", err)
+			continue
+		}
+		d.checkCluster(ctx, k.AccessControlClientset(), enabledTools)
+	}
+}
+
+func (d *doctor) checkCluster(ctx context.Context, clientset *internalk8s.AccessControlClientset, enabledTools []string) {
+	m := d.options
+	var version string
+	err := d.withTimeout(ctx, func(context.Context) error {
+		info, err := clientset.DiscoveryClient().ServerVersion()
+		if err == nil {
+			version = info.GitVersion
+		}
+		return err
+	})
+	if err != nil {
+		d.fail("connectivity: %v", err)
+		return
+	}
+	d.ok("connectivity: Kubernetes %s", version)
+	reviews, err := clientset.SelfSubjectAccessReviews()
+	if err != nil {
+		d.fail("authentication: %v", err)
+		return
+	}
+	allowed := func(attributes authorizationv1.ResourceAttributes) (bool, error) {
+		var review *authorizationv1.SelfSubjectAccessReview
+		err := d.withTimeout(ctx, func(ctx context.Context) error {
+			var err error
+			review, err = reviews.Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &attributes},
+			}, metav1.CreateOptions{})
+			return err
+		})
+		if err != nil {
+			return false, err
+		}
+		return review.Status.Allowed, nil
+	}
+	if _, err := allowed(authorizationv1.ResourceAttributes{Verb: "list", Resource: "namespaces"}); err != nil {
+		if apierrors.IsUnauthorized(err) {
+			d.fail("authentication: the credentials were rejected: %v", err)
+		} else {
+			d.fail("authentication: %v", err)
+		}
+		return
+	}
+	d.ok("authentication: the credentials were accepted")
+
+	if m.ExtensionsConfig.Impersonation.Enabled || m.ExtensionsConfig.Authentication.Enabled() {
+		for _, resource := range []string{"users", "groups"} {
+			if ok, err := allowed(authorizationv1.ResourceAttributes{Verb: "impersonate", Resource: resource}); err != nil {
+				d.fail("impersonation: %v", err)
+			} else if !ok {
+				d.fail("impersonation: impersonate %s is denied, required to impersonate the authenticated users", resource)
+			}
+		}
+	}
+
+	namespaces := m.ExtensionsConfig.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	for _, name := range m.StaticConfig.Toolsets {
+		toolset, ok := localtoolsets.ToolsetFromString(name).(localapi.PermissionsProvider)
+		if !ok {
+			continue
+		}
+		denied := 0
+		for _, permission := range toolset.RequiredPermissions() {
+			tools := slices.DeleteFunc(slices.Clone(permission.Tools), func(tool string) bool {
+				return !slices.Contains(enabledTools, tool)
+			})
+			if len(tools) == 0 {
+				continue
+			}
+			for _, namespace := range namespaces {
+				ok, err := allowed(authorizationv1.ResourceAttributes{
+					Namespace:   namespace,
+					Verb:        permission.Verb,
+					Group:       permission.Group,
+					Resource:    permission.Resource,
+					Subresource: permission.Subresource,
+				})
+				if err != nil {
+					d.fail("RBAC %s: %v", name, err)
+					return
+				}
+				if !ok {
+					denied++
+					d.warn("RBAC %s: %s is denied%s, required by %s", name, permissionString(permission), namespaceString(namespace), strings.Join(tools, ", "))
+				}
+			}
+		}
+		if denied == 0 {
+			d.ok("RBAC %s: the permissions required by the enabled tools are granted", name)
+		}
+	}
+}
+
+// checkHelm checks the Helm repositories used to resolve the chart references of helm_install.
+func (d *doctor) checkHelm() {
+	d.section("Helm")
+	settings := cli.New()
+	repositories, err := repo.LoadFile(settings.RepositoryConfig)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		repositories = repo.NewFile()
+		d.warn("no repositories configured in %s, only OCI, URL and local charts can be installed", settings.RepositoryConfig)
+	case err != nil:
+		d.fail("failed to load the repositories from %s: %v", settings.RepositoryConfig, err)
+		return
+	default:
+		d.ok("%d repositories configured in %s", len(repositories.Repositories), settings.RepositoryConfig)
+	}
+	cfg, err := d.options.ExtensionsConfig.ToolsetConfig(localtoolsets.ToolsetFromString("helm").(localapi.ConfigurableToolset))
+	if err != nil {
+		return
+	}
+	repository := cfg.(*localtoolsets.HelmConfig).DefaultRepository
+	if repository == "" || strings.Contains(repository, "://") {
+		return
+	}
+	name, _, _ := strings.Cut(repository, "/")
+	if !repositories.Has(name) {
+		d.fail("the default_repository %q is not a configured repository, add it with helm repo add", name)
+	}
+}
+
+// checkOIDC checks the discovery of the OIDC provider of the authorization URL.
+func (d *doctor) checkOIDC(ctx context.Context) {
+	d.section("OIDC")
+	err := d.withTimeout(ctx, func(ctx context.Context) error {
+		_, _, err := d.options.newOIDCProvider(ctx)
+		return err
+	})
+	if err != nil {
+		d.fail("%v", err)
+		return
+	}
+	d.ok("discovered the OIDC provider of %s", d.options.StaticConfig.AuthorizationURL)
+}
+
+// checkPort checks the HTTP port can be listened on.
+func (d *doctor) checkPort() {
+	d.section("Port")
+	listener, err := net.Listen("tcp", ":"+d.options.StaticConfig.Port)
+	if err != nil {
+		d.fail("port %s is not available: %v", d.options.StaticConfig.Port, err)
+		return
+	}
+	_ = listener.Close()
+	d.ok("port %s is available", d.options.StaticConfig.Port)
+}
+
+// checkSocket checks the Unix domain socket can be listened on: its directory exists and no server uses it.
+func (d *doctor) checkSocket() {
+	d.section("Socket")
+	path := d.options.ExtensionsConfig.Listen.SocketPath()
+	if info, err := os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+		d.fail("socket %s is not available: the directory %s doesn't exist", path, filepath.Dir(path))
+		return
+	}
+	info, err := os.Lstat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		d.ok("socket %s is available", path)
+	case err != nil:
+		d.fail("socket %s is not available: %v", path, err)
+	case info.Mode().Type() != fs.ModeSocket:
+		d.fail("socket %s is not available: the file exists and is not a socket", path)
+	default:
+		if conn, err := net.DialTimeout("unix", path, d.timeout); err == nil {
+			_ = conn.Close()
+			d.fail("socket %s is not available: it is in use by another server", path)
+			return
+		}
+		d.ok("socket %s is available (the stale socket file will be replaced)", path)
+	}
+}
+
+// withTimeout runs the check with the timeout, returning an error if it doesn't complete in time
+// (for the clients that don't accept a context).
+func (d *doctor) withTimeout(ctx context.Context, check func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %s", d.timeout)
+	}
+}
+
+func permissionString(permission localapi.Permission) string {
+	resource := permission.Resource
+	if permission.Subresource != "" {
+		resource += "/" + permission.Subresource
+	}
+	if permission.Group != "" {
+		resource += "." + permission.Group
+	}
+	return permission.Verb + " " + resource
+}
+
+func namespaceString(namespace string) string {
+	if namespace == metav1.NamespaceAll {
+		return ""
+	}
+	return " in namespace " + namespace
+}
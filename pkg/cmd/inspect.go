@@ -0,0 +1,478 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	localconfig "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	localhttp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/http"
+)
+
+var inspectExamples = templates.Examples(`
+# inspect the server built from the configuration, in-process
+extendable-k8s-mcp inspect --config config.toml --toolsets core,my-toolset
+
+# inspect a server running on port 8080 of this host, showing the raw JSON-RPC traffic
+extendable-k8s-mcp inspect --port 8080 --raw
+
+# inspect a server listening on a Unix domain socket
+extendable-k8s-mcp inspect --listen unix:///run/mcp/mcp.sock
+
+# inspect a remote server authenticated with an API key
+extendable-k8s-mcp inspect --url https://mcp.example.com/mcp --header "Authorization: Bearer $API_KEY"
+
+# inspect a remote server through its WebSocket endpoint
+extendable-k8s-mcp inspect --url wss://mcp.example.com/ws --header "Authorization: Bearer $API_KEY"
+`)
+
+const inspectHelp = `Commands:
+  tools                 list the tools
+  resources             list the resources and resource templates
+  prompts               list the prompts
+  call <tool> [json]    call the tool, prompting for the arguments unless provided as a JSON object
+  read <uri>            read the resource
+  raw [on|off]          toggle the raw JSON-RPC traffic view
+  help                  show this help
+  exit                  end the session
+`
+
+func newInspectCommand(o *ExtendableMCPServerOptions) *cobra.Command {
+	var url string
+	var headers []string
+	var raw bool
+	cmd := &cobra.Command{
+		Use:   "inspect",
+		Short: "Start an interactive session to list and call the tools, resources and prompts",
+		Long: "Start an interactive terminal session against the server built from the configuration (in-process), " +
+			"or against a running server with --port or --listen (on this host) or --url. " +
+			"Tools are called with prompts for their arguments and the raw JSON-RPC traffic can be displayed.",
+		Example:      inspectExamples,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, _ []string) error {
+			ctx := c.Context()
+			traffic := &rawTraffic{out: o.Out, enabled: raw}
+			switch {
+			case url != "":
+			case c.Flags().Changed(flagListen):
+				url = o.Listen
+			case c.Flags().Changed(flagPort):
+				url = "http://localhost:" + o.Port + "/mcp"
+			}
+			var session *mcp.ClientSession
+			if url != "" {
+				header, err := parseHeaders(headers)
+				if err != nil {
+					return err
+				}
+				transport, err := remoteTransport(ctx, url, header)
+				if err != nil {
+					return fmt.Errorf("failed to connect to the MCP server at %s: %w", url, err)
+				}
+				if session, err = newCLIClient().Connect(ctx, traffic.wrap(transport), nil); err != nil {
+					return fmt.Errorf("failed to connect to the MCP server at %s: %w", url, err)
+				}
+			} else {
+				server, inProcessSession, err := o.connectInProcessTransport(ctx, c, traffic.wrap)
+				if err != nil {
+					return err
+				}
+				defer server.Close()
+				session = inProcessSession
+			}
+			defer func() { _ = session.Close() }()
+			i := &inspector{session: session, in: bufio.NewScanner(o.In), traffic: traffic}
+			return i.run(ctx)
+		},
+	}
+	cmd.Flags().StringVar(&url, "url", url,
+		"URL of the streamable HTTP or WebSocket endpoint of a running server (e.g. https://mcp.example.com/mcp or "+
+			"wss://mcp.example.com/ws), or its Unix domain socket (unix:///run/mcp/mcp.sock)")
+	cmd.Flags().StringArrayVar(&headers, "header", headers, "HTTP header sent to the running server as \"Name: value\", may be repeated")
+	cmd.Flags().BoolVar(&raw, "raw", raw, "Display the raw JSON-RPC traffic from the start")
+	return cmd
+}
+
+// inspector is an interactive session against an MCP server.
+type inspector struct {
+	session *mcp.ClientSession
+	in      *bufio.Scanner
+	// traffic displays the raw JSON-RPC traffic, the output is written through it so the messages
+	// logged by the transport goroutines don't interleave with the command output
+	traffic *rawTraffic
+}
+
+// run reads and runs the commands until exit or the end of the input.
+func (i *inspector) run(ctx context.Context) error {
+	result := i.session.InitializeResult()
+	if result != nil && result.ServerInfo != nil {
+		i.printf("Connected to %s %s\n", result.ServerInfo.Name, result.ServerInfo.Version)
+	}
+	i.printf("%s", inspectHelp)
+	for {
+		line, ok := i.prompt("mcp> ")
+		if !ok {
+			return i.in.Err()
+		}
+		command, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+		var err error
+		switch command {
+		case "":
+		case "tools":
+			err = i.listTools(ctx)
+		case "resources":
+			err = i.listResources(ctx)
+		case "prompts":
+			err = i.listPrompts(ctx)
+		case "call":
+			err = i.callTool(ctx, rest)
+		case "read":
+			err = i.readResource(ctx, rest)
+		case "raw":
+			err = i.toggleRaw(rest)
+		case "help":
+			i.printf("%s", inspectHelp)
+		case "exit", "quit":
+			return nil
+		default:
+			err = fmt.Errorf("unknown command %q, type help for the available commands", command)
+		}
+		if err != nil {
+			i.printf("Error: %v\n", err)
+		}
+	}
+}
+
+func (i *inspector) printf(format string, args ...any) {
+	i.traffic.mu.Lock()
+	defer i.traffic.mu.Unlock()
+	_, _ = fmt.Fprintf(i.traffic.out, format, args...)
+}
+
+// prompt reads a trimmed line after printing the prompt, returning false at the end of the input.
+func (i *inspector) prompt(prompt string) (string, bool) {
+	i.printf("%s", prompt)
+	if !i.in.Scan() {
+		i.printf("\n")
+		return "", false
+	}
+	return strings.TrimSpace(i.in.Text()), true
+}
+
+func (i *inspector) listTools(ctx context.Context) error {
+	result, err := i.session.ListTools(ctx, &mcp.ListToolsParams{})
+	if err != nil {
+		return err
+	}
+	var table strings.Builder
+	if err := printToolsTable(&table, result.Tools); err != nil {
+		return err
+	}
+	i.printf("%s", table.String())
+	return nil
+}
+
+func (i *inspector) listResources(ctx context.Context) error {
+	if capabilities := i.session.InitializeResult().Capabilities; capabilities == nil || capabilities.Resources == nil {
+		i.printf("The server has no resources\n")
+		return nil
+	}
+	resources, err := i.session.ListResources(ctx, &mcp.ListResourcesParams{})
+	if err != nil {
+		return err
+	}
+	for _, resource := range resources.Resources {
+		i.printf("%s\t%s\t%s\n", resource.URI, resource.Name, resource.MIMEType)
+	}
+	templates, err := i.session.ListResourceTemplates(ctx, &mcp.ListResourceTemplatesParams{})
+	if err != nil {
+		return err
+	}
+	for _, template := range templates.ResourceTemplates {
+		i.printf("%s\t%s\t%s\n", template.URITemplate, template.Name, template.MIMEType)
+	}
+	if len(resources.Resources) == 0 && len(templates.ResourceTemplates) == 0 {
+		i.printf("The server has no resources\n")
+	}
+	return nil
+}
+
+func (i *inspector) listPrompts(ctx context.Context) error {
+	if capabilities := i.session.InitializeResult().Capabilities; capabilities == nil || capabilities.Prompts == nil {
+		i.printf("The server has no prompts\n")
+		return nil
+	}
+	prompts, err := i.session.ListPrompts(ctx, &mcp.ListPromptsParams{})
+	if err != nil {
+		return err
+	}
+	for _, prompt := range prompts.Prompts {
+		i.printf("%s\t%s\n", prompt.Name, prompt.Description)
+	}
+	if len(prompts.Prompts) == 0 {
+		i.printf("The server has no prompts\n")
+	}
+	return nil
+}
+
+// callTool calls the tool with the JSON arguments, or with the arguments prompted from its input schema.
+func (i *inspector) callTool(ctx context.Context, line string) error {
+	name, jsonArguments, _ := strings.Cut(line, " ")
+	if name == "" {
+		return fmt.Errorf("usage: call <tool> [json]")
+	}
+	arguments := make(map[string]any)
+	if jsonArguments = strings.TrimSpace(jsonArguments); jsonArguments != "" {
+		if err := json.Unmarshal([]byte(jsonArguments), &arguments); err != nil {
+			return fmt.Errorf("invalid tool arguments, a JSON object is expected: %w", err)
+		}
+	} else {
+		tool, err := i.findTool(ctx, name)
+		if err != nil {
+			return err
+		}
+		if arguments, err = i.promptArguments(tool); err != nil {
+			return err
+		}
+	}
+	result, err := i.session.CallTool(ctx, &mcp.CallToolParams{Name: name, Arguments: arguments})
+	if err != nil {
+		return err
+	}
+	if result.IsError {
+		i.printf("Tool error: %s\n", toolResultText(result))
+		return nil
+	}
+	i.printf("%s\n", toolResultText(result))
+	return nil
+}
+
+func (i *inspector) findTool(ctx context.Context, name string) (*mcp.Tool, error) {
+	for tool, err := range i.session.Tools(ctx, nil) {
+		if err != nil {
+			return nil, err
+		}
+		if tool.Name == name {
+			return tool, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown tool %q", name)
+}
+
+// promptArguments prompts for the arguments of the tool input schema, optional arguments are skipped if empty.
+func (i *inspector) promptArguments(tool *mcp.Tool) (map[string]any, error) {
+	arguments := make(map[string]any)
+	for _, parameter := range toolParameters(tool) {
+		if parameter.description != "" {
+			i.printf("  %s\n", parameter.description)
+		}
+		label := parameter.name + " (" + valueOrNone(parameter.typ)
+		if parameter.required {
+			label += ", required"
+		}
+		label += "): "
+		for {
+			value, ok := i.prompt(label)
+			if !ok {
+				return nil, fmt.Errorf("tool call aborted")
+			}
+			if value == "" {
+				if parameter.required {
+					continue
+				}
+				break
+			}
+			converted, err := convertArgument(parameter.typ, value)
+			if err != nil {
+				i.printf("Invalid value: %v\n", err)
+				continue
+			}
+			arguments[parameter.name] = converted
+			break
+		}
+	}
+	return arguments, nil
+}
+
+func (i *inspector) readResource(ctx context.Context, uri string) error {
+	if uri == "" {
+		return fmt.Errorf("usage: read <uri>")
+	}
+	result, err := i.session.ReadResource(ctx, &mcp.ReadResourceParams{URI: uri})
+	if err != nil {
+		return err
+	}
+	for _, content := range result.Contents {
+		if content.Text != "" {
+			i.printf("%s\n", content.Text)
+		} else {
+			i.printf("<%d bytes of %s>\n", len(content.Blob), valueOrNone(content.MIMEType))
+		}
+	}
+	return nil
+}
+
+func (i *inspector) toggleRaw(value string) error {
+	switch value {
+	case "":
+		i.traffic.setEnabled(!i.traffic.isEnabled())
+	case "on":
+		i.traffic.setEnabled(true)
+	case "off":
+		i.traffic.setEnabled(false)
+	default:
+		return fmt.Errorf("usage: raw [on|off]")
+	}
+	if i.traffic.isEnabled() {
+		i.printf("Raw JSON-RPC traffic view enabled (--> sent, <-- received)\n")
+	} else {
+		i.printf("Raw JSON-RPC traffic view disabled\n")
+	}
+	return nil
+}
+
+// rawTraffic displays the JSON-RPC messages exchanged over the wrapped transports while enabled.
+type rawTraffic struct {
+	mu      sync.Mutex
+	out     io.Writer
+	enabled bool
+}
+
+// wrap returns the transport displaying the JSON-RPC messages exchanged over the provided transport.
+func (r *rawTraffic) wrap(transport mcp.Transport) mcp.Transport {
+	return &rawTransport{Transport: transport, traffic: r}
+}
+
+func (r *rawTraffic) log(direction string, msg jsonrpc.Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.enabled {
+		return
+	}
+	data, err := jsonrpc.EncodeMessage(msg)
+	if err != nil {
+		_, _ = fmt.Fprintf(r.out, "%s <invalid message: %v>\n", direction, err)
+		return
+	}
+	_, _ = fmt.Fprintf(r.out, "%s %s\n", direction, data)
+}
+
+func (r *rawTraffic) setEnabled(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.enabled = enabled
+}
+
+func (r *rawTraffic) isEnabled() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.enabled
+}
+
+type rawTransport struct {
+	mcp.Transport
+	traffic *rawTraffic
+}
+
+func (t *rawTransport) Connect(ctx context.Context) (mcp.Connection, error) {
+	conn, err := t.Transport.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &rawConnection{Connection: conn, traffic: t.traffic}, nil
+}
+
+// rawConnection logs the messages sent before writing them, so they are displayed before their responses.
+type rawConnection struct {
+	mcp.Connection
+	traffic *rawTraffic
+}
+
+func (c *rawConnection) Read(ctx context.Context) (jsonrpc.Message, error) {
+	msg, err := c.Connection.Read(ctx)
+	if err == nil {
+		c.traffic.log("<--", msg)
+	}
+	return msg, err
+}
+
+func (c *rawConnection) Write(ctx context.Context, msg jsonrpc.Message) error {
+	c.traffic.log("-->", msg)
+	return c.Connection.Write(ctx, msg)
+}
+
+// parseHeaders parses the "Name: value" HTTP headers.
+func parseHeaders(headers []string) (http.Header, error) {
+	header := make(http.Header)
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid --header %q, \"Name: value\" is expected", h)
+		}
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return header, nil
+}
+
+// remoteTransport returns the client transport of the server URL: the WebSocket transport for ws:// and wss://
+// URLs, the streamable HTTP transport otherwise (through the Unix domain socket for unix:// URLs).
+func remoteTransport(ctx context.Context, url string, header http.Header) (mcp.Transport, error) {
+	if strings.HasPrefix(url, "ws://") || strings.HasPrefix(url, "wss://") {
+		dialer := &websocket.Dialer{Proxy: http.ProxyFromEnvironment, Subprotocols: []string{localhttp.WebSocketSubprotocol}}
+		conn, resp, err := dialer.DialContext(ctx, url, header)
+		if err != nil {
+			if resp != nil {
+				return nil, fmt.Errorf("%w: %s", err, resp.Status)
+			}
+			return nil, err
+		}
+		return &localhttp.WebSocketTransport{Conn: conn}, nil
+	}
+	endpoint, roundTripper := url, http.DefaultTransport
+	if socket, ok := strings.CutPrefix(url, localconfig.ListenSchemeUnix); ok {
+		endpoint, roundTripper = "http://localhost/mcp", unixRoundTripper(socket)
+	}
+	return &mcp.StreamableClientTransport{
+		Endpoint:   endpoint,
+		HTTPClient: &http.Client{Transport: &headerRoundTripper{header: header, next: roundTripper}},
+	}, nil
+}
+
+// unixRoundTripper returns the HTTP transport connecting to the Unix domain socket whatever the request host.
+func unixRoundTripper(socket string) http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+	}
+	return transport
+}
+
+// headerRoundTripper adds the headers to the requests.
+type headerRoundTripper struct {
+	header http.Header
+	next   http.RoundTripper
+}
+
+func (t *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, values := range t.header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	return t.next.RoundTrip(req)
+}
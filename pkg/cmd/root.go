@@ -6,6 +6,7 @@
 package cmd
 
 import (
+	"cmp"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
@@ -15,11 +16,18 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
 	"k8s.io/cli-runtime/pkg/genericiooptions"
 	"k8s.io/klog/v2"
@@ -28,20 +36,24 @@ import (
 	"k8s.io/kubectl/pkg/util/templates"
 
 	"github.com/containers/kubernetes-mcp-server/pkg/config"
-	internalhttp "github.com/containers/kubernetes-mcp-server/pkg/http"
-	k8smcp "github.com/containers/kubernetes-mcp-server/pkg/mcp"
 	"github.com/containers/kubernetes-mcp-server/pkg/output"
 	"github.com/containers/kubernetes-mcp-server/pkg/toolsets"
 	"github.com/containers/kubernetes-mcp-server/pkg/version"
 
-	// Import local mcp package to load toolsets via modules.go
-	_ "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/mcp"
+	localconfig "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	localhttp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/http"
+	// The local mcp package also loads the toolsets via modules.go
+	localmcp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/mcp"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/redact"
 )
 
 var (
 	long = templates.LongDesc(i18n.T(
 		"Extendable Kubernetes Model Context Protocol (MCP) server - " +
-			"Clean foundation replicating kubernetes-mcp-server"))
+			"Clean foundation replicating kubernetes-mcp-server\n\n" +
+			"Every flag can also be set with an environment variable prefixed with " + envPrefix + ", " +
+			"e.g. " + envPrefix + "READ_ONLY=true for --read-only. " +
+			"Flags take precedence over environment variables, which take precedence over the config files."))
 	examples = templates.Examples(i18n.T(`
 # show this help
 extendable-k8s-mcp -h
@@ -52,21 +64,37 @@ extendable-k8s-mcp --version
 # start STDIO server
 extendable-k8s-mcp
 
+# start STDIO server merging a base config file, an overlay and the files of the config.d drop-in directory
+extendable-k8s-mcp --config base.toml --config overlay.toml --config-dir config.d
+
+# start STDIO server in read-only mode, configured through the environment
+EXTENDABLE_K8S_MCP_READ_ONLY=true extendable-k8s-mcp
+
 # start a SSE server on port 8080
 extendable-k8s-mcp --port 8080
 
 # start a SSE server on port 8443 with a public HTTPS host of example.com
 extendable-k8s-mcp --port 8443 --sse-base-url https://example.com:8443
 
+# start a HTTPS server on port 8443 with the provided certificate and key
+extendable-k8s-mcp --port 8443 --tls-cert-file tls.crt --tls-key-file tls.key
+
+# start a SSE server on a Unix domain socket only accessible to its owner
+extendable-k8s-mcp --listen unix:///run/mcp/mcp.sock --listen-socket-mode 0600
+
 # start a SSE server on port 8080 with multi-cluster tools disabled
 extendable-k8s-mcp --port 8080 --disable-multi-cluster
 `))
 )
 
+// envPrefix is the prefix of the environment variables bound to the flags.
+const envPrefix = "EXTENDABLE_K8S_MCP_"
+
 const (
 	flagVersion              = "version"
 	flagLogLevel             = "log-level"
 	flagConfig               = "config"
+	flagConfigDir            = "config-dir"
 	flagPort                 = "port"
 	flagSSEBaseUrl           = "sse-base-url"
 	flagKubeconfig           = "kubeconfig"
@@ -81,6 +109,14 @@ const (
 	flagServerUrl            = "server-url"
 	flagCertificateAuthority = "certificate-authority"
 	flagDisableMultiCluster  = "disable-multi-cluster"
+	flagTLSCertFile          = "tls-cert-file"
+	flagTLSKeyFile           = "tls-key-file"
+	flagTLSMinVersion        = "tls-min-version"
+	flagListen               = "listen"
+	flagListenSocketMode     = "listen-socket-mode"
+	flagShutdownGracePeriod  = "shutdown-grace-period"
+	flagSessionResumption    = "session-resumption"
+	flagProfile              = "profile"
 )
 
 type ExtendableMCPServerOptions struct {
@@ -100,17 +136,39 @@ type ExtendableMCPServerOptions struct {
 	CertificateAuthority string
 	ServerURL            string
 	DisableMultiCluster  bool
-
-	ConfigPath   string
-	StaticConfig *config.StaticConfig
+	TLSCertFile          string
+	TLSKeyFile           string
+	TLSMinVersion        string
+	Listen               string
+	ListenSocketMode     string
+	ShutdownGracePeriod  string
+	SessionResumption    bool
+	Profile              string
+
+	ConfigPaths      []string
+	ConfigDir        string
+	StaticConfig     *config.StaticConfig
+	ExtensionsConfig *localconfig.Config
+
+	// flagOverrides apply the flags set in the command line, reapplied when the configuration is reloaded
+	flagOverrides []func(*config.StaticConfig, *localconfig.Config)
+	// flagSources describes the flag or environment variable that set each config key
+	flagSources map[string]string
+	// envFlags are the flags set from their environment variables
+	envFlags []string
+	// endpoints are the profiles served under their own path in HTTP mode
+	endpoints []profileEndpoint
+	// listeners are the additional HTTP listeners in HTTP mode
+	listeners []listenerServer
 
 	genericiooptions.IOStreams
 }
 
 func NewExtendableMCPServerOptions(streams genericiooptions.IOStreams) *ExtendableMCPServerOptions {
 	return &ExtendableMCPServerOptions{
-		IOStreams:    streams,
-		StaticConfig: config.Default(),
+		IOStreams:        streams,
+		StaticConfig:     config.Default(),
+		ExtensionsConfig: localconfig.Default(),
 	}
 }
 
@@ -136,111 +194,312 @@ func NewExtendableMCPServer(streams genericiooptions.IOStreams) *cobra.Command {
 		},
 	}
 
-	// Add all kubernetes-mcp-server flags
+	// Add all kubernetes-mcp-server flags, shared with the subcommands loading the configuration
 	cmd.Flags().BoolVar(&o.Version, flagVersion, o.Version, "Print version information and quit")
-	cmd.Flags().IntVar(&o.LogLevel, flagLogLevel, o.LogLevel, "Set the log level (from 0 to 9)")
-	cmd.Flags().StringVar(&o.ConfigPath, flagConfig, o.ConfigPath, "Path of the config file.")
-	cmd.Flags().StringVar(&o.Port, flagPort, o.Port, "Start a streamable HTTP and SSE HTTP server on the specified port (e.g. 8080)")
-	cmd.Flags().StringVar(&o.SSEBaseUrl, flagSSEBaseUrl, o.SSEBaseUrl, "SSE public base URL to use when sending the endpoint message (e.g. https://example.com)")
-	cmd.Flags().StringVar(&o.Kubeconfig, flagKubeconfig, o.Kubeconfig, "Path to the kubeconfig file to use for authentication")
-	cmd.Flags().StringSliceVar(&o.Toolsets, flagToolsets, o.Toolsets,
+	cmd.PersistentFlags().IntVar(&o.LogLevel, flagLogLevel, o.LogLevel, "Set the log level (from 0 to 9)")
+	cmd.PersistentFlags().StringSliceVar(&o.ConfigPaths, flagConfig, o.ConfigPaths,
+		"Path of the config file, may be repeated to merge several files in order (later files take precedence)")
+	cmd.PersistentFlags().StringVar(&o.ConfigDir, flagConfigDir, o.ConfigDir,
+		"Drop-in directory whose *.toml files are merged in lexical order after the config files. "+
+			"Defaults to the "+localconfig.DropInDir+" directory next to the first config file, if any.")
+	cmd.PersistentFlags().StringVar(&o.Port, flagPort, o.Port, "Start a streamable HTTP and SSE HTTP server on the specified port (e.g. 8080)")
+	cmd.PersistentFlags().StringVar(&o.SSEBaseUrl, flagSSEBaseUrl, o.SSEBaseUrl, "SSE public base URL to use when sending the endpoint message (e.g. https://example.com)")
+	cmd.PersistentFlags().StringVar(&o.Kubeconfig, flagKubeconfig, o.Kubeconfig, "Path to the kubeconfig file to use for authentication")
+	cmd.PersistentFlags().StringSliceVar(&o.Toolsets, flagToolsets, o.Toolsets,
 		"Comma-separated list of MCP toolsets to use (available toolsets: "+
 			strings.Join(toolsets.ToolsetNames(), ", ")+"). Defaults to "+
 			strings.Join(o.StaticConfig.Toolsets, ", ")+".")
-	cmd.Flags().StringVar(&o.ListOutput, flagListOutput, o.ListOutput,
+	cmd.PersistentFlags().StringVar(&o.ListOutput, flagListOutput, o.ListOutput,
 		"Output format for resource list operations (one of: "+
 			strings.Join(output.Names, ", ")+"). Defaults to "+o.StaticConfig.ListOutput+".")
-	cmd.Flags().BoolVar(&o.ReadOnly, flagReadOnly, o.ReadOnly, "If true, only tools annotated with readOnlyHint=true are exposed")
-	cmd.Flags().BoolVar(&o.DisableDestructive, flagDisableDestructive, o.DisableDestructive, "If true, tools annotated with destructiveHint=true are disabled")
-	cmd.Flags().BoolVar(&o.RequireOAuth, flagRequireOAuth, o.RequireOAuth,
+	cmd.PersistentFlags().BoolVar(&o.ReadOnly, flagReadOnly, o.ReadOnly, "If true, only tools annotated with readOnlyHint=true are exposed")
+	cmd.PersistentFlags().BoolVar(&o.DisableDestructive, flagDisableDestructive, o.DisableDestructive, "If true, tools annotated with destructiveHint=true are disabled")
+	cmd.PersistentFlags().BoolVar(&o.RequireOAuth, flagRequireOAuth, o.RequireOAuth,
 		"If true, requires OAuth authorization as defined in the Model Context Protocol (MCP) "+
 			"specification. This flag is ignored if transport type is stdio")
-	_ = cmd.Flags().MarkHidden(flagRequireOAuth)
-	cmd.Flags().StringVar(&o.OAuthAudience, flagOAuthAudience, o.OAuthAudience,
+	_ = cmd.PersistentFlags().MarkHidden(flagRequireOAuth)
+	cmd.PersistentFlags().StringVar(&o.OAuthAudience, flagOAuthAudience, o.OAuthAudience,
 		"OAuth audience for token claims validation. Optional. If not set, "+
 			"the audience is not validated. Only valid if require-oauth is enabled.")
-	_ = cmd.Flags().MarkHidden(flagOAuthAudience)
-	cmd.Flags().BoolVar(&o.ValidateToken, flagValidateToken, o.ValidateToken,
+	_ = cmd.PersistentFlags().MarkHidden(flagOAuthAudience)
+	cmd.PersistentFlags().BoolVar(&o.ValidateToken, flagValidateToken, o.ValidateToken,
 		"If true, validates the token against the Kubernetes API Server using TokenReview. "+
 			"Optional. If not set, the token is not validated. Only valid if require-oauth is enabled.")
-	_ = cmd.Flags().MarkHidden(flagValidateToken)
-	cmd.Flags().StringVar(&o.AuthorizationURL, flagAuthorizationURL, o.AuthorizationURL,
+	_ = cmd.PersistentFlags().MarkHidden(flagValidateToken)
+	cmd.PersistentFlags().StringVar(&o.AuthorizationURL, flagAuthorizationURL, o.AuthorizationURL,
 		"OAuth authorization server URL for protected resource endpoint. "+
 			"If not provided, the Kubernetes API server host will be used. Only valid if require-oauth is enabled.")
-	_ = cmd.Flags().MarkHidden(flagAuthorizationURL)
-	cmd.Flags().StringVar(&o.ServerURL, flagServerUrl, o.ServerURL,
+	_ = cmd.PersistentFlags().MarkHidden(flagAuthorizationURL)
+	cmd.PersistentFlags().StringVar(&o.ServerURL, flagServerUrl, o.ServerURL,
 		"Server URL of this application. Optional. If set, this url will be served in "+
 			"protected resource metadata endpoint and tokens will be validated with this audience. "+
 			"If not set, expected audience is kubernetes-mcp-server. Only valid if require-oauth is enabled.")
-	_ = cmd.Flags().MarkHidden(flagServerUrl)
-	cmd.Flags().StringVar(&o.CertificateAuthority, flagCertificateAuthority, o.CertificateAuthority,
+	_ = cmd.PersistentFlags().MarkHidden(flagServerUrl)
+	cmd.PersistentFlags().StringVar(&o.CertificateAuthority, flagCertificateAuthority, o.CertificateAuthority,
 		"Certificate authority path to verify certificates. Optional. Only valid if require-oauth is enabled.")
-	_ = cmd.Flags().MarkHidden(flagCertificateAuthority)
-	cmd.Flags().BoolVar(&o.DisableMultiCluster, flagDisableMultiCluster, o.DisableMultiCluster,
+	_ = cmd.PersistentFlags().MarkHidden(flagCertificateAuthority)
+	cmd.PersistentFlags().BoolVar(&o.DisableMultiCluster, flagDisableMultiCluster, o.DisableMultiCluster,
 		"Disable multi cluster tools. Optional. If true, all tools will be run against the default cluster/context.")
+	cmd.PersistentFlags().StringVar(&o.TLSCertFile, flagTLSCertFile, o.TLSCertFile,
+		"Path to the TLS certificate file to serve HTTPS (reloaded on change). Only valid with --port or --listen.")
+	cmd.PersistentFlags().StringVar(&o.TLSKeyFile, flagTLSKeyFile, o.TLSKeyFile,
+		"Path to the TLS private key file to serve HTTPS (reloaded on change). Only valid with --port or --listen.")
+	cmd.PersistentFlags().StringVar(&o.Profile, flagProfile, o.Profile,
+		"Name of the config file profile to apply (bundling toolsets, read-only mode, namespaces and cluster strategy)")
+	cmd.PersistentFlags().StringVar(&o.TLSMinVersion, flagTLSMinVersion, o.TLSMinVersion,
+		"Minimum TLS version accepted by the HTTPS server (one of: 1.2, 1.3). Defaults to "+o.ExtensionsConfig.TLS.MinVersion+".")
+	cmd.PersistentFlags().StringVar(&o.Listen, flagListen, o.Listen,
+		"Start the streamable HTTP and SSE HTTP server on the Unix domain socket instead of a TCP port (e.g. unix:///run/mcp/mcp.sock)")
+	cmd.PersistentFlags().StringVar(&o.ListenSocketMode, flagListenSocketMode, o.ListenSocketMode,
+		"Octal file mode of the --listen Unix domain socket. Defaults to "+o.ExtensionsConfig.Listen.SocketMode+".")
+	cmd.PersistentFlags().StringVar(&o.ShutdownGracePeriod, flagShutdownGracePeriod, o.ShutdownGracePeriod,
+		"Maximum time given to the in-flight tool calls to complete on SIGTERM or SIGINT, new sessions and tool calls "+
+			"being refused meanwhile (e.g. 1m). Defaults to "+o.ExtensionsConfig.Shutdown.GracePeriod+".")
+	cmd.PersistentFlags().BoolVar(&o.SessionResumption, flagSessionResumption, o.SessionResumption,
+		"Keep stateful streamable HTTP sessions whose streams are resumed with Last-Event-ID after a dropped connection, "+
+			"replaying the missed events. Unless the [sessions] store is shared, the clients must reconnect to the same server instance.")
+
+	cmd.AddCommand(newConfigCommand(o))
+	cmd.AddCommand(newToolsCommand(o))
+	cmd.AddCommand(newCallCommand(o))
+	cmd.AddCommand(newInspectCommand(o))
+	cmd.AddCommand(newDoctorCommand(o))
 
 	return cmd
 }
 
 func (m *ExtendableMCPServerOptions) Complete(cmd *cobra.Command) error {
-	if m.ConfigPath != "" {
-		cnf, err := config.Read(m.ConfigPath)
-		if err != nil {
-			return err
-		}
-		m.StaticConfig = cnf
+	if err := m.completeConfiguration(cmd); err != nil {
+		return err
+	}
+
+	m.initializeLogging()
+
+	return nil
+}
+
+// completeConfiguration loads the configuration from the config files, environment variables and flags.
+func (m *ExtendableMCPServerOptions) completeConfiguration(cmd *cobra.Command) error {
+	if err := m.bindEnvironment(cmd); err != nil {
+		return err
 	}
 
 	m.loadFlags(cmd)
 
-	m.initializeLogging()
+	return m.loadConfiguration()
+}
 
-	if m.StaticConfig.RequireOAuth && m.StaticConfig.Port == "" {
-		// RequireOAuth is not relevant flow for STDIO transport
-		m.StaticConfig.RequireOAuth = false
+// envName returns the environment variable bound to the flag (e.g. EXTENDABLE_K8S_MCP_READ_ONLY for --read-only).
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// bindEnvironment sets the flags not set in the command line from their environment variables,
+// they are then handled as any other flag set in the command line.
+func (m *ExtendableMCPServerOptions) bindEnvironment(cmd *cobra.Command) error {
+	var err error
+	m.envFlags = nil
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || f.Name == flagVersion || f.Name == "help" {
+			return
+		}
+		if value, ok := os.LookupEnv(envName(f.Name)); ok {
+			if setErr := cmd.Flags().Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid value %q for %s: %w", value, envName(f.Name), setErr)
+				return
+			}
+			m.envFlags = append(m.envFlags, f.Name)
+		}
+	})
+	return err
+}
+
+// flagSource describes where the value of the flag comes from.
+func (m *ExtendableMCPServerOptions) flagSource(name string) string {
+	if slices.Contains(m.envFlags, name) {
+		return "env " + envName(name)
+	}
+	return "flag --" + name
+}
+
+// configFiles returns the config files in the order they are merged, the drop-in files come last.
+func (m *ExtendableMCPServerOptions) configFiles() ([]string, error) {
+	dropInDir := m.dropInDir()
+	if dropInDir == "" {
+		return m.ConfigPaths, nil
+	}
+	dropInFiles, err := localconfig.DropInFiles(dropInDir)
+	if err != nil {
+		return nil, err
 	}
+	return append(slices.Clone(m.ConfigPaths), dropInFiles...), nil
+}
 
+func (m *ExtendableMCPServerOptions) dropInDir() string {
+	if m.ConfigDir != "" || len(m.ConfigPaths) == 0 {
+		return m.ConfigDir
+	}
+	return filepath.Join(filepath.Dir(m.ConfigPaths[0]), localconfig.DropInDir)
+}
+
+// loadConfiguration reads and merges the config files (if any) and applies the selected profile,
+// the command line flags and environment variables on top of them.
+func (m *ExtendableMCPServerOptions) loadConfiguration() error {
+	staticConfig, extensionsConfig, err := m.readConfigFiles()
+	if err != nil {
+		return err
+	}
+	profile := extensionsConfig.Profile
+	if m.Profile != "" {
+		profile = m.Profile
+	}
+	if m.StaticConfig, m.ExtensionsConfig, err = m.resolveConfiguration(staticConfig, extensionsConfig, profile); err != nil {
+		return err
+	}
+	m.endpoints = nil
+	for _, name := range extensionsConfig.EndpointProfiles() {
+		endpoint := profileEndpoint{name: name, path: extensionsConfig.Profiles[name].Path}
+		if endpoint.staticConfig, endpoint.extensionsConfig, err = m.resolveConfiguration(staticConfig, extensionsConfig, name); err != nil {
+			return err
+		}
+		m.endpoints = append(m.endpoints, endpoint)
+	}
+	m.listeners = nil
+	for _, listenerConfig := range extensionsConfig.Listeners {
+		listener := listenerServer{config: listenerConfig}
+		listenerProfile := listenerConfig.Profile
+		if listenerProfile == "" {
+			listenerProfile = profile
+		}
+		s, e, err := m.resolveConfiguration(staticConfig, extensionsConfig, listenerProfile)
+		if err != nil {
+			return err
+		}
+		if listener.staticConfig, listener.extensionsConfig, err = e.ApplyListener(listenerConfig.Name, s); err != nil {
+			return err
+		}
+		m.listeners = append(m.listeners, listener)
+	}
 	return nil
 }
 
+// readConfigFiles reads and merges the config files, or returns the defaults if there are none.
+func (m *ExtendableMCPServerOptions) readConfigFiles() (*config.StaticConfig, *localconfig.Config, error) {
+	files, err := m.configFiles()
+	if err != nil {
+		return nil, nil, err
+	}
+	switch len(files) {
+	case 0:
+		return config.Default(), localconfig.Default(), nil
+	case 1:
+		staticConfig, err := config.Read(files[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		extensionsConfig, err := localconfig.Read(files[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		return staticConfig, extensionsConfig, nil
+	default:
+		data, err := localconfig.Merge(files...)
+		if err != nil {
+			return nil, nil, err
+		}
+		staticConfig, err := config.ReadToml(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		extensionsConfig, err := localconfig.ReadToml(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		return staticConfig, extensionsConfig, nil
+	}
+}
+
+// resolveConfiguration returns copies of the configuration read from the files with the profile (if any),
+// the command line flags and environment variables applied.
+func (m *ExtendableMCPServerOptions) resolveConfiguration(staticConfig *config.StaticConfig, extensionsConfig *localconfig.Config,
+	profile string) (*config.StaticConfig, *localconfig.Config, error) {
+	staticCopy, extensionsCopy := *staticConfig, *extensionsConfig
+	s, e := &staticCopy, &extensionsCopy
+	if profile != "" {
+		var err error
+		if s, e, err = extensionsConfig.ApplyProfile(profile, staticConfig); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, override := range m.flagOverrides {
+		override(s, e)
+	}
+	if s.RequireOAuth && !servesHTTP(s, e) {
+		// RequireOAuth is not relevant flow for STDIO transport
+		s.RequireOAuth = false
+	}
+	return s, e, nil
+}
+
+// loadFlags records the flags set in the command line, applied on top of the config file by loadConfiguration.
 func (m *ExtendableMCPServerOptions) loadFlags(cmd *cobra.Command) {
 	// Load basic flags using a mapping approach to reduce complexity
 	flagMappings := []struct {
-		name   string
-		setter func()
+		name string
+		// key is the config file key set by the flag
+		key    string
+		setter func(s *config.StaticConfig, e *localconfig.Config)
 	}{
-		{flagLogLevel, func() { m.StaticConfig.LogLevel = m.LogLevel }},
-		{flagPort, func() { m.StaticConfig.Port = m.Port }},
-		{flagSSEBaseUrl, func() { m.StaticConfig.SSEBaseURL = m.SSEBaseUrl }},
-		{flagKubeconfig, func() { m.StaticConfig.KubeConfig = m.Kubeconfig }},
-		{flagListOutput, func() { m.StaticConfig.ListOutput = m.ListOutput }},
-		{flagReadOnly, func() { m.StaticConfig.ReadOnly = m.ReadOnly }},
-		{flagDisableDestructive, func() { m.StaticConfig.DisableDestructive = m.DisableDestructive }},
-		{flagToolsets, func() { m.StaticConfig.Toolsets = m.Toolsets }},
-		{flagRequireOAuth, func() { m.StaticConfig.RequireOAuth = m.RequireOAuth }},
-		{flagOAuthAudience, func() { m.StaticConfig.OAuthAudience = m.OAuthAudience }},
-		{flagValidateToken, func() { m.StaticConfig.ValidateToken = m.ValidateToken }},
-		{flagAuthorizationURL, func() { m.StaticConfig.AuthorizationURL = m.AuthorizationURL }},
-		{flagServerUrl, func() { m.StaticConfig.ServerURL = m.ServerURL }},
-		{flagCertificateAuthority, func() { m.StaticConfig.CertificateAuthority = m.CertificateAuthority }},
+		{flagLogLevel, "log_level", func(s *config.StaticConfig, _ *localconfig.Config) { s.LogLevel = m.LogLevel }},
+		{flagPort, "port", func(s *config.StaticConfig, _ *localconfig.Config) { s.Port = m.Port }},
+		{flagSSEBaseUrl, "sse_base_url", func(s *config.StaticConfig, _ *localconfig.Config) { s.SSEBaseURL = m.SSEBaseUrl }},
+		{flagKubeconfig, "kubeconfig", func(s *config.StaticConfig, _ *localconfig.Config) { s.KubeConfig = m.Kubeconfig }},
+		{flagListOutput, "list_output", func(s *config.StaticConfig, _ *localconfig.Config) { s.ListOutput = m.ListOutput }},
+		{flagReadOnly, "read_only", func(s *config.StaticConfig, _ *localconfig.Config) { s.ReadOnly = m.ReadOnly }},
+		{flagDisableDestructive, "disable_destructive", func(s *config.StaticConfig, _ *localconfig.Config) { s.DisableDestructive = m.DisableDestructive }},
+		{flagToolsets, "toolsets", func(s *config.StaticConfig, _ *localconfig.Config) { s.Toolsets = m.Toolsets }},
+		{flagRequireOAuth, "require_oauth", func(s *config.StaticConfig, _ *localconfig.Config) { s.RequireOAuth = m.RequireOAuth }},
+		{flagOAuthAudience, "oauth_audience", func(s *config.StaticConfig, _ *localconfig.Config) { s.OAuthAudience = m.OAuthAudience }},
+		{flagValidateToken, "validate_token", func(s *config.StaticConfig, _ *localconfig.Config) { s.ValidateToken = m.ValidateToken }},
+		{flagAuthorizationURL, "authorization_url", func(s *config.StaticConfig, _ *localconfig.Config) { s.AuthorizationURL = m.AuthorizationURL }},
+		{flagServerUrl, "server_url", func(s *config.StaticConfig, _ *localconfig.Config) { s.ServerURL = m.ServerURL }},
+		{flagCertificateAuthority, "certificate_authority", func(s *config.StaticConfig, _ *localconfig.Config) { s.CertificateAuthority = m.CertificateAuthority }},
+		{flagTLSCertFile, "tls.cert_file", func(_ *config.StaticConfig, e *localconfig.Config) { e.TLS.CertFile = m.TLSCertFile }},
+		{flagTLSKeyFile, "tls.key_file", func(_ *config.StaticConfig, e *localconfig.Config) { e.TLS.KeyFile = m.TLSKeyFile }},
+		{flagTLSMinVersion, "tls.min_version", func(_ *config.StaticConfig, e *localconfig.Config) { e.TLS.MinVersion = m.TLSMinVersion }},
+		{flagListen, "listen.address", func(_ *config.StaticConfig, e *localconfig.Config) { e.Listen.Address = m.Listen }},
+		{flagListenSocketMode, "listen.socket_mode", func(_ *config.StaticConfig, e *localconfig.Config) { e.Listen.SocketMode = m.ListenSocketMode }},
+		{flagShutdownGracePeriod, "shutdown.grace_period", func(_ *config.StaticConfig, e *localconfig.Config) {
+			e.Shutdown.GracePeriod = m.ShutdownGracePeriod
+		}},
+		{flagSessionResumption, "sessions.resumption", func(_ *config.StaticConfig, e *localconfig.Config) {
+			e.Sessions.Resumption = m.SessionResumption
+		}},
+		{flagProfile, "profile", func(_ *config.StaticConfig, e *localconfig.Config) { e.Profile = m.Profile }},
 	}
 
+	m.flagOverrides = nil
+	m.flagSources = make(map[string]string)
 	for _, mapping := range flagMappings {
 		if cmd.Flag(mapping.name).Changed {
-			mapping.setter()
+			m.flagOverrides = append(m.flagOverrides, mapping.setter)
+			m.flagSources[mapping.key] = m.flagSource(mapping.name)
 		}
 	}
 
 	// Handle special case for DisableMultiCluster
 	if cmd.Flag(flagDisableMultiCluster).Changed && m.DisableMultiCluster {
-		m.StaticConfig.ClusterProviderStrategy = config.ClusterProviderDisabled
+		m.flagOverrides = append(m.flagOverrides, func(s *config.StaticConfig, _ *localconfig.Config) {
+			s.ClusterProviderStrategy = config.ClusterProviderDisabled
+		})
+		m.flagSources["cluster_provider_strategy"] = m.flagSource(flagDisableMultiCluster)
 	}
 }
 
 func (m *ExtendableMCPServerOptions) initializeLogging() {
 	flagSet := flag.NewFlagSet("klog", flag.ContinueOnError)
 	klog.InitFlags(flagSet)
-	if m.StaticConfig.Port == "" {
+	if !servesHTTP(m.StaticConfig, m.ExtensionsConfig) {
 		// disable klog output for stdio mode
 		// this is needed to avoid klog writing to stderr and breaking the protocol
 		_ = flagSet.Parse([]string{"-logtostderr=false", "-alsologtostderr=false", "-stderrthreshold=FATAL"})
@@ -257,43 +516,75 @@ func (m *ExtendableMCPServerOptions) initializeLogging() {
 
 //gocyclo:ignore - CLI validation logic with multiple configuration checks
 func (m *ExtendableMCPServerOptions) Validate() error {
+	var errs []error
 	if output.FromString(m.StaticConfig.ListOutput) == nil {
-		return fmt.Errorf("invalid output name: %s, valid names are: %s",
-			m.StaticConfig.ListOutput, strings.Join(output.Names, ", "))
+		errs = append(errs, fmt.Errorf("invalid output name: %s, valid names are: %s",
+			m.StaticConfig.ListOutput, strings.Join(output.Names, ", ")))
 	}
-	if err := toolsets.Validate(m.StaticConfig.Toolsets); err != nil {
-		return err
-	}
-	if !m.StaticConfig.RequireOAuth && (m.StaticConfig.ValidateToken ||
+	errs = append(errs, toolsets.Validate(m.StaticConfig.Toolsets))
+	if !m.StaticConfig.RequireOAuth && !m.ExtensionsConfig.OAuthListeners() && (m.StaticConfig.ValidateToken ||
 		m.StaticConfig.OAuthAudience != "" || m.StaticConfig.AuthorizationURL != "" ||
 		m.StaticConfig.ServerURL != "" || m.StaticConfig.CertificateAuthority != "") {
-		return fmt.Errorf("validate-token, oauth-audience, authorization-url, server-url and " +
-			"certificate-authority are only valid if require-oauth is enabled. " +
-			"Missing --port may implicitly set require-oauth to false")
+		errs = append(errs, fmt.Errorf("validate-token, oauth-audience, authorization-url, server-url and "+
+			"certificate-authority are only valid if require-oauth is enabled. "+
+			"Missing --port or --listen may implicitly set require-oauth to false"))
+	}
+	if m.StaticConfig.Port != "" && m.ExtensionsConfig.Listen.Enabled() {
+		errs = append(errs, fmt.Errorf("port and listen are mutually exclusive, the HTTP server listens either on a TCP port or a Unix domain socket"))
+	}
+	if len(m.ExtensionsConfig.Listeners) > 0 && !servesHTTP(m.StaticConfig, m.ExtensionsConfig) {
+		errs = append(errs, fmt.Errorf("listeners are only served in HTTP mode, set --port or --listen"))
 	}
 	if m.StaticConfig.AuthorizationURL != "" {
 		u, err := url.Parse(m.StaticConfig.AuthorizationURL)
-		if err != nil {
-			return err
-		}
-		if u.Scheme != "https" && u.Scheme != "http" {
-			return fmt.Errorf("--authorization-url must be a valid URL")
-		}
-		if u.Scheme == "http" {
+		switch {
+		case err != nil:
+			errs = append(errs, err)
+		case u.Scheme != "https" && u.Scheme != "http":
+			errs = append(errs, fmt.Errorf("--authorization-url must be a valid URL"))
+		case u.Scheme == "http":
 			klog.Warningf("authorization-url is using http://, this is not recommended production use")
 		}
 	}
-	return nil
+	if m.ExtensionsConfig.Impersonation.Enabled && !m.StaticConfig.RequireOAuth &&
+		(!servesHTTP(m.StaticConfig, m.ExtensionsConfig) || !m.ExtensionsConfig.Authentication.Enabled()) {
+		errs = append(errs, fmt.Errorf("impersonation is only valid if require-oauth, API key or mTLS authentication is enabled. "+
+			"Missing --port or --listen may implicitly set require-oauth to false"))
+	}
+	if m.ExtensionsConfig.Impersonation.Enabled && (m.StaticConfig.RequireOAuth || m.ExtensionsConfig.OAuthListeners()) &&
+		m.StaticConfig.AuthorizationURL == "" && !m.StaticConfig.ValidateToken {
+		errs = append(errs, fmt.Errorf("impersonation of the OAuth users requires authorization-url or validate-token, "+
+			"the impersonated identity is read from the claims of the token which must be verified"))
+	}
+	if err := m.ExtensionsConfig.Validate(); err != nil {
+		errs = append(errs, err)
+	} else if _, err := redact.New(m.ExtensionsConfig.Redaction); err != nil {
+		// the json paths are only checked when building the redactor
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
 }
 
 //gocyclo:ignore - Main server startup logic with OAuth, HTTP, and STDIO handling
 func (m *ExtendableMCPServerOptions) Run() error {
 	klog.V(1).Info("Starting extendable-kubernetes-mcp-server")
-	klog.V(1).Infof(" - Config: %s", m.ConfigPath)
+	configFiles, _ := m.configFiles()
+	klog.V(1).Infof(" - Config: %s", strings.Join(configFiles, ", "))
+	klog.V(1).Infof(" - Profile: %s", m.ExtensionsConfig.Profile)
 	klog.V(1).Infof(" - Toolsets: %s", strings.Join(m.StaticConfig.Toolsets, ", "))
 	klog.V(1).Infof(" - ListOutput: %s", m.StaticConfig.ListOutput)
 	klog.V(1).Infof(" - Read-only mode: %t", m.StaticConfig.ReadOnly)
 	klog.V(1).Infof(" - Disable destructive tools: %t", m.StaticConfig.DisableDestructive)
+	klog.V(1).Infof(" - Namespaces: %s", strings.Join(m.ExtensionsConfig.Namespaces, ", "))
+	klog.V(1).Infof(" - Output redaction: %t", m.ExtensionsConfig.Redaction.Enabled)
+	klog.V(1).Infof(" - Impersonation: %t", m.ExtensionsConfig.Impersonation.Enabled)
+	klog.V(1).Infof(" - Rate limiting: %t", m.ExtensionsConfig.RateLimit.Enabled)
+	klog.V(1).Infof(" - TLS: %t", m.ExtensionsConfig.TLS.Enabled())
+	klog.V(1).Infof(" - Listen: %s", m.ExtensionsConfig.Listen.Address)
+	klog.V(1).Infof(" - Shutdown grace period: %s", m.ExtensionsConfig.Shutdown.GracePeriod)
+	klog.V(1).Infof(" - Session resumption: %t", m.ExtensionsConfig.Sessions.Resumption)
+	klog.V(1).Infof(" - Session store: %s", m.ExtensionsConfig.Sessions.Store)
+	klog.V(1).Infof(" - API keys: %d, mTLS authentication: %t", len(m.ExtensionsConfig.Authentication.APIKeys), m.ExtensionsConfig.Authentication.MTLS.Enabled)
 
 	strategy := m.StaticConfig.ClusterProviderStrategy
 	if strategy == "" {
@@ -307,54 +598,316 @@ func (m *ExtendableMCPServerOptions) Run() error {
 		return nil
 	}
 
-	var oidcProvider *oidc.Provider
-	var httpClient *http.Client
-	if m.StaticConfig.AuthorizationURL != "" {
-		ctx := context.Background()
-		if m.StaticConfig.CertificateAuthority != "" {
-			httpClient = &http.Client{}
-			caCert, err := os.ReadFile(m.StaticConfig.CertificateAuthority)
+	oidcProvider, httpClient, err := m.newOIDCProvider(context.Background())
+	if err != nil {
+		return err
+	}
+
+	mcpServer, err := localmcp.NewServer(localmcp.Configuration{
+		StaticConfig: m.StaticConfig,
+		Extensions:   m.ExtensionsConfig,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize MCP server: %w", err)
+	}
+	defer mcpServer.Close()
+
+	endpointServers := make(map[string]*localmcp.Server)
+	endpoints := make([]localhttp.Endpoint, 0, len(m.endpoints))
+	if servesHTTP(m.StaticConfig, m.ExtensionsConfig) {
+		for _, endpoint := range m.endpoints {
+			klog.V(1).Infof(" - Profile %s served under %s", endpoint.name, endpoint.path)
+			endpointServer, err := localmcp.NewServer(endpoint.configuration())
 			if err != nil {
-				return fmt.Errorf("failed to read CA certificate from %s: %w", m.StaticConfig.CertificateAuthority, err)
-			}
-			caCertPool := x509.NewCertPool()
-			if !caCertPool.AppendCertsFromPEM(caCert) {
-				return fmt.Errorf("failed to append CA certificate from %s to pool", m.StaticConfig.CertificateAuthority)
+				return fmt.Errorf("failed to initialize MCP server for profile %s: %w", endpoint.name, err)
 			}
+			defer endpointServer.Close()
+			endpointServers[endpoint.name] = endpointServer
+			endpoints = append(endpoints, localhttp.Endpoint{Path: endpoint.path, Server: endpointServer})
+		}
+	}
 
-			if caCertPool.Equal(x509.NewCertPool()) {
-				caCertPool = nil
+	listenerServers := make(map[string]*localmcp.Server)
+	listeners := []localhttp.Listener{{
+		Name:         "default",
+		Server:       mcpServer,
+		StaticConfig: m.StaticConfig,
+		Extensions:   m.ExtensionsConfig,
+		Endpoints:    endpoints,
+	}}
+	if servesHTTP(m.StaticConfig, m.ExtensionsConfig) {
+		for _, listener := range m.listeners {
+			klog.V(1).Infof(" - Listener %s on %s (profile: %s, auth: %s, TLS: %t)", listener.config.Name, listener.config.Address,
+				listener.extensionsConfig.Profile, cmp.Or(listener.config.Auth, localconfig.ListenerAuthInherit), listener.config.TLS)
+			listenerServer, err := localmcp.NewServer(listener.configuration())
+			if err != nil {
+				return fmt.Errorf("failed to initialize MCP server for listener %s: %w", listener.config.Name, err)
 			}
+			defer listenerServer.Close()
+			listenerServers[listener.config.Name] = listenerServer
+			listeners = append(listeners, localhttp.Listener{
+				Name:         listener.config.Name,
+				Address:      listener.address(),
+				Server:       listenerServer,
+				StaticConfig: listener.staticConfig,
+				Extensions:   listener.extensionsConfig,
+			})
+		}
+	}
 
-			transport := &http.Transport{
-				TLSClientConfig: &tls.Config{
-					RootCAs:    caCertPool,
-					MinVersion: tls.VersionTLS12,
-				},
-			}
-			httpClient.Transport = transport
-			ctx = oidc.ClientContext(ctx, httpClient)
+	// SIGTERM and SIGINT shut the server down gracefully, a second signal terminates it immediately
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	if err := m.watchConfiguration(ctx, mcpServer, endpointServers, listenerServers); err != nil {
+		return err
+	}
+
+	if servesHTTP(m.StaticConfig, m.ExtensionsConfig) {
+		return localhttp.ServeListeners(ctx, oidcProvider, httpClient, listeners...)
+	}
+
+	return m.serveStdio(ctx, mcpServer)
+}
+
+// serveStdio serves the server over stdio until the input is closed or the context is cancelled,
+// in which case the in-flight tool calls are given the shutdown grace period to complete.
+func (m *ExtendableMCPServerOptions) serveStdio(ctx context.Context, mcpServer *localmcp.Server) error {
+	gracePeriod, err := m.ExtensionsConfig.Shutdown.GracePeriodDuration()
+	if err != nil {
+		return err
+	}
+	serveCtx, cancelServe := context.WithCancel(context.Background())
+	defer cancelServe()
+	go func() {
+		select {
+		case <-serveCtx.Done():
+			return
+		case <-ctx.Done():
 		}
-		provider, err := oidc.NewProvider(ctx, m.StaticConfig.AuthorizationURL)
-		if err != nil {
-			return fmt.Errorf("unable to setup OIDC provider: %w", err)
+		graceCtx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+		defer cancel()
+		if err := mcpServer.Shutdown(graceCtx); err != nil {
+			klog.Warningf("Shutdown grace period expired: %v", err)
 		}
-		oidcProvider = provider
+		cancelServe()
+	}()
+	if err := mcpServer.ServeStdio(serveCtx); err != nil && !errors.Is(err, context.Canceled) {
+		return err
 	}
+	return nil
+}
+
+// servesHTTP returns true if the configuration serves the HTTP transport, on a TCP port or a Unix domain socket,
+// instead of stdio.
+func servesHTTP(staticConfig *config.StaticConfig, extensionsConfig *localconfig.Config) bool {
+	return staticConfig.Port != "" || extensionsConfig.Listen.Enabled()
+}
 
-	mcpServer, err := k8smcp.NewServer(k8smcp.Configuration{StaticConfig: m.StaticConfig})
+// newOIDCProvider discovers the OIDC provider of the authorization URL (nil if not set), returning it with
+// the HTTP client trusting the configured certificate authority (nil if not set).
+func (m *ExtendableMCPServerOptions) newOIDCProvider(ctx context.Context) (*oidc.Provider, *http.Client, error) {
+	if m.StaticConfig.AuthorizationURL == "" {
+		return nil, nil, nil
+	}
+	var httpClient *http.Client
+	if m.StaticConfig.CertificateAuthority != "" {
+		httpClient = &http.Client{}
+		caCert, err := os.ReadFile(m.StaticConfig.CertificateAuthority)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read CA certificate from %s: %w", m.StaticConfig.CertificateAuthority, err)
+		}
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, nil, fmt.Errorf("failed to append CA certificate from %s to pool", m.StaticConfig.CertificateAuthority)
+		}
+
+		if caCertPool.Equal(x509.NewCertPool()) {
+			caCertPool = nil
+		}
+
+		transport := &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs:    caCertPool,
+				MinVersion: tls.VersionTLS12,
+			},
+		}
+		httpClient.Transport = transport
+		ctx = oidc.ClientContext(ctx, httpClient)
+	}
+	provider, err := oidc.NewProvider(ctx, m.StaticConfig.AuthorizationURL)
 	if err != nil {
-		return fmt.Errorf("failed to initialize MCP server: %w", err)
+		return nil, nil, fmt.Errorf("unable to setup OIDC provider: %w", err)
 	}
-	defer mcpServer.Close()
+	return provider, httpClient, nil
+}
 
-	if m.StaticConfig.Port != "" {
-		return internalhttp.Serve(context.Background(), mcpServer, m.StaticConfig, oidcProvider, httpClient)
+// watchConfiguration reloads the configuration when the config file changes or a SIGHUP is received.
+func (m *ExtendableMCPServerOptions) watchConfiguration(ctx context.Context, mcpServer *localmcp.Server,
+	endpointServers, listenerServers map[string]*localmcp.Server) error {
+	var mu sync.Mutex
+	reload := func(reason string) {
+		mu.Lock()
+		defer mu.Unlock()
+		klog.V(0).Infof("Reloading configuration (%s)", reason)
+		if err := m.reloadConfiguration(mcpServer, endpointServers, listenerServers); err != nil {
+			klog.Errorf("Configuration not reloaded: %v", err)
+			return
+		}
+		klog.V(0).Infof("Configuration reloaded")
 	}
+	if len(m.ConfigPaths) > 0 || m.ConfigDir != "" {
+		paths := slices.Clone(m.ConfigPaths)
+		if dropInDir := m.dropInDir(); dropInDir != "" {
+			paths = append(paths, dropInDir)
+		}
+		if err := localconfig.Watch(ctx, paths, func() { reload("config file changed") }); err != nil {
+			return fmt.Errorf("failed to watch config files %s: %w", strings.Join(paths, ", "), err)
+		}
+	}
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(sighup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sighup:
+				reload("SIGHUP")
+			}
+		}
+	}()
+	return nil
+}
 
-	if err := mcpServer.ServeStdio(); err != nil && !errors.Is(err, context.Canceled) {
+// reloadConfiguration reads and validates the configuration again and applies it to the running server.
+// The previous configuration is kept if the new one is invalid or can't be applied.
+func (m *ExtendableMCPServerOptions) reloadConfiguration(mcpServer *localmcp.Server, endpointServers, listenerServers map[string]*localmcp.Server) error {
+	previousStaticConfig, previousExtensionsConfig, previousEndpoints, previousListeners := m.StaticConfig, m.ExtensionsConfig, m.endpoints, m.listeners
+	restore := func() {
+		m.StaticConfig, m.ExtensionsConfig, m.endpoints, m.listeners = previousStaticConfig, previousExtensionsConfig, previousEndpoints, previousListeners
+	}
+	if err := m.loadConfiguration(); err != nil {
+		restore()
 		return err
 	}
-
+	if err := m.Validate(); err != nil {
+		restore()
+		return err
+	}
+	for _, setting := range restartRequiredChanges(previousStaticConfig, previousExtensionsConfig, m.StaticConfig, m.ExtensionsConfig) {
+		klog.Warningf("Changes to %s are not applied until the server is restarted", setting)
+	}
+	if !slices.EqualFunc(previousEndpoints, m.endpoints, func(a, b profileEndpoint) bool { return a.name == b.name && a.path == b.path }) {
+		klog.Warningf("Changes to the profile paths are not applied until the server is restarted")
+	}
+	if !slices.EqualFunc(previousListeners, m.listeners, func(a, b listenerServer) bool { return a.config == b.config }) {
+		klog.Warningf("Changes to the listeners are not applied until the server is restarted")
+	}
+	// the servers already reloaded are reloaded with their previous configuration (if any) if a reload fails
+	var rollbacks []func()
+	reload := func(server *localmcp.Server, configuration, previous localmcp.Configuration) error {
+		if err := server.Reload(configuration); err != nil {
+			for _, rollback := range slices.Backward(rollbacks) {
+				rollback()
+			}
+			restore()
+			return err
+		}
+		if previous.StaticConfig != nil {
+			rollbacks = append(rollbacks, func() {
+				if err := server.Reload(previous); err != nil {
+					klog.Errorf("Failed to restore the previous configuration: %v", err)
+				}
+			})
+		}
+		return nil
+	}
+	if err := reload(mcpServer, localmcp.Configuration{StaticConfig: m.StaticConfig, Extensions: m.ExtensionsConfig},
+		localmcp.Configuration{StaticConfig: previousStaticConfig, Extensions: previousExtensionsConfig}); err != nil {
+		return err
+	}
+	for _, endpoint := range m.endpoints {
+		if endpointServer, ok := endpointServers[endpoint.name]; ok {
+			var previous localmcp.Configuration
+			if index := slices.IndexFunc(previousEndpoints, func(e profileEndpoint) bool { return e.name == endpoint.name }); index >= 0 {
+				previous = previousEndpoints[index].configuration()
+			}
+			if err := reload(endpointServer, endpoint.configuration(), previous); err != nil {
+				return fmt.Errorf("failed to reload profile %s: %w", endpoint.name, err)
+			}
+		}
+	}
+	for _, listener := range m.listeners {
+		if server, ok := listenerServers[listener.config.Name]; ok {
+			var previous localmcp.Configuration
+			if index := slices.IndexFunc(previousListeners, func(l listenerServer) bool { return l.config.Name == listener.config.Name }); index >= 0 {
+				previous = previousListeners[index].configuration()
+			}
+			if err := reload(server, listener.configuration(), previous); err != nil {
+				return fmt.Errorf("failed to reload listener %s: %w", listener.config.Name, err)
+			}
+		}
+	}
 	return nil
 }
+
+// profileEndpoint is a profile served under its own path in HTTP mode.
+type profileEndpoint struct {
+	name             string
+	path             string
+	staticConfig     *config.StaticConfig
+	extensionsConfig *localconfig.Config
+}
+
+func (e *profileEndpoint) configuration() localmcp.Configuration {
+	return localmcp.Configuration{StaticConfig: e.staticConfig, Extensions: e.extensionsConfig}
+}
+
+// listenerServer is an additional HTTP listener in HTTP mode, with the configuration of the server it serves.
+type listenerServer struct {
+	config           localconfig.ListenerConfig
+	staticConfig     *config.StaticConfig
+	extensionsConfig *localconfig.Config
+}
+
+func (l *listenerServer) configuration() localmcp.Configuration {
+	return localmcp.Configuration{StaticConfig: l.staticConfig, Extensions: l.extensionsConfig}
+}
+
+// address returns the TCP address of the listener, empty if it listens on the Unix domain socket of its configuration.
+func (l *listenerServer) address() string {
+	if l.config.SocketPath() != "" {
+		return ""
+	}
+	return l.config.Address
+}
+
+// restartRequiredChanges returns the changed settings that can't be applied to the running server, the changes
+// to the authentication of the clients are rejected by the reload of the servers.
+func restartRequiredChanges(previousStatic *config.StaticConfig, previousExtensions *localconfig.Config,
+	static *config.StaticConfig, extensions *localconfig.Config) []string {
+	settings := []struct {
+		name    string
+		changed bool
+	}{
+		{flagPort, previousStatic.Port != static.Port},
+		{flagSSEBaseUrl, previousStatic.SSEBaseURL != static.SSEBaseURL},
+		{flagLogLevel, previousStatic.LogLevel != static.LogLevel},
+		{"tls", !reflect.DeepEqual(previousExtensions.TLS, extensions.TLS)},
+		{"listen", previousExtensions.Listen != extensions.Listen},
+		{"shutdown", previousExtensions.Shutdown != extensions.Shutdown},
+		{"sessions", previousExtensions.Sessions != extensions.Sessions},
+	}
+	changed := make([]string, 0)
+	for _, setting := range settings {
+		if setting.changed {
+			changed = append(changed, setting.name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/containers/kubernetes-mcp-server/pkg/version"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+	"k8s.io/klog/v2"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	localmcp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/mcp"
+)
+
+var toolsExamples = templates.Examples(`
+# list the tools exposed by the default configuration
+extendable-k8s-mcp tools list
+
+# list the tools of a read-only server with the core toolset as Markdown
+extendable-k8s-mcp tools list --toolsets core --read-only --output markdown
+
+# list the tools exposed by a profile with their input schemas as JSON
+extendable-k8s-mcp tools list --config config.toml --profile prod-readonly --output json
+`)
+
+// Output formats of the tools list subcommand.
+const (
+	toolsOutputTable    = "table"
+	toolsOutputJSON     = "json"
+	toolsOutputMarkdown = "markdown"
+)
+
+func newToolsCommand(o *ExtendableMCPServerOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "tools",
+		Short:   "Inspect the tools exposed by the server",
+		Long:    "Build the server from the configuration (config files, drop-in directory, profile, environment variables and flags) without serving any client.",
+		Example: toolsExamples,
+	}
+	outputFormat := toolsOutputTable
+	list := &cobra.Command{
+		Use:          "list",
+		Short:        "List the tools with their annotations and input schemas",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, _ []string) error {
+			if !slices.Contains([]string{toolsOutputTable, toolsOutputJSON, toolsOutputMarkdown}, outputFormat) {
+				return fmt.Errorf("invalid output format %q, valid formats are: %s, %s, %s",
+					outputFormat, toolsOutputTable, toolsOutputJSON, toolsOutputMarkdown)
+			}
+			ctx := c.Context()
+			server, session, err := o.connectInProcess(ctx, c)
+			if err != nil {
+				return err
+			}
+			defer server.Close()
+			defer func() { _ = session.Close() }()
+			result, err := session.ListTools(ctx, &mcp.ListToolsParams{})
+			if err != nil {
+				return fmt.Errorf("failed to list the tools: %w", err)
+			}
+			switch outputFormat {
+			case toolsOutputJSON:
+				return printToolsJSON(o.Out, result.Tools)
+			case toolsOutputMarkdown:
+				return printToolsMarkdown(o.Out, result.Tools)
+			default:
+				return printToolsTable(o.Out, result.Tools)
+			}
+		},
+	}
+	list.Flags().StringVarP(&outputFormat, "output", "o", outputFormat,
+		"Output format (one of: "+strings.Join([]string{toolsOutputTable, toolsOutputJSON, toolsOutputMarkdown}, ", ")+")")
+	cmd.AddCommand(list)
+	return cmd
+}
+
+// connectInProcess builds the MCP server as Run does and connects an in-memory client session to it.
+// The server logs are discarded so they don't mix with the command output.
+func (m *ExtendableMCPServerOptions) connectInProcess(ctx context.Context, cmd *cobra.Command) (*localmcp.Server, *mcp.ClientSession, error) {
+	return m.connectInProcessTransport(ctx, cmd, func(transport mcp.Transport) mcp.Transport { return transport })
+}
+
+// connectInProcessTransport is connectInProcess with the client transport wrapped by the provided function.
+func (m *ExtendableMCPServerOptions) connectInProcessTransport(ctx context.Context, cmd *cobra.Command,
+	wrap func(mcp.Transport) mcp.Transport) (*localmcp.Server, *mcp.ClientSession, error) {
+	if err := m.completeConfiguration(cmd); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration:\n%s", formatErrors(err))
+	}
+	quietLogging()
+
+	server, err := localmcp.NewServer(localmcp.Configuration{
+		StaticConfig: m.StaticConfig,
+		Extensions:   m.ExtensionsConfig,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize MCP server: %w", err)
+	}
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	if _, err = server.Connect(ctx, serverTransport, nil); err != nil {
+		server.Close()
+		return nil, nil, fmt.Errorf("failed to connect to the MCP server: %w", err)
+	}
+	session, err := newCLIClient().Connect(ctx, wrap(clientTransport), nil)
+	if err != nil {
+		server.Close()
+		return nil, nil, fmt.Errorf("failed to connect to the MCP server: %w", err)
+	}
+	return server, session, nil
+}
+
+// quietLogging discards the server logs so they don't mix with the command output.
+func quietLogging() {
+	flagSet := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(flagSet)
+	_ = flagSet.Parse([]string{"-logtostderr=false", "-alsologtostderr=false", "-stderrthreshold=FATAL"})
+}
+
+// newCLIClient returns the MCP client of the subcommands.
+func newCLIClient() *mcp.Client {
+	return mcp.NewClient(&mcp.Implementation{Name: "extendable-k8s-mcp-cli", Version: version.Version}, nil)
+}
+
+// toolParameter is a property of the tool input schema.
+type toolParameter struct {
+	name        string
+	typ         string
+	required    bool
+	description string
+}
+
+// toolParameters returns the properties of the tool input schema, required ones first.
+func toolParameters(tool *mcp.Tool) []toolParameter {
+	schema, _ := tool.InputSchema.(map[string]any)
+	properties, _ := schema["properties"].(map[string]any)
+	required, _ := schema["required"].([]any)
+	parameters := make([]toolParameter, 0, len(properties))
+	for name, property := range properties {
+		p, _ := property.(map[string]any)
+		parameter := toolParameter{name: name, required: slices.Contains(required, any(name))}
+		parameter.typ, _ = p["type"].(string)
+		parameter.description, _ = p["description"].(string)
+		parameters = append(parameters, parameter)
+	}
+	slices.SortFunc(parameters, func(a, b toolParameter) int {
+		if a.required != b.required {
+			if a.required {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.name, b.name)
+	})
+	return parameters
+}
+
+// toolHints returns the annotation hints set on the tool.
+func toolHints(tool *mcp.Tool) []string {
+	var hints []string
+	if tool.Annotations == nil {
+		return hints
+	}
+	if tool.Annotations.ReadOnlyHint {
+		hints = append(hints, "read-only")
+	}
+	if tool.Annotations.DestructiveHint != nil && *tool.Annotations.DestructiveHint {
+		hints = append(hints, "destructive")
+	}
+	if tool.Annotations.IdempotentHint {
+		hints = append(hints, "idempotent")
+	}
+	if tool.Annotations.OpenWorldHint != nil && *tool.Annotations.OpenWorldHint {
+		hints = append(hints, "open-world")
+	}
+	return hints
+}
+
+func printToolsTable(out io.Writer, tools []*mcp.Tool) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tANNOTATIONS\tARGUMENTS")
+	for _, tool := range tools {
+		arguments := make([]string, 0)
+		for _, parameter := range toolParameters(tool) {
+			if parameter.required {
+				arguments = append(arguments, parameter.name+"*")
+			} else {
+				arguments = append(arguments, parameter.name)
+			}
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", tool.Name, valueOrNone(strings.Join(toolHints(tool), ",")), valueOrNone(strings.Join(arguments, ",")))
+	}
+	return w.Flush()
+}
+
+func printToolsJSON(out io.Writer, tools []*mcp.Tool) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(tools)
+}
+
+func printToolsMarkdown(out io.Writer, tools []*mcp.Tool) error {
+	var b strings.Builder
+	b.WriteString("# Tools\n")
+	for _, tool := range tools {
+		_, _ = fmt.Fprintf(&b, "\n## %s\n\n", tool.Name)
+		if tool.Annotations != nil && tool.Annotations.Title != "" {
+			_, _ = fmt.Fprintf(&b, "**%s**\n\n", tool.Annotations.Title)
+		}
+		_, _ = fmt.Fprintf(&b, "%s\n\n", tool.Description)
+		_, _ = fmt.Fprintf(&b, "Annotations: %s\n", valueOrNone(strings.Join(toolHints(tool), ", ")))
+		parameters := toolParameters(tool)
+		if len(parameters) == 0 {
+			continue
+		}
+		b.WriteString("\n| Argument | Type | Required | Description |\n|---|---|---|---|\n")
+		for _, parameter := range parameters {
+			_, _ = fmt.Fprintf(&b, "| `%s` | %s | %t | %s |\n", parameter.name, parameter.typ, parameter.required, markdownCell(parameter.description))
+		}
+	}
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// markdownCell escapes the text for a Markdown table cell.
+func markdownCell(text string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(text, "|", `\|`), "\n", " ")
+}
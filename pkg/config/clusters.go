@@ -0,0 +1,226 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/containers/kubernetes-mcp-server/pkg/config"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ClusterProviderRegistry is the cluster provider strategy of the cluster registry, whose clusters are defined
+// by the [cluster_provider_configs.registry] section instead of the contexts of a single kubeconfig.
+const ClusterProviderRegistry = "registry"
+
+// DefaultClusterRefreshInterval is the default interval between the reloads of the cluster registry sources.
+const DefaultClusterRefreshInterval = "1m"
+
+// ClusterRegistryConfig is the [cluster_provider_configs.registry] section: the clusters of the registry
+// provider and their labels, read from the config file, from directories of kubeconfig files and from Secrets
+// of a management cluster. A cluster defined by several sources is taken from the first one, in this order.
+type ClusterRegistryConfig struct {
+	// DefaultCluster is the cluster of the tool calls without a cluster argument, the first one by name if empty.
+	DefaultCluster string `toml:"default_cluster,omitempty"`
+	// RefreshInterval is the interval between the reloads of the sources (e.g. 1m), the tools are updated
+	// when the clusters change. 0 disables the reloads.
+	RefreshInterval string `toml:"refresh_interval,omitempty"`
+	// Clusters are the clusters defined in the config file.
+	Clusters []ClusterConfig `toml:"clusters,omitempty"`
+	// KubeconfigDirs are directories of kubeconfig files, a cluster per file named after the file
+	// (without extension) using the current context of the file.
+	KubeconfigDirs []ClusterKubeconfigDirConfig `toml:"kubeconfig_dirs,omitempty"`
+	// Secrets are the Secrets of a management cluster holding the clusters credentials: Cluster API
+	// kubeconfig Secrets, Argo CD cluster Secrets or Secrets with a kubeconfig key.
+	Secrets []ClusterSecretsConfig `toml:"secrets,omitempty"`
+}
+
+// ClusterConfig is a cluster defined in the config file, either by a kubeconfig file or by its API server.
+type ClusterConfig struct {
+	// Name is the name of the cluster, the value of the cluster argument of the tools.
+	Name string `toml:"name"`
+	// Kubeconfig is the kubeconfig file of the cluster.
+	Kubeconfig string `toml:"kubeconfig,omitempty"`
+	// Context is the context of the kubeconfig, the current context if empty.
+	Context string `toml:"context,omitempty"`
+	// Server is the URL of the API server of the cluster, instead of a kubeconfig.
+	Server string `toml:"server,omitempty"`
+	// CertificateAuthority is the CA bundle file verifying the API server.
+	CertificateAuthority string `toml:"certificate_authority,omitempty"`
+	// TokenFile is the file of the bearer token authenticating to the API server (e.g. a service account token).
+	TokenFile string `toml:"token_file,omitempty"`
+	// InsecureSkipTLSVerify skips the verification of the API server certificate.
+	InsecureSkipTLSVerify bool `toml:"insecure_skip_tls_verify,omitempty"`
+	// Labels are the labels of the cluster (e.g. env = "prod").
+	Labels map[string]string `toml:"labels,omitempty"`
+}
+
+// ClusterKubeconfigDirConfig is a directory of kubeconfig files, hidden files are ignored.
+type ClusterKubeconfigDirConfig struct {
+	// Path is the directory (e.g. a mounted Secret or ConfigMap).
+	Path string `toml:"path"`
+	// Labels are added to the labels of the clusters of the directory.
+	Labels map[string]string `toml:"labels,omitempty"`
+}
+
+// ClusterSecretsConfig selects the Secrets of a management cluster defining clusters. The clusters are
+// labeled with the labels of their Secret.
+type ClusterSecretsConfig struct {
+	// Kubeconfig is the kubeconfig file of the management cluster, the kubeconfig (or in-cluster
+	// configuration) of the server if empty.
+	Kubeconfig string `toml:"kubeconfig,omitempty"`
+	// Context is the context of the management cluster kubeconfig, the current context if empty.
+	Context string `toml:"context,omitempty"`
+	// Namespace is the namespace of the Secrets, all the namespaces if empty.
+	Namespace string `toml:"namespace,omitempty"`
+	// LabelSelector selects the Secrets (e.g. argocd.argoproj.io/secret-type=cluster).
+	LabelSelector string `toml:"label_selector,omitempty"`
+	// Labels are added to the labels of the clusters of the Secrets.
+	Labels map[string]string `toml:"labels,omitempty"`
+}
+
+// RefreshIntervalDuration returns the parsed refresh interval, 0 if the sources aren't reloaded.
+func (c *ClusterRegistryConfig) RefreshIntervalDuration() (time.Duration, error) {
+	interval, err := time.ParseDuration(c.RefreshInterval)
+	if err != nil || interval < 0 {
+		return 0, fmt.Errorf("invalid refresh_interval %q, a non negative duration such as 1m is expected", c.RefreshInterval)
+	}
+	return interval, nil
+}
+
+// clusterName matches the valid cluster names, which are used as tool arguments and file names.
+var clusterName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidateClusterName returns an error if the cluster name is invalid.
+func ValidateClusterName(name string) error {
+	if !clusterName.MatchString(name) {
+		return fmt.Errorf("invalid cluster name %q, letters, digits, '.', '_' and '-' are expected", name)
+	}
+	return nil
+}
+
+// ValidateClusterLabels returns an error if a label isn't a valid Kubernetes label, as they are matched
+// by label selectors.
+func ValidateClusterLabels(clusterLabels map[string]string) error {
+	var errs []error
+	for key, value := range clusterLabels {
+		for _, msg := range validation.IsQualifiedName(key) {
+			errs = append(errs, fmt.Errorf("invalid label %q: %s", key, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(value) {
+			errs = append(errs, fmt.Errorf("invalid label %q value %q: %s", key, value, msg))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks the cluster registry configuration for errors, all the errors found are returned joined.
+func (c *ClusterRegistryConfig) Validate() error {
+	var errs []error
+	if _, err := c.RefreshIntervalDuration(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(c.Clusters) == 0 && len(c.KubeconfigDirs) == 0 && len(c.Secrets) == 0 {
+		errs = append(errs, fmt.Errorf("clusters, kubeconfig_dirs or secrets are required"))
+	}
+	names := make(map[string]bool, len(c.Clusters))
+	for _, cluster := range c.Clusters {
+		if err := cluster.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid clusters %q configuration: %w", cluster.Name, err))
+		}
+		if names[cluster.Name] {
+			errs = append(errs, fmt.Errorf("invalid clusters %q configuration: name is already used by another cluster", cluster.Name))
+		}
+		names[cluster.Name] = true
+	}
+	for _, dir := range c.KubeconfigDirs {
+		if dir.Path == "" {
+			errs = append(errs, fmt.Errorf("invalid kubeconfig_dirs configuration: path is required"))
+		}
+		if err := ValidateClusterLabels(dir.Labels); err != nil {
+			errs = append(errs, fmt.Errorf("invalid kubeconfig_dirs %q configuration: %w", dir.Path, err))
+		}
+	}
+	for _, secrets := range c.Secrets {
+		if _, err := labels.Parse(secrets.LabelSelector); err != nil {
+			errs = append(errs, fmt.Errorf("invalid secrets label_selector %q: %w", secrets.LabelSelector, err))
+		}
+		if err := ValidateClusterLabels(secrets.Labels); err != nil {
+			errs = append(errs, fmt.Errorf("invalid secrets configuration: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *ClusterConfig) validate() error {
+	var errs []error
+	if c.Name == "" {
+		errs = append(errs, fmt.Errorf("name is required"))
+	} else if err := ValidateClusterName(c.Name); err != nil {
+		errs = append(errs, err)
+	}
+	switch {
+	case c.Kubeconfig == "" && c.Server == "":
+		errs = append(errs, fmt.Errorf("kubeconfig or server is required"))
+	case c.Kubeconfig != "" && c.Server != "":
+		errs = append(errs, fmt.Errorf("kubeconfig and server are mutually exclusive"))
+	case c.Kubeconfig != "" && (c.CertificateAuthority != "" || c.TokenFile != "" || c.InsecureSkipTLSVerify):
+		errs = append(errs, fmt.Errorf("certificate_authority, token_file and insecure_skip_tls_verify require server"))
+	case c.Server != "" && c.Context != "":
+		errs = append(errs, fmt.Errorf("context requires kubeconfig"))
+	}
+	if err := ValidateClusterLabels(c.Labels); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// resolvePaths makes the relative paths of the configuration relative to the directory of the config file.
+func (c *ClusterRegistryConfig) resolvePaths(dir string) {
+	resolve := func(path *string) {
+		if dir != "" && *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(dir, *path)
+		}
+	}
+	for i := range c.Clusters {
+		resolve(&c.Clusters[i].Kubeconfig)
+		resolve(&c.Clusters[i].CertificateAuthority)
+		resolve(&c.Clusters[i].TokenFile)
+	}
+	for i := range c.KubeconfigDirs {
+		resolve(&c.KubeconfigDirs[i].Path)
+	}
+	for i := range c.Secrets {
+		resolve(&c.Secrets[i].Kubeconfig)
+	}
+}
+
+// parseClusterRegistryConfig decodes the [cluster_provider_configs.registry] section, rejecting unknown settings.
+func parseClusterRegistryConfig(ctx context.Context, primitive toml.Primitive, md toml.MetaData) (config.ProviderConfig, error) {
+	cfg := &ClusterRegistryConfig{RefreshInterval: DefaultClusterRefreshInterval}
+	if err := md.PrimitiveDecode(primitive, cfg); err != nil {
+		return nil, err
+	}
+	prefix := "cluster_provider_configs." + ClusterProviderRegistry + "."
+	var unknown []string
+	for _, key := range md.Undecoded() {
+		if strings.HasPrefix(key.String(), prefix) {
+			unknown = append(unknown, strings.TrimPrefix(key.String(), prefix))
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown settings %s", strings.Join(unknown, ", "))
+	}
+	cfg.resolvePaths(config.ConfigDirPathFromContext(ctx))
+	return cfg, nil
+}
+
+func init() {
+	config.RegisterProviderConfig(ClusterProviderRegistry, parseClusterRegistryConfig)
+}
@@ -0,0 +1,534 @@
+// Package config provides the configuration for the extensions built on top of kubernetes-mcp-server.
+//
+// The extension settings live in the same TOML file as the kubernetes-mcp-server StaticConfig.
+// kubernetes-mcp-server ignores the sections it doesn't know about, so both configurations can
+// be read from a single --config file.
+package config
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Config is the configuration for the server extensions.
+type Config struct {
+	// Redaction configures the masking of sensitive data in tool and resource output.
+	Redaction RedactionConfig `toml:"redaction"`
+	// Impersonation configures the impersonation of the authenticated MCP user on Kubernetes API calls.
+	Impersonation ImpersonationConfig `toml:"impersonation"`
+	// Authentication configures the static API key and mTLS authentication of the HTTP transport.
+	Authentication AuthenticationConfig `toml:"authentication"`
+	// TLS configures the TLS serving of the HTTP transport.
+	TLS TLSConfig `toml:"tls"`
+	// Listen configures the Unix domain socket the HTTP transport listens on instead of the TCP port.
+	Listen ListenConfig `toml:"listen"`
+	// Listeners are the additional HTTP listeners ([[listeners]]), each with its own address, profile,
+	// authentication and base URL.
+	Listeners []ListenerConfig `toml:"listeners,omitempty"`
+	// Shutdown configures the graceful shutdown on SIGTERM and SIGINT.
+	Shutdown ShutdownConfig `toml:"shutdown"`
+	// Sessions configures the sessions of the streamable HTTP transport.
+	Sessions SessionsConfig `toml:"sessions"`
+	// RateLimit configures the rate limits and concurrency quotas per client.
+	RateLimit RateLimitConfig `toml:"rate_limit"`
+	// FanOut configures the read-only tool calls running against the clusters matching a cluster selector.
+	FanOut FanOutConfig `toml:"fan_out"`
+	// ClusterOverrides restricts the tools allowed on each cluster or kubeconfig context ([cluster_overrides.<name>]).
+	ClusterOverrides map[string]ClusterOverrideConfig `toml:"cluster_overrides"`
+	// ToolsetConfigs holds the configuration section of each toolset ([toolset_configs.<name>]), decoded with ToolsetConfig.
+	// The sections can't live under the toolsets key, which holds the list of enabled toolsets.
+	ToolsetConfigs map[string]map[string]any `toml:"toolset_configs"`
+	// Namespaces restricts the tools to these namespaces (all namespaces if empty).
+	Namespaces []string `toml:"namespaces,omitempty"`
+	// Profile is the name of the profile applied to the configuration.
+	Profile string `toml:"profile,omitempty"`
+	// Profiles are the named bundles of settings selectable with Profile ([profiles.<name>]).
+	Profiles map[string]ProfileConfig `toml:"profiles"`
+}
+
+// RedactionConfig configures the output-filtering layer that masks sensitive data
+// before tool call results and resource contents leave the server.
+type RedactionConfig struct {
+	// Enabled turns on the redaction of tool and resource output (default true).
+	Enabled bool `toml:"enabled"`
+	// Replacement is the string that replaces redacted values.
+	Replacement string `toml:"replacement,omitempty"`
+	// SecretData masks the values of Secret data and stringData fields.
+	SecretData bool `toml:"secret_data"`
+	// KubeconfigCredentials masks tokens, passwords and client keys in kubeconfig output.
+	KubeconfigCredentials bool `toml:"kubeconfig_credentials"`
+	// EnvPatterns are regular expressions matched against container env var names,
+	// the value of any matching env var is masked.
+	EnvPatterns []string `toml:"env_patterns,omitempty"`
+	// JSONPaths are additional paths (e.g. $.metadata.annotations.token or
+	// spec.containers[*].args) whose values are masked.
+	JSONPaths []string `toml:"json_paths,omitempty"`
+	// Patterns are regular expressions masked anywhere in the output (e.g. bearer tokens in pod logs).
+	Patterns []string `toml:"patterns,omitempty"`
+}
+
+// ImpersonationConfig configures the impersonation of the authenticated MCP user.
+//
+// When enabled (requires require_oauth), the Kubernetes API calls are performed with the server
+// credentials impersonating the user and groups read from the claims of the validated OAuth token,
+// making Kubernetes RBAC the source of truth for what each user may do.
+type ImpersonationConfig struct {
+	// Enabled turns on the impersonation of the authenticated user.
+	Enabled bool `toml:"enabled"`
+	// UserClaim is the token claim mapped to the impersonated user name (nested claims separated by dots).
+	UserClaim string `toml:"user_claim,omitempty"`
+	// UserPrefix is prepended to the impersonated user name (e.g. oidc:).
+	UserPrefix string `toml:"user_prefix,omitempty"`
+	// GroupsClaim is the token claim mapped to the impersonated groups (nested claims separated by dots).
+	// Optional, if empty no groups are read from the token.
+	GroupsClaim string `toml:"groups_claim,omitempty"`
+	// GroupsPrefix is prepended to each of the impersonated group names.
+	GroupsPrefix string `toml:"groups_prefix,omitempty"`
+	// ExtraGroups are added to the impersonated groups of every user.
+	ExtraGroups []string `toml:"extra_groups,omitempty"`
+}
+
+// AuthenticationConfig configures the authentication alternatives to OAuth for the HTTP transport.
+//
+// If any API key or mTLS is configured, requests must be authenticated by one of them
+// (or by OAuth if require_oauth is enabled).
+type AuthenticationConfig struct {
+	// APIKeys are the static bearer API keys accepted by the server.
+	APIKeys []APIKeyConfig `toml:"api_keys,omitempty"`
+	// MTLS configures the client certificate authentication.
+	MTLS MTLSConfig `toml:"mtls"`
+}
+
+// Enabled returns true if any API key or mTLS authentication is configured.
+func (a *AuthenticationConfig) Enabled() bool {
+	return len(a.APIKeys) > 0 || a.MTLS.Enabled
+}
+
+// APIKeyConfig is a static bearer API key.
+type APIKeyConfig struct {
+	// Name identifies the key in logs.
+	Name string `toml:"name"`
+	// Hash is the hex encoded SHA-256 hash of the key prefixed with the algorithm (sha256:<hex>).
+	Hash string `toml:"hash"`
+	// User is the identity of the key (impersonated if impersonation is enabled), defaults to the name.
+	User string `toml:"user,omitempty"`
+	// Groups are the groups of the key identity.
+	Groups []string `toml:"groups,omitempty"`
+	// Scopes restrict the tools the key may call (read, write, destructive), all tools if empty.
+	Scopes []string `toml:"scopes,omitempty" jsonschema:"enum=read,enum=write,enum=destructive"`
+}
+
+// MTLSConfig configures the client certificate authentication.
+type MTLSConfig struct {
+	// Enabled turns on the client certificate authentication, requires TLS serving.
+	Enabled bool `toml:"enabled"`
+	// ClientCAFile is the path to the PEM encoded CA bundle used to verify the client certificates.
+	ClientCAFile string `toml:"client_ca_file,omitempty"`
+	// UserField is the certificate field mapped to the user (cn, email, uri or dns).
+	UserField string `toml:"user_field,omitempty" jsonschema:"enum=cn,enum=email,enum=uri,enum=dns"`
+	// GroupsField is the certificate subject field mapped to the groups (o, ou or empty for none).
+	GroupsField string `toml:"groups_field,omitempty" jsonschema:"enum=,enum=o,enum=ou"`
+	// Scopes restrict the tools the certificates may call, all tools if empty.
+	Scopes []string `toml:"scopes,omitempty" jsonschema:"enum=read,enum=write,enum=destructive"`
+	// Subjects map certificate subjects to identities, if set only matching certificates are accepted.
+	Subjects []MTLSSubjectConfig `toml:"subjects,omitempty"`
+}
+
+// MTLSSubjectConfig maps the client certificates whose subject (RFC 2253) matches the pattern to an identity.
+type MTLSSubjectConfig struct {
+	// Subject is a regular expression matched against the certificate subject (e.g. ^CN=ci-bot,O=platform$).
+	Subject string `toml:"subject"`
+	// User overrides the user mapped from the certificate.
+	User string `toml:"user,omitempty"`
+	// Groups override the groups mapped from the certificate.
+	Groups []string `toml:"groups,omitempty"`
+	// Scopes override the default mTLS scopes.
+	Scopes []string `toml:"scopes,omitempty" jsonschema:"enum=read,enum=write,enum=destructive"`
+}
+
+// TLSConfig configures the TLS serving of the HTTP transport.
+type TLSConfig struct {
+	// CertFile is the path to the PEM encoded server certificate.
+	CertFile string `toml:"cert_file,omitempty"`
+	// KeyFile is the path to the PEM encoded server private key.
+	KeyFile string `toml:"key_file,omitempty"`
+	// MinVersion is the minimum TLS version accepted by the server (1.2 or 1.3).
+	MinVersion string `toml:"min_version,omitempty"`
+}
+
+// TLSVersion returns the crypto/tls version for the TLS version name (1.2 or 1.3).
+func TLSVersion(name string) (uint16, error) {
+	switch name {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("invalid tls min_version %q, valid versions are: 1.2, 1.3", name)
+	}
+}
+
+// Enabled returns true if a server certificate is configured.
+func (t *TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+// ListenConfig configures the Unix domain socket the HTTP transport listens on, as an alternative to the TCP port.
+type ListenConfig struct {
+	// Address is the Unix domain socket to listen on (unix:///path/to/mcp.sock).
+	Address string `toml:"address,omitempty"`
+	// SocketMode is the octal file mode of the socket (e.g. 0660), restricting the local users that may connect.
+	SocketMode string `toml:"socket_mode,omitempty"`
+}
+
+// ListenSchemeUnix is the scheme of the Unix domain socket listen addresses.
+const ListenSchemeUnix = "unix://"
+
+// Enabled returns true if a listen address is configured.
+func (l *ListenConfig) Enabled() bool {
+	return l.Address != ""
+}
+
+// SocketPath returns the path of the Unix domain socket of the listen address.
+func (l *ListenConfig) SocketPath() string {
+	return strings.TrimPrefix(l.Address, ListenSchemeUnix)
+}
+
+// FileMode returns the file mode of the socket.
+func (l *ListenConfig) FileMode() (os.FileMode, error) {
+	mode, err := strconv.ParseUint(l.SocketMode, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid listen socket_mode %q, an octal file mode such as 0660 is expected", l.SocketMode)
+	}
+	return os.FileMode(mode), nil
+}
+
+func (l *ListenConfig) validate() error {
+	if !l.Enabled() {
+		return nil
+	}
+	if !strings.HasPrefix(l.Address, ListenSchemeUnix) || l.SocketPath() == "" {
+		return fmt.Errorf("invalid listen address %q, expected %s<socket path>", l.Address, ListenSchemeUnix)
+	}
+	_, err := l.FileMode()
+	return err
+}
+
+// ShutdownConfig configures the graceful shutdown on SIGTERM and SIGINT: new sessions and tool calls are
+// refused while the in-flight tool calls complete, then the remaining sessions are closed.
+type ShutdownConfig struct {
+	// GracePeriod is the maximum time given to the in-flight tool calls to complete (e.g. 25s). It should be
+	// shorter than the terminationGracePeriodSeconds of the Kubernetes Pod.
+	GracePeriod string `toml:"grace_period,omitempty"`
+}
+
+// GracePeriodDuration returns the parsed grace period.
+func (s *ShutdownConfig) GracePeriodDuration() (time.Duration, error) {
+	gracePeriod, err := time.ParseDuration(s.GracePeriod)
+	if err != nil || gracePeriod < 0 {
+		return 0, fmt.Errorf("invalid shutdown grace_period %q, a non negative duration such as 25s is expected", s.GracePeriod)
+	}
+	return gracePeriod, nil
+}
+
+// SessionsConfig configures the sessions of the streamable HTTP transport.
+type SessionsConfig struct {
+	// Resumption enables the stateful sessions whose streams are resumed with Last-Event-ID once the connection
+	// dropped, replaying the missed events. Unless the store is shared by the server instances, the clients
+	// must reconnect to the same instance.
+	Resumption bool `toml:"resumption"`
+	// Store is the session store keeping the sessions and their events: memory (the default, local to the
+	// server instance), file (a directory that the server instances may share) or a store registered with
+	// sessions.RegisterStore.
+	Store string `toml:"store,omitempty"`
+	// StoreDir is the directory of the file session store.
+	StoreDir string `toml:"store_dir,omitempty"`
+	// MaxEvents is the maximum number of events kept per session for the replay, the oldest ones are dropped.
+	MaxEvents int `toml:"max_events,omitempty"`
+	// MaxBytes is the maximum size of the events kept per session for the replay, the oldest ones are dropped.
+	MaxBytes int `toml:"max_bytes,omitempty"`
+	// IdleTimeout closes the sessions without requests for longer (e.g. 30m), dropping their events.
+	IdleTimeout string `toml:"idle_timeout,omitempty"`
+}
+
+// IdleTimeoutDuration returns the parsed idle timeout, 0 if the idle sessions are never closed.
+func (s *SessionsConfig) IdleTimeoutDuration() (time.Duration, error) {
+	timeout, err := time.ParseDuration(s.IdleTimeout)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("invalid sessions idle_timeout %q, a non negative duration such as 30m is expected", s.IdleTimeout)
+	}
+	return timeout, nil
+}
+
+func (s *SessionsConfig) validate() error {
+	var errs []error
+	if s.MaxEvents < 1 {
+		errs = append(errs, fmt.Errorf("sessions max_events must be positive"))
+	}
+	if s.MaxBytes < 1 {
+		errs = append(errs, fmt.Errorf("sessions max_bytes must be positive"))
+	}
+	if _, err := s.IdleTimeoutDuration(); err != nil {
+		errs = append(errs, err)
+	}
+	if s.Store == SessionsStoreFile && s.StoreDir == "" {
+		errs = append(errs, fmt.Errorf("sessions store_dir is required by the %s store", SessionsStoreFile))
+	}
+	return errors.Join(errs...)
+}
+
+// Built-in session stores.
+const (
+	SessionsStoreMemory = "memory"
+	SessionsStoreFile   = "file"
+)
+
+// FanOutConfig configures the read-only tool calls targeting a cluster selector of the cluster registry,
+// which run against all the matching clusters concurrently.
+type FanOutConfig struct {
+	// MaxConcurrency is the maximum number of clusters called concurrently by a tool call.
+	MaxConcurrency int `toml:"max_concurrency,omitempty"`
+	// MaxClusters is the maximum number of clusters a selector may match, 0 for no limit.
+	MaxClusters int `toml:"max_clusters,omitempty"`
+	// ClusterTimeout bounds the call of each cluster (e.g. 30s), the clusters not answering in time are
+	// reported as failed. 0 for no timeout.
+	ClusterTimeout string `toml:"cluster_timeout,omitempty"`
+}
+
+// ClusterTimeoutDuration returns the parsed cluster timeout, 0 if the calls of the clusters aren't bounded.
+func (f *FanOutConfig) ClusterTimeoutDuration() (time.Duration, error) {
+	timeout, err := time.ParseDuration(f.ClusterTimeout)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("invalid fan_out cluster_timeout %q, a non negative duration such as 30s is expected", f.ClusterTimeout)
+	}
+	return timeout, nil
+}
+
+func (f *FanOutConfig) validate() error {
+	var errs []error
+	if f.MaxConcurrency < 1 {
+		errs = append(errs, fmt.Errorf("fan_out max_concurrency must be positive"))
+	}
+	if f.MaxClusters < 0 {
+		errs = append(errs, fmt.Errorf("fan_out max_clusters must not be negative"))
+	}
+	if _, err := f.ClusterTimeoutDuration(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// RateLimitConfig configures the rate limits and concurrency quotas applied to each client.
+// Limits set to 0 are not enforced.
+type RateLimitConfig struct {
+	// Enabled turns on the rate limiting.
+	Enabled bool `toml:"enabled"`
+	// KeyBy selects how clients are identified: identity (the authenticated user, falling back
+	// to the client address for unauthenticated clients) or session.
+	KeyBy string `toml:"key_by,omitempty" jsonschema:"enum=identity,enum=session"`
+	// RequestsPerSecond is the sustained rate of MCP requests per client.
+	RequestsPerSecond float64 `toml:"requests_per_second,omitempty"`
+	// Burst is the maximum number of MCP requests per client above the sustained rate, defaults to RequestsPerSecond.
+	Burst int `toml:"burst,omitempty"`
+	// MaxConcurrentToolCalls is the maximum number of tool calls in progress per client.
+	MaxConcurrentToolCalls int `toml:"max_concurrent_tool_calls,omitempty"`
+	// KubernetesAPICallsPerMinute is the maximum number of Kubernetes API calls per client in a sliding minute,
+	// tool calls are rejected once the budget is spent.
+	KubernetesAPICallsPerMinute int `toml:"kubernetes_api_calls_per_minute,omitempty"`
+}
+
+// Rate limit client keys.
+const (
+	RateLimitKeyByIdentity = "identity"
+	RateLimitKeyBySession  = "session"
+)
+
+// DefaultRedactionReplacement is the string used to mask redacted values if none is configured.
+const DefaultRedactionReplacement = "***REDACTED***"
+
+// Default returns the default extension configuration.
+func Default() *Config {
+	return &Config{
+		Redaction: RedactionConfig{
+			Enabled:               true,
+			Replacement:           DefaultRedactionReplacement,
+			SecretData:            true,
+			KubeconfigCredentials: true,
+			EnvPatterns:           []string{"(?i)(password|passwd|secret|token|api_?key|private_?key|credentials?)"},
+		},
+		Impersonation: ImpersonationConfig{
+			UserClaim:   "sub",
+			GroupsClaim: "groups",
+		},
+		TLS: TLSConfig{
+			MinVersion: "1.2",
+		},
+		Listen: ListenConfig{
+			SocketMode: "0660",
+		},
+		Shutdown: ShutdownConfig{
+			GracePeriod: "25s",
+		},
+		Sessions: SessionsConfig{
+			Store:       SessionsStoreMemory,
+			MaxEvents:   1000,
+			MaxBytes:    4 << 20,
+			IdleTimeout: "30m",
+		},
+		RateLimit: RateLimitConfig{
+			KeyBy: RateLimitKeyByIdentity,
+		},
+		FanOut: FanOutConfig{
+			MaxConcurrency: 10,
+			ClusterTimeout: "30s",
+		},
+		Authentication: AuthenticationConfig{
+			MTLS: MTLSConfig{
+				UserField:   "cn",
+				GroupsField: "o",
+			},
+		},
+	}
+}
+
+// Read reads the extension configuration from the toml file.
+func Read(configPath string) (*Config, error) {
+	configData, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	return ReadToml(configData)
+}
+
+// ReadToml reads the extension configuration from the toml data, applying the defaults for any missing values.
+func ReadToml(configData []byte) (*Config, error) {
+	cfg := Default()
+	if _, err := toml.NewDecoder(bytes.NewReader(configData)).Decode(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Redaction.Replacement == "" {
+		cfg.Redaction.Replacement = DefaultRedactionReplacement
+	}
+	return cfg, nil
+}
+
+// Validate checks the extension configuration for errors, all the errors found are returned joined.
+func (c *Config) Validate() error {
+	var errs []error
+	for _, pattern := range c.Redaction.EnvPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("invalid redaction env_patterns entry %q: %w", pattern, err))
+		}
+	}
+	for _, pattern := range c.Redaction.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("invalid redaction patterns entry %q: %w", pattern, err))
+		}
+	}
+	if c.Impersonation.Enabled && c.Impersonation.UserClaim == "" {
+		errs = append(errs, fmt.Errorf("impersonation user_claim is required when impersonation is enabled"))
+	}
+	errs = append(errs, c.Authentication.validate())
+	if c.TLS.Enabled() && (c.TLS.CertFile == "" || c.TLS.KeyFile == "") {
+		errs = append(errs, fmt.Errorf("tls cert_file and key_file must be set together"))
+	}
+	if _, err := TLSVersion(c.TLS.MinVersion); err != nil {
+		errs = append(errs, err)
+	}
+	errs = append(errs, c.Listen.validate(), c.Sessions.validate(), c.RateLimit.validate(), c.FanOut.validate())
+	if _, err := c.Shutdown.GracePeriodDuration(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Authentication.MTLS.Enabled && !c.TLS.Enabled() {
+		errs = append(errs, fmt.Errorf("mtls authentication requires tls cert_file and key_file"))
+	}
+	errs = append(errs, c.validateToolsets(), c.validateProfiles(), c.validateListeners(), c.validateClusterOverrides())
+	return errors.Join(errs...)
+}
+
+// Scopes of the tools that API keys and client certificates may call.
+const (
+	ScopeRead        = "read"
+	ScopeWrite       = "write"
+	ScopeDestructive = "destructive"
+)
+
+func (a *AuthenticationConfig) validate() error {
+	names := make(map[string]bool, len(a.APIKeys))
+	for _, key := range a.APIKeys {
+		if key.Name == "" {
+			return fmt.Errorf("authentication api_keys entries require a name")
+		}
+		if names[key.Name] {
+			return fmt.Errorf("duplicate authentication api_keys name %q", key.Name)
+		}
+		names[key.Name] = true
+		algorithm, hash, _ := strings.Cut(key.Hash, ":")
+		if algorithm != "sha256" || !sha256HexRegexp.MatchString(hash) {
+			return fmt.Errorf("invalid hash for authentication api key %q, expected sha256:<hex encoded SHA-256>", key.Name)
+		}
+		if err := validateScopes(key.Scopes); err != nil {
+			return fmt.Errorf("invalid scopes for authentication api key %q: %w", key.Name, err)
+		}
+	}
+	if !a.MTLS.Enabled {
+		return nil
+	}
+	if a.MTLS.ClientCAFile == "" {
+		return fmt.Errorf("authentication mtls client_ca_file is required when mtls is enabled")
+	}
+	switch a.MTLS.UserField {
+	case "cn", "email", "uri", "dns":
+	default:
+		return fmt.Errorf("invalid authentication mtls user_field %q, valid fields are: cn, email, uri, dns", a.MTLS.UserField)
+	}
+	switch a.MTLS.GroupsField {
+	case "", "o", "ou":
+	default:
+		return fmt.Errorf("invalid authentication mtls groups_field %q, valid fields are: o, ou", a.MTLS.GroupsField)
+	}
+	if err := validateScopes(a.MTLS.Scopes); err != nil {
+		return fmt.Errorf("invalid authentication mtls scopes: %w", err)
+	}
+	for _, subject := range a.MTLS.Subjects {
+		if _, err := regexp.Compile(subject.Subject); err != nil {
+			return fmt.Errorf("invalid authentication mtls subject %q: %w", subject.Subject, err)
+		}
+		if err := validateScopes(subject.Scopes); err != nil {
+			return fmt.Errorf("invalid scopes for authentication mtls subject %q: %w", subject.Subject, err)
+		}
+	}
+	return nil
+}
+
+func (r *RateLimitConfig) validate() error {
+	if r.KeyBy != RateLimitKeyByIdentity && r.KeyBy != RateLimitKeyBySession {
+		return fmt.Errorf("invalid rate_limit key_by %q, valid values are: %s, %s", r.KeyBy, RateLimitKeyByIdentity, RateLimitKeyBySession)
+	}
+	if r.RequestsPerSecond < 0 || r.Burst < 0 || r.MaxConcurrentToolCalls < 0 || r.KubernetesAPICallsPerMinute < 0 {
+		return fmt.Errorf("rate_limit limits must not be negative")
+	}
+	return nil
+}
+
+var sha256HexRegexp = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+func validateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		switch scope {
+		case ScopeRead, ScopeWrite, ScopeDestructive:
+		default:
+			return fmt.Errorf("unknown scope %q, valid scopes are: %s, %s, %s", scope, ScopeRead, ScopeWrite, ScopeDestructive)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// DropInDir is the name of the drop-in directory looked up next to the first config file.
+const DropInDir = "config.d"
+
+// DropInFiles returns the toml files of the drop-in directory in lexical order, or none if the directory doesn't exist.
+func DropInFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config drop-in directory %s: %w", dir, err)
+	}
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		// Skip hidden files, such as the ..data entries of Kubernetes ConfigMap volumes
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || filepath.Ext(entry.Name()) != ".toml" {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// Merge reads the toml files and merges them in order into a single toml document.
+//
+// Tables are merged recursively, any other value (including arrays) set in a later file replaces
+// the value of the earlier files.
+func Merge(paths ...string) ([]byte, error) {
+	merged := make(map[string]any)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		document := make(map[string]any)
+		if _, err := toml.Decode(string(data), &document); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		mergeTables(merged, document)
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(merged); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func mergeTables(dst, src map[string]any) {
+	for key, value := range src {
+		srcTable, srcIsTable := value.(map[string]any)
+		dstTable, dstIsTable := dst[key].(map[string]any)
+		if srcIsTable && dstIsTable {
+			mergeTables(dstTable, srcTable)
+			continue
+		}
+		dst[key] = value
+	}
+}
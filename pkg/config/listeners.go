@@ -0,0 +1,157 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/containers/kubernetes-mcp-server/pkg/config"
+)
+
+// ListenerConfig is an additional HTTP listener ([[listeners]]) serving the server, or one of its profiles, with
+// its own address, authentication and base URL. For instance, a loopback listener without authentication for the
+// administrators next to the public one requiring OAuth.
+type ListenerConfig struct {
+	// Name identifies the listener in the logs.
+	Name string `toml:"name"`
+	// Address is the TCP address (e.g. 127.0.0.1:8081) or the Unix domain socket (unix:///path/to/mcp.sock)
+	// the listener listens on. The socket has the mode of the listen configuration.
+	Address string `toml:"address"`
+	// Profile is the profile served by the listener (toolsets, tool filters, read-only mode, ...),
+	// the listener serves the server configuration if empty.
+	Profile string `toml:"profile,omitempty"`
+	// Auth is the authentication of the listener: inherit (the default, as the server), none (the tools use the
+	// server credentials, without impersonation), api_keys (the API keys and client certificates of the
+	// authentication configuration) or oauth (require_oauth).
+	Auth string `toml:"auth,omitempty" jsonschema:"enum=inherit,enum=none,enum=api_keys,enum=oauth"`
+	// BaseURL is the public URL of the listener (e.g. https://mcp.example.com), used as the SSE base URL
+	// and the server URL of the OAuth protected resource metadata.
+	BaseURL string `toml:"base_url,omitempty"`
+	// TLS serves the listener with the certificate of the tls configuration, required by the mTLS authentication.
+	TLS bool `toml:"tls"`
+}
+
+// Authentication modes of the listeners.
+const (
+	ListenerAuthInherit = "inherit"
+	ListenerAuthNone    = "none"
+	ListenerAuthAPIKeys = "api_keys"
+	ListenerAuthOAuth   = "oauth"
+)
+
+// SocketPath returns the path of the Unix domain socket of the listener, empty for a TCP address.
+func (l *ListenerConfig) SocketPath() string {
+	if !strings.HasPrefix(l.Address, ListenSchemeUnix) {
+		return ""
+	}
+	return strings.TrimPrefix(l.Address, ListenSchemeUnix)
+}
+
+// OAuthListeners returns true if a listener requires OAuth.
+func (c *Config) OAuthListeners() bool {
+	return slices.ContainsFunc(c.Listeners, func(l ListenerConfig) bool { return l.Auth == ListenerAuthOAuth })
+}
+
+// ApplyListener returns copies of the configurations of the server (with the profile of the listener already
+// applied) with the authentication, TLS, address and base URL of the named listener.
+func (c *Config) ApplyListener(name string, staticConfig *config.StaticConfig) (*config.StaticConfig, *Config, error) {
+	index := slices.IndexFunc(c.Listeners, func(l ListenerConfig) bool { return l.Name == name })
+	if index < 0 {
+		return nil, nil, fmt.Errorf("unknown listener %q", name)
+	}
+	listener := c.Listeners[index]
+	static, extensions := *staticConfig, *c
+	static.Port = ""
+	extensions.Listen.Address = ""
+	if listener.SocketPath() != "" {
+		extensions.Listen.Address = listener.Address
+	}
+	switch listener.Auth {
+	case ListenerAuthNone:
+		static.RequireOAuth = false
+		extensions.Authentication.APIKeys = nil
+		extensions.Authentication.MTLS.Enabled = false
+		extensions.Impersonation.Enabled = false
+	case ListenerAuthAPIKeys:
+		static.RequireOAuth = false
+	case ListenerAuthOAuth:
+		static.RequireOAuth = true
+		extensions.Authentication.APIKeys = nil
+		extensions.Authentication.MTLS.Enabled = false
+	}
+	if !listener.TLS {
+		extensions.TLS.CertFile, extensions.TLS.KeyFile = "", ""
+		extensions.Authentication.MTLS.Enabled = false
+	}
+	if listener.BaseURL != "" {
+		static.SSEBaseURL = listener.BaseURL
+		static.ServerURL = listener.BaseURL
+	}
+	return &static, &extensions, nil
+}
+
+func (c *Config) validateListeners() error {
+	var errs []error
+	names := make(map[string]bool, len(c.Listeners))
+	addresses := make(map[string]bool, len(c.Listeners))
+	for _, listener := range c.Listeners {
+		if err := c.validateListener(listener, names, addresses); err != nil {
+			errs = append(errs, fmt.Errorf("invalid listeners %q configuration: %w", listener.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateListener checks the listener for errors, names and addresses hold the ones already in use.
+func (c *Config) validateListener(listener ListenerConfig, names, addresses map[string]bool) error {
+	var errs []error
+	switch {
+	case listener.Name == "":
+		errs = append(errs, fmt.Errorf("name is required"))
+	case names[listener.Name]:
+		errs = append(errs, fmt.Errorf("name is already used by another listener"))
+	}
+	names[listener.Name] = true
+	switch {
+	case listener.Address == "":
+		errs = append(errs, fmt.Errorf("address is required"))
+	case strings.HasPrefix(listener.Address, ListenSchemeUnix):
+		if listener.SocketPath() == "" {
+			errs = append(errs, fmt.Errorf("invalid address %q, expected %s<socket path>", listener.Address, ListenSchemeUnix))
+		}
+	default:
+		if _, _, err := net.SplitHostPort(listener.Address); err != nil {
+			errs = append(errs, fmt.Errorf("invalid address %q, expected host:port or %s<socket path>", listener.Address, ListenSchemeUnix))
+		}
+	}
+	if listener.Address != "" && addresses[listener.Address] {
+		errs = append(errs, fmt.Errorf("address %q is already used by another listener", listener.Address))
+	}
+	addresses[listener.Address] = true
+	if listener.Profile != "" {
+		if _, ok := c.Profiles[listener.Profile]; !ok {
+			errs = append(errs, fmt.Errorf("unknown profile %q, valid profiles are: %s", listener.Profile, strings.Join(c.profileNames(), ", ")))
+		}
+	}
+	switch listener.Auth {
+	case "", ListenerAuthInherit, ListenerAuthNone, ListenerAuthOAuth:
+	case ListenerAuthAPIKeys:
+		if !c.Authentication.Enabled() {
+			errs = append(errs, fmt.Errorf("auth %s requires API keys or mTLS in the authentication configuration", ListenerAuthAPIKeys))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid auth %q, valid modes are: %s", listener.Auth,
+			strings.Join([]string{ListenerAuthInherit, ListenerAuthNone, ListenerAuthAPIKeys, ListenerAuthOAuth}, ", ")))
+	}
+	if listener.TLS && !c.TLS.Enabled() {
+		errs = append(errs, fmt.Errorf("tls requires the tls cert_file and key_file"))
+	}
+	if !listener.TLS && c.Authentication.MTLS.Enabled && len(c.Authentication.APIKeys) == 0 &&
+		(listener.Auth == "" || listener.Auth == ListenerAuthInherit || listener.Auth == ListenerAuthAPIKeys) {
+		// the listener would silently accept the clients without certificate
+		errs = append(errs, fmt.Errorf("tls is required by the mtls authentication"))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/toolsets"
+)
+
+// ClusterOverrideConfig restricts the tools allowed on a cluster (a cluster of the registry or a kubeconfig
+// context), on top of the server settings. For instance, writes allowed on the dev clusters while the prod
+// clusters are read-only, in one server. The overrides can only restrict the tools, never allow more.
+type ClusterOverrideConfig struct {
+	// ReadOnly only allows the read-only tools on the cluster.
+	ReadOnly bool `toml:"read_only,omitempty"`
+	// DisableDestructive disallows the destructive tools on the cluster.
+	DisableDestructive bool `toml:"disable_destructive,omitempty"`
+	// Toolsets only allows the tools of these toolsets on the cluster (all the enabled toolsets if not set).
+	Toolsets []string `toml:"toolsets,omitempty"`
+	// EnabledTools only allows these tools on the cluster (all the enabled tools if not set).
+	EnabledTools []string `toml:"enabled_tools,omitempty"`
+	// DisabledTools disallows these tools on the cluster.
+	DisabledTools []string `toml:"disabled_tools,omitempty"`
+}
+
+func (c *Config) validateClusterOverrides() error {
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(c.ClusterOverrides)) {
+		if name == "" {
+			errs = append(errs, fmt.Errorf("cluster_overrides names must not be empty"))
+			continue
+		}
+		for _, toolset := range c.ClusterOverrides[name].Toolsets {
+			if toolsets.ToolsetFromString(toolset) == nil {
+				errs = append(errs, fmt.Errorf("invalid cluster_overrides.%s configuration: invalid toolset name %s", name, toolset))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
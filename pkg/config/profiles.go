@@ -0,0 +1,137 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/containers/kubernetes-mcp-server/pkg/config"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/toolsets"
+)
+
+// ProfileConfig is a named bundle of settings applied on top of the configuration.
+// Settings that aren't set in the profile keep their configured value.
+type ProfileConfig struct {
+	// Toolsets replaces the enabled toolsets.
+	Toolsets []string `toml:"toolsets,omitempty"`
+	// EnabledTools replaces the tools allowlist.
+	EnabledTools []string `toml:"enabled_tools,omitempty"`
+	// DisabledTools replaces the tools denylist.
+	DisabledTools []string `toml:"disabled_tools,omitempty"`
+	// ReadOnly overrides the read-only mode.
+	ReadOnly *bool `toml:"read_only,omitempty"`
+	// DisableDestructive overrides the disabling of destructive tools.
+	DisableDestructive *bool `toml:"disable_destructive,omitempty"`
+	// Namespaces replaces the namespaces the tools are restricted to.
+	Namespaces []string `toml:"namespaces,omitempty"`
+	// ClusterProviderStrategy overrides the cluster provider strategy.
+	ClusterProviderStrategy string `toml:"cluster_provider_strategy,omitempty"`
+	// Path serves the profile in HTTP mode under this path prefix (e.g. /readonly/mcp and /readonly/sse),
+	// in addition to the endpoints of the server profile.
+	Path string `toml:"path,omitempty"`
+}
+
+// ApplyProfile returns copies of the configurations with the named profile applied.
+func (c *Config) ApplyProfile(name string, staticConfig *config.StaticConfig) (*config.StaticConfig, *Config, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown profile %q, valid profiles are: %s", name, strings.Join(c.profileNames(), ", "))
+	}
+	static, extensions := *staticConfig, *c
+	extensions.Profile = name
+	if profile.Toolsets != nil {
+		static.Toolsets = profile.Toolsets
+	}
+	if profile.EnabledTools != nil {
+		static.EnabledTools = profile.EnabledTools
+	}
+	if profile.DisabledTools != nil {
+		static.DisabledTools = profile.DisabledTools
+	}
+	if profile.ReadOnly != nil {
+		static.ReadOnly = *profile.ReadOnly
+	}
+	if profile.DisableDestructive != nil {
+		static.DisableDestructive = *profile.DisableDestructive
+	}
+	if profile.Namespaces != nil {
+		extensions.Namespaces = profile.Namespaces
+	}
+	if profile.ClusterProviderStrategy != "" {
+		static.ClusterProviderStrategy = profile.ClusterProviderStrategy
+	}
+	return &static, &extensions, nil
+}
+
+// EndpointProfiles returns the names of the profiles served under their own path, sorted by name.
+func (c *Config) EndpointProfiles() []string {
+	names := make([]string, 0)
+	for _, name := range c.profileNames() {
+		if c.Profiles[name].Path != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (c *Config) profileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// reservedPaths are the endpoints of the server that profile paths must not shadow.
+var reservedPaths = []string{"/mcp", "/sse", "/message", "/ws", "/healthz", "/livez", "/readyz", "/.well-known"}
+
+func (c *Config) validateProfiles() error {
+	var errs []error
+	if c.Profile != "" {
+		if _, ok := c.Profiles[c.Profile]; !ok {
+			errs = append(errs, fmt.Errorf("unknown profile %q, valid profiles are: %s", c.Profile, strings.Join(c.profileNames(), ", ")))
+		}
+	}
+	if slices.Contains(c.Namespaces, "") {
+		errs = append(errs, fmt.Errorf("namespaces entries must not be empty"))
+	}
+	paths := make(map[string]string)
+	for _, name := range c.profileNames() {
+		if err := c.validateProfile(name, paths); err != nil {
+			errs = append(errs, fmt.Errorf("invalid profiles.%s configuration: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateProfile checks the profile for errors, paths holds the profile name of the paths already in use.
+func (c *Config) validateProfile(name string, paths map[string]string) error {
+	profile := c.Profiles[name]
+	if slices.Contains(profile.Namespaces, "") {
+		return fmt.Errorf("namespaces entries must not be empty")
+	}
+	for _, toolset := range profile.Toolsets {
+		if toolsets.ToolsetFromString(toolset) == nil {
+			return fmt.Errorf("invalid toolset name %s", toolset)
+		}
+	}
+	if profile.Path == "" {
+		return nil
+	}
+	if !strings.HasPrefix(profile.Path, "/") || strings.HasSuffix(profile.Path, "/") {
+		return fmt.Errorf("path %q must start and must not end with a slash", profile.Path)
+	}
+	if slices.ContainsFunc(reservedPaths, func(reserved string) bool {
+		return profile.Path == reserved || strings.HasPrefix(profile.Path, reserved+"/")
+	}) {
+		return fmt.Errorf("path %q conflicts with the server endpoints", profile.Path)
+	}
+	if other, ok := paths[profile.Path]; ok {
+		return fmt.Errorf("path %q is already used by profile %s", profile.Path, other)
+	}
+	paths[profile.Path] = name
+	return nil
+}
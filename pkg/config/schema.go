@@ -0,0 +1,157 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+
+	"github.com/BurntSushi/toml"
+	staticconfig "github.com/containers/kubernetes-mcp-server/pkg/config"
+	internalk8s "github.com/containers/kubernetes-mcp-server/pkg/kubernetes"
+	"github.com/containers/kubernetes-mcp-server/pkg/output"
+	k8stoolsets "github.com/containers/kubernetes-mcp-server/pkg/toolsets"
+	"github.com/invopop/jsonschema"
+
+	localapi "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/api"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/toolsets"
+)
+
+// SchemaID is the identifier of the published configuration schema.
+const SchemaID = "https://raw.githubusercontent.com/friedrichwilken/extendable-kubernetes-mcp-server/main/config.schema.json"
+
+// Schema returns the JSON Schema of the config file, covering both the kubernetes-mcp-server
+// settings and the extension settings, including the sections of the registered configurable toolsets.
+func Schema() ([]byte, error) {
+	reflector := &jsonschema.Reflector{
+		FieldNameTag:               "toml",
+		RequiredFromJSONSchemaTags: true,
+		DoNotReference:             true,
+		Mapper: func(t reflect.Type) *jsonschema.Schema {
+			if t == reflect.TypeOf(toml.Primitive{}) {
+				// decoded by the cluster provider of each strategy
+				return &jsonschema.Schema{Type: "object"}
+			}
+			return nil
+		},
+	}
+	schema := reflector.Reflect(&Config{})
+	static := reflector.Reflect(&staticconfig.StaticConfig{})
+	for pair := static.Properties.Oldest(); pair != nil; pair = pair.Next() {
+		schema.Properties.Set(pair.Key, pair.Value)
+	}
+	schema.ID = SchemaID
+	schema.Title = "Extendable Kubernetes MCP Server configuration"
+	schema.Description = "The TOML configuration file consumed by --config"
+
+	setEnum(schema, "toolsets", k8stoolsets.ToolsetNames())
+	setEnum(schema, "list_output", outputNames())
+	setEnum(schema, "cluster_provider_strategy", internalk8s.GetRegisteredStrategies())
+	if profiles, ok := schema.Properties.Get("profiles"); ok {
+		setEnum(profiles.AdditionalProperties, "toolsets", k8stoolsets.ToolsetNames())
+		setEnum(profiles.AdditionalProperties, "cluster_provider_strategy", internalk8s.GetRegisteredStrategies())
+	}
+	if overrides, ok := schema.Properties.Get("cluster_overrides"); ok {
+		setEnum(overrides.AdditionalProperties, "toolsets", k8stoolsets.ToolsetNames())
+	}
+	toolsetConfigs, err := toolsetConfigsSchema(reflector)
+	if err != nil {
+		return nil, err
+	}
+	schema.Properties.Set("toolset_configs", toolsetConfigs)
+	if providerConfigs, ok := schema.Properties.Get("cluster_provider_configs"); ok {
+		registry := reflector.Reflect(&ClusterRegistryConfig{})
+		registry.Version, registry.ID = "", ""
+		registry.Description = "The clusters of the registry cluster provider strategy"
+		setDefaults(registry, map[string]any{"refresh_interval": DefaultClusterRefreshInterval})
+		providerConfigs.Properties = jsonschema.NewProperties()
+		providerConfigs.Properties.Set(ClusterProviderRegistry, registry)
+	}
+
+	defaults, err := tomlValues(Default(), staticconfig.Default())
+	if err != nil {
+		return nil, err
+	}
+	setDefaults(schema, defaults)
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// toolsetConfigsSchema returns the schema of the toolset_configs table, a section for each configurable toolset.
+func toolsetConfigsSchema(reflector *jsonschema.Reflector) (*jsonschema.Schema, error) {
+	schema := &jsonschema.Schema{
+		Type:                 "object",
+		Properties:           jsonschema.NewProperties(),
+		AdditionalProperties: jsonschema.FalseSchema,
+	}
+	for _, name := range k8stoolsets.ToolsetNames() {
+		toolset, ok := toolsets.ToolsetFromString(name).(localapi.ConfigurableToolset)
+		if !ok {
+			continue
+		}
+		cfg := toolset.DefaultConfig()
+		section := reflector.Reflect(cfg)
+		section.Version, section.ID = "", ""
+		section.Description = toolset.GetDescription()
+		defaults, err := tomlValues(cfg)
+		if err != nil {
+			return nil, err
+		}
+		setDefaults(section, defaults)
+		schema.Properties.Set(name, section)
+	}
+	return schema, nil
+}
+
+func setEnum(schema *jsonschema.Schema, property string, values []string) {
+	p, ok := schema.Properties.Get(property)
+	if !ok {
+		return
+	}
+	if p.Items != nil {
+		p = p.Items
+	}
+	p.Enum = make([]any, 0, len(values))
+	for _, value := range values {
+		p.Enum = append(p.Enum, value)
+	}
+}
+
+// setDefaults sets the default of the schema properties to the provided values, tables are set recursively.
+func setDefaults(schema *jsonschema.Schema, values map[string]any) {
+	if schema.Properties == nil {
+		return
+	}
+	for key, value := range values {
+		p, ok := schema.Properties.Get(key)
+		if !ok {
+			continue
+		}
+		if table, ok := value.(map[string]any); ok {
+			setDefaults(p, table)
+			continue
+		}
+		p.Default = value
+	}
+}
+
+// tomlValues returns the settings of the configurations as they are written in the config file.
+func tomlValues(configs ...any) (map[string]any, error) {
+	values := make(map[string]any)
+	for _, cfg := range configs {
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(cfg); err != nil {
+			return nil, err
+		}
+		if _, err := toml.NewDecoder(&buf).Decode(&values); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func outputNames() []string {
+	names := make([]string, 0, len(output.Outputs))
+	for _, o := range output.Outputs {
+		names = append(names, o.GetName())
+	}
+	return names
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	localapi "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/api"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/toolsets"
+)
+
+// ToolsetConfig returns the configuration of the toolset: its defaults overridden by the
+// [toolset_configs.<name>] section of the config file, validated.
+func (c *Config) ToolsetConfig(toolset localapi.ConfigurableToolset) (localapi.ToolsetConfig, error) {
+	cfg := toolset.DefaultConfig()
+	if section, ok := c.ToolsetConfigs[toolset.GetName()]; ok {
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(section); err != nil {
+			return nil, fmt.Errorf("invalid toolset_configs.%s configuration: %w", toolset.GetName(), err)
+		}
+		md, err := toml.NewDecoder(&buf).Decode(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid toolset_configs.%s configuration: %w", toolset.GetName(), err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			keys := make([]string, 0, len(undecoded))
+			for _, key := range undecoded {
+				keys = append(keys, key.String())
+			}
+			return nil, fmt.Errorf("invalid toolset_configs.%s configuration: unknown settings %s", toolset.GetName(), strings.Join(keys, ", "))
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid toolset_configs.%s configuration: %w", toolset.GetName(), err)
+	}
+	return cfg, nil
+}
+
+func (c *Config) validateToolsets() error {
+	names := make([]string, 0, len(c.ToolsetConfigs))
+	for name := range c.ToolsetConfigs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var errs []error
+	for _, name := range names {
+		toolset := toolsets.ToolsetFromString(name)
+		if toolset == nil {
+			errs = append(errs, fmt.Errorf("invalid toolset_configs.%s configuration: unknown toolset", name))
+			continue
+		}
+		configurable, ok := toolset.(localapi.ConfigurableToolset)
+		if !ok {
+			errs = append(errs, fmt.Errorf("invalid toolset_configs.%s configuration: the toolset has no settings", name))
+			continue
+		}
+		if _, err := c.ToolsetConfig(configurable); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/klog/v2"
+)
+
+// watchDebounce is the time to wait for further changes before notifying a configuration change,
+// editors and ConfigMap volume updates usually produce several events for a single change.
+const watchDebounce = 250 * time.Millisecond
+
+// Watch calls onChange whenever any of the configuration files or drop-in directories changes, until
+// the context is cancelled.
+//
+// The parent directories of the files are watched so that atomic replacements (e.g. editor renames or Kubernetes
+// ConfigMap volume updates, which swap a symlink) are detected.
+func Watch(ctx context.Context, paths []string, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	dirs := make([]string, 0, len(paths))
+	names := make([]string, 0, len(paths))
+	dropInDirs := make([]string, 0)
+	for _, path := range paths {
+		dir := filepath.Dir(path)
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			dir = filepath.Clean(path)
+			dropInDirs = append(dropInDirs, dir)
+		} else {
+			names = append(names, filepath.Base(path))
+		}
+		if slices.Contains(dirs, dir) {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			_ = watcher.Close()
+			return err
+		}
+		dirs = append(dirs, dir)
+	}
+	go func() {
+		defer func() { _ = watcher.Close() }()
+		debounce := time.NewTimer(watchDebounce)
+		debounce.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				debounce.Stop()
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				base := filepath.Base(event.Name)
+				inDropInDir := slices.Contains(dropInDirs, filepath.Dir(event.Name)) && filepath.Ext(base) == ".toml"
+				// Kubernetes ConfigMap volumes update the files through the ..data symlink
+				if inDropInDir || slices.Contains(names, base) || strings.HasPrefix(base, "..") {
+					debounce.Reset(watchDebounce)
+				}
+			case <-debounce.C:
+				onChange()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				klog.Warningf("Config watch error: %v", err)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/containers/kubernetes-mcp-server/pkg/config"
+	internalhttp "github.com/containers/kubernetes-mcp-server/pkg/http"
+	"k8s.io/klog/v2"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/auth"
+)
+
+// AuthenticationMiddleware authenticates the requests with the static API keys and client certificates.
+//
+// Requests authenticated by the authenticator skip the OAuth flow, the Principal is set in the request
+// context (and the API key removed so that it's never used as a Kubernetes bearer token).
+// The remaining requests are passed to the oauth handler if require-oauth is enabled, or rejected otherwise.
+func AuthenticationMiddleware(authenticator *auth.Authenticator, staticConfig *config.StaticConfig, oauth http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slices.Contains(probeEndpoints, r.URL.Path) {
+				// the probes are served to anyone, their details only to the authenticated principals
+				if authenticator != nil {
+					if principal, err := authenticator.Authenticate(r); err == nil && principal != nil {
+						r = auth.WithPrincipal(r, principal)
+					}
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			r = auth.WithRemoteAddress(r)
+			if authenticator == nil || r.URL.Path == healthEndpoint || slices.Contains(internalhttp.WellKnownEndpoints, r.URL.EscapedPath()) {
+				oauth.ServeHTTP(w, r)
+				return
+			}
+			principal, err := authenticator.Authenticate(r)
+			if err != nil {
+				klog.V(1).Infof("Authentication failed: %s %s from %s, error: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+				http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+				return
+			}
+			if principal != nil {
+				klog.V(2).Infof("Authenticated %s: %s %s from %s", principal, r.Method, r.URL.Path, r.RemoteAddr)
+				if principal.Method == auth.MethodAPIKey {
+					r.Header.Del("Authorization")
+				}
+				next.ServeHTTP(w, auth.WithPrincipal(r, principal))
+				return
+			}
+			if staticConfig.RequireOAuth {
+				oauth.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="Kubernetes MCP Server"`)
+			if r.Header.Get("Authorization") != "" {
+				klog.V(1).Infof("Authentication failed - invalid API key: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+				http.Error(w, "Unauthorized: invalid API key", http.StatusUnauthorized)
+				return
+			}
+			klog.V(1).Infof("Authentication failed - missing API key or client certificate: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+			http.Error(w, "Unauthorized: API key or client certificate required", http.StatusUnauthorized)
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"k8s.io/klog/v2"
+
+	"github.com/containers/kubernetes-mcp-server/pkg/config"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/auth"
+	localmcp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/mcp"
+)
+
+const (
+	livezEndpoint  = "/livez"
+	readyzEndpoint = "/readyz"
+)
+
+// probeEndpoints are the liveness and readiness endpoints, served without authentication (neither OAuth).
+var probeEndpoints = []string{livezEndpoint, readyzEndpoint}
+
+// healthCheckTimeout bounds each check so that a slow dependency fails the probe instead of timing it out.
+const healthCheckTimeout = 5 * time.Second
+
+// healthCheckCacheTTL is how long the result of a readiness check is reused, so that the probes (served to
+// anyone) don't send requests to the clusters and the OIDC provider each.
+const healthCheckCacheTTL = 5 * time.Second
+
+// healthCheck is a named liveness or readiness check.
+type healthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// livenessChecks returns the liveness checks, the server is alive as long as it serves HTTP requests.
+func livenessChecks() []healthCheck {
+	return []healthCheck{{Name: "ping", Check: func(context.Context) error { return nil }}}
+}
+
+// cached returns the check reusing its result for the TTL, the concurrent probes waiting for the same result.
+// The results of the checks interrupted by the client are not kept.
+func (c healthCheck) cached(ttl time.Duration) healthCheck {
+	var mu sync.Mutex
+	var err error
+	var checked time.Time
+	return healthCheck{Name: c.Name, Check: func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !checked.IsZero() && time.Since(checked) < ttl {
+			return err
+		}
+		result := c.Check(ctx)
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return result
+		}
+		err, checked = result, time.Now()
+		return err
+	}}
+}
+
+// readinessChecks returns the readiness checks: the kubeconfig loaded and the default cluster is reachable
+// for the server and each additional endpoint, and the OIDC provider discovery succeeds if configured. The
+// results are cached for the cache TTL.
+func readinessChecks(mcpServer *localmcp.Server, staticConfig *config.StaticConfig, oidcProvider *oidc.Provider,
+	httpClient *http.Client, endpoints []Endpoint) []healthCheck {
+	checks := livenessChecks()
+	serverChecks := func(suffix string, server *localmcp.Server) {
+		checks = append(checks,
+			healthCheck{Name: "kubeconfig" + suffix, Check: server.CheckKubeconfig},
+			healthCheck{Name: "cluster" + suffix, Check: server.CheckCluster},
+		)
+	}
+	serverChecks("", mcpServer)
+	for _, endpoint := range endpoints {
+		serverChecks(":"+endpoint.Path, endpoint.Server)
+	}
+	if staticConfig.AuthorizationURL != "" {
+		checks = append(checks, healthCheck{Name: "oidc", Check: func(ctx context.Context) error {
+			if oidcProvider == nil {
+				return fmt.Errorf("the OIDC provider of %s wasn't discovered", staticConfig.AuthorizationURL)
+			}
+			if httpClient != nil {
+				ctx = oidc.ClientContext(ctx, httpClient)
+			}
+			_, err := oidc.NewProvider(ctx, staticConfig.AuthorizationURL)
+			return err
+		}})
+	}
+	for i, check := range checks {
+		checks[i] = check.cached(healthCheckCacheTTL)
+	}
+	return checks
+}
+
+// healthHandler runs the checks in parallel, replying 200 if they all pass and 500 otherwise.
+//
+// As for the Kubernetes API server, the checks are listed with their failure reasons with the ?verbose query
+// parameter and checks are skipped with ?exclude=<name>. Since the probes are served without authentication,
+// only the clients authenticated with an API key or a client certificate get the details, unless the server
+// doesn't authenticate its clients (public); the failures are logged.
+func healthHandler(name string, checks []healthCheck, public bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		_, verbose := query["verbose"]
+		verbose = verbose && (public || auth.PrincipalFromContext(r.Context()) != nil)
+		excluded := query["exclude"]
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		defer cancel()
+
+		errs := make([]error, len(checks))
+		var wg sync.WaitGroup
+		for i, check := range checks {
+			if slices.Contains(excluded, check.Name) {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = check.Check(ctx)
+			}()
+		}
+		wg.Wait()
+
+		var out strings.Builder
+		failed := false
+		for i, check := range checks {
+			switch {
+			case slices.Contains(excluded, check.Name):
+				fmt.Fprintf(&out, "[+]%s excluded: ok\n", check.Name)
+			case errs[i] == nil:
+				fmt.Fprintf(&out, "[+]%s ok\n", check.Name)
+			default:
+				failed = true
+				klog.V(1).Infof("%s check %s failed: %v", name, check.Name, errs[i])
+				fmt.Fprintf(&out, "[-]%s failed: %s\n", check.Name, errs[i])
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if failed {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		switch {
+		case verbose && failed:
+			_, _ = fmt.Fprintf(w, "%s%s check failed\n", out.String(), name)
+		case verbose:
+			_, _ = fmt.Fprintf(w, "%s%s check passed\n", out.String(), name)
+		case failed:
+			_, _ = fmt.Fprintf(w, "%s check failed\n", name)
+		default:
+			_, _ = w.Write([]byte("ok"))
+		}
+	})
+}
@@ -0,0 +1,343 @@
+// Package http provides the streamable HTTP, SSE and WebSocket transports for the extendable MCP server.
+//
+// It replicates the kubernetes-mcp-server HTTP server (same endpoints, middleware and OAuth flow)
+// on top of the Model Context Protocol SDK server, adding a WebSocket transport.
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"k8s.io/klog/v2"
+
+	"github.com/containers/kubernetes-mcp-server/pkg/config"
+	internalhttp "github.com/containers/kubernetes-mcp-server/pkg/http"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/auth"
+	localconfig "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	localmcp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/mcp"
+)
+
+const (
+	healthEndpoint     = "/healthz"
+	mcpEndpoint        = "/mcp"
+	sseEndpoint        = "/sse"
+	sseMessageEndpoint = "/message"
+	websocketEndpoint  = "/ws"
+)
+
+// Endpoint is an additional MCP server served under its own path prefix (e.g. a configuration profile).
+type Endpoint struct {
+	// Path is the prefix of the endpoints, the server is served at Path+/mcp, Path+/sse, Path+/message and Path+/ws.
+	Path   string
+	Server *localmcp.Server
+}
+
+// Listener is an HTTP listener of ServeListeners, serving its server with its own configuration.
+type Listener struct {
+	// Name identifies the listener in the logs.
+	Name string
+	// Address is the TCP address (host:port) to listen on. If empty, the listener listens on the Unix domain
+	// socket of the listen configuration if set, on the TCP port otherwise.
+	Address string
+	// Server is the MCP server of the listener.
+	Server *localmcp.Server
+	// StaticConfig and Extensions configure the authentication, OAuth, TLS and SSE base URL of the listener.
+	StaticConfig *config.StaticConfig
+	Extensions   *localconfig.Config
+	// Endpoints are the additional MCP servers of the listener.
+	Endpoints []Endpoint
+}
+
+// Serve starts the streamable HTTP, SSE and WebSocket servers and blocks until the context is cancelled
+// or the server fails.
+//
+// Once the context is cancelled, the server shuts down gracefully: new connections, sessions and tool calls
+// are refused, the in-flight tool calls are given the shutdown grace period to complete, then the remaining
+// requests and sessions (SSE streams and WebSocket connections) are closed.
+//
+// The server listens on the Unix domain socket of the listen configuration if set, on the TCP port otherwise.
+// The additional endpoints share the listener, authentication and OAuth configuration of the server.
+func Serve(ctx context.Context, mcpServer *localmcp.Server, staticConfig *config.StaticConfig, extensions *localconfig.Config,
+	oidcProvider *oidc.Provider, httpClient *http.Client, endpoints ...Endpoint) error {
+	return ServeListeners(ctx, oidcProvider, httpClient, Listener{
+		Name:         "default",
+		Server:       mcpServer,
+		StaticConfig: staticConfig,
+		Extensions:   extensions,
+		Endpoints:    endpoints,
+	})
+}
+
+// ServeListeners serves each listener as Serve does, until the context is cancelled or any of them fails.
+// The listeners are shut down together, with the longest grace period of their shutdown configurations.
+func ServeListeners(ctx context.Context, oidcProvider *oidc.Provider, httpClient *http.Client, listeners ...Listener) error {
+	var gracePeriod time.Duration
+	for _, listener := range listeners {
+		listenerGracePeriod, err := listener.Extensions.Shutdown.GracePeriodDuration()
+		if err != nil {
+			return err
+		}
+		gracePeriod = max(gracePeriod, listenerGracePeriod)
+	}
+	// the requests and sessions outlive the context until the in-flight tool calls are drained
+	requestsCtx, closeRequests := context.WithCancel(context.Background())
+	defer closeRequests()
+	requests := &requestTracker{}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	httpServers := make([]*http.Server, 0, len(listeners))
+	netListeners := make([]net.Listener, 0, len(listeners))
+	defer func() {
+		// closes the listeners that weren't served if a listener failed to start
+		for _, listener := range netListeners[len(httpServers):] {
+			_ = listener.Close()
+		}
+	}()
+	for _, listener := range listeners {
+		httpServer, err := newHTTPServer(ctx, requestsCtx, requests, listener, oidcProvider, httpClient)
+		if err != nil {
+			return err
+		}
+		netListener, err := listen(listener.Address, listener.StaticConfig.Port, listener.Extensions.Listen)
+		if err != nil {
+			return err
+		}
+		netListeners = append(netListeners, netListener)
+		httpServers = append(httpServers, httpServer)
+	}
+
+	serverErr := make(chan error, len(listeners))
+	for i, listener := range listeners {
+		httpServer, netListener := httpServers[i], netListeners[i]
+		go func() {
+			logListener(listener, netListener)
+			var err error
+			if httpServer.TLSConfig != nil {
+				// The certificate is provided (and reloaded) by the TLSConfig
+				err = httpServer.ServeTLS(netListener, "", "")
+			} else {
+				err = httpServer.Serve(netListener)
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- fmt.Errorf("listener %s: %w", listener.Name, err)
+			}
+		}()
+	}
+
+	var serveErr error
+	select {
+	case <-ctx.Done():
+		klog.V(0).Infof("Context cancelled, initiating graceful shutdown")
+	case serveErr = <-serverErr:
+		klog.Errorf("HTTP server error: %v", serveErr)
+	}
+
+	var servers []*localmcp.Server
+	for _, listener := range listeners {
+		for _, server := range append([]*localmcp.Server{listener.Server}, endpointServers(listener.Endpoints)...) {
+			if !slices.Contains(servers, server) {
+				servers = append(servers, server)
+			}
+		}
+	}
+	shutdown(httpServers, servers, requests, closeRequests, gracePeriod)
+	if serveErr != nil {
+		return serveErr
+	}
+	klog.V(0).Infof("HTTP server shutdown complete")
+	return nil
+}
+
+// maxRequestBodySize bounds the size of the request bodies, and of the WebSocket messages, which are read in memory.
+const maxRequestBodySize = 4 << 20
+
+// limitRequestBody rejects the requests whose body is larger than the maximum size, failing the reads of the
+// bodies of unknown length beyond it.
+func limitRequestBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxRequestBodySize {
+			http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newHTTPServer returns the HTTP server of the listener, with its endpoints, authentication, OAuth and TLS.
+func newHTTPServer(ctx, requestsCtx context.Context, requests *requestTracker, listener Listener, oidcProvider *oidc.Provider, httpClient *http.Client) (*http.Server, error) {
+	mcpServer, staticConfig, extensions := listener.Server, listener.StaticConfig, listener.Extensions
+	mux := http.NewServeMux()
+
+	authenticator, err := auth.NewAuthenticator(extensions.Authentication)
+	if err != nil {
+		return nil, err
+	}
+	oauthMux := internalhttp.AuthorizationMiddleware(staticConfig, oidcProvider, mcpServer, httpClient)(mux)
+	wrappedMux := internalhttp.RequestMiddleware(
+		AuthenticationMiddleware(authenticator, staticConfig, oauthMux)(mux),
+	)
+	httpServer := &http.Server{
+		Handler:           requests.track(limitRequestBody(wrappedMux)),
+		ReadHeaderTimeout: 30 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return requestsCtx },
+	}
+	if extensions.TLS.Enabled() {
+		if httpServer.TLSConfig, err = tlsConfig(ctx, extensions); err != nil {
+			return nil, err
+		}
+	}
+
+	streamable, err := newStreamable(staticConfig, extensions)
+	if err != nil {
+		return nil, err
+	}
+	handleMCP(mux, "", mcpServer, staticConfig.SSEBaseURL, streamable)
+	for _, endpoint := range listener.Endpoints {
+		handleMCP(mux, endpoint.Path, endpoint.Server, staticConfig.SSEBaseURL, streamable)
+	}
+	mux.HandleFunc(healthEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	public := authenticator == nil && !staticConfig.RequireOAuth
+	mux.Handle(livezEndpoint, healthHandler("livez", livenessChecks(), public))
+	mux.Handle(readyzEndpoint, healthHandler("readyz", readinessChecks(mcpServer, staticConfig, oidcProvider, httpClient, listener.Endpoints), public))
+	mux.Handle("/.well-known/", internalhttp.WellKnownHandler(staticConfig, httpClient))
+	return httpServer, nil
+}
+
+// logListener logs the address and the paths the listener serves.
+func logListener(listener Listener, netListener net.Listener) {
+	address := "port " + listener.StaticConfig.Port
+	switch {
+	case listener.Address != "":
+		address = netListener.Addr().String()
+	case listener.Extensions.Listen.Enabled():
+		address = "socket " + listener.Extensions.Listen.SocketPath()
+	}
+	if listener.Name == "default" {
+		klog.V(0).Infof("Streaming, SSE and WebSocket HTTP servers starting on %s and paths /mcp, /sse, /message, /ws", address)
+	} else {
+		klog.V(0).Infof("Listener %s starting on %s and paths /mcp, /sse, /message, /ws", listener.Name, address)
+	}
+	for _, endpoint := range listener.Endpoints {
+		klog.V(0).Infof("Serving additional endpoints at %s/mcp, %s/sse, %s/message, %s/ws", endpoint.Path, endpoint.Path, endpoint.Path, endpoint.Path)
+	}
+}
+
+func endpointServers(endpoints []Endpoint) []*localmcp.Server {
+	servers := make([]*localmcp.Server, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		servers = append(servers, endpoint.Server)
+	}
+	return servers
+}
+
+// shutdown closes the listeners and drains the servers for up to the grace period, then closes the remaining
+// requests and sessions once the responses of the drained tool calls are written. The connections still open
+// after the grace period are closed forcibly.
+func shutdown(httpServers []*http.Server, servers []*localmcp.Server, requests *requestTracker,
+	closeRequests context.CancelFunc, gracePeriod time.Duration) {
+	klog.V(0).Infof("Shutting down HTTP server gracefully, waiting up to %s for the in-flight tool calls...", gracePeriod)
+	graceCtx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+	httpShutdown := make([]chan error, len(httpServers))
+	for i, httpServer := range httpServers {
+		httpShutdown[i] = make(chan error, 1)
+		go func() { httpShutdown[i] <- httpServer.Shutdown(graceCtx) }()
+	}
+
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := server.Shutdown(graceCtx); err != nil {
+				klog.Warningf("Shutdown grace period expired: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	requests.wait(graceCtx)
+	// ends the SSE streams, WebSocket connections and the requests still in progress
+	closeRequests()
+	for i, httpServer := range httpServers {
+		if err := <-httpShutdown[i]; err != nil {
+			klog.Warningf("HTTP server shutdown error: %v, closing the remaining connections", err)
+			_ = httpServer.Close()
+		}
+	}
+}
+
+// requestTracker tracks the in-flight requests other than the GET streams (SSE, WebSocket and the standalone
+// streamable HTTP stream), so that the responses of the tool calls drained on shutdown are written before the
+// requests are closed.
+type requestTracker struct {
+	mu       sync.Mutex
+	requests int
+	// idle is closed once no request is in flight
+	idle chan struct{}
+}
+
+func (t *requestTracker) track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.mu.Lock()
+			t.requests++
+			t.mu.Unlock()
+			defer t.done()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (t *requestTracker) done() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.requests--
+	if t.requests == 0 && t.idle != nil {
+		close(t.idle)
+		t.idle = nil
+	}
+}
+
+// wait waits until no request is in flight or the context is done.
+func (t *requestTracker) wait(ctx context.Context) {
+	t.mu.Lock()
+	if t.requests == 0 {
+		t.mu.Unlock()
+		return
+	}
+	if t.idle == nil {
+		t.idle = make(chan struct{})
+	}
+	idle := t.idle
+	t.mu.Unlock()
+	select {
+	case <-idle:
+	case <-ctx.Done():
+	}
+}
+
+// handleMCP registers the streamable HTTP, SSE and WebSocket endpoints of the server under the path prefix.
+func handleMCP(mux *http.ServeMux, prefix string, mcpServer *localmcp.Server, sseBaseURL string,
+	streamable func(mcpServer *localmcp.Server, endpoint string) http.Handler) {
+	var sseServer http.Handler = NewSSEHandler(mcpServer, strings.TrimSuffix(sseBaseURL, "/")+prefix)
+	if prefix != "" {
+		sseServer = http.StripPrefix(prefix, sseServer)
+	}
+	mux.Handle(prefix+sseEndpoint, sseServer)
+	mux.Handle(prefix+sseMessageEndpoint, sseServer)
+	mux.Handle(prefix+mcpEndpoint, streamable(mcpServer, prefix+mcpEndpoint))
+	mux.Handle(prefix+websocketEndpoint, NewWebSocketHandler(mcpServer))
+}
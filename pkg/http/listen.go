@@ -0,0 +1,85 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	localconfig "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+)
+
+// listen returns the listener of the HTTP server: the TCP address if set, the Unix domain socket of the
+// listen configuration if set, the TCP port otherwise.
+func listen(address, port string, listenConfig localconfig.ListenConfig) (net.Listener, error) {
+	switch {
+	case address != "":
+		return net.Listen("tcp", address)
+	case listenConfig.Enabled():
+		return listenUnix(listenConfig)
+	default:
+		return net.Listen("tcp", ":"+port)
+	}
+}
+
+// listenUnix listens on the Unix domain socket with the configured file mode.
+// A stale socket left by a server that didn't shut down cleanly is replaced, a socket in use is not.
+// The socket file is removed when the listener is closed.
+func listenUnix(listenConfig localconfig.ListenConfig) (net.Listener, error) {
+	path := listenConfig.SocketPath()
+	mode, err := listenConfig.FileMode()
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("failed to listen on %s: the file exists and is not a socket", path)
+		}
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("failed to listen on %s: the socket is in use by another server", path)
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to remove the stale socket %s: %w", path, err)
+		}
+	}
+	// the socket is created in a private directory and moved once it has its mode, so that it can't be
+	// connected to with the mode resulting from the umask
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".sock-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the directory of the socket %s: %w", path, err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	listener, err := net.Listen("unix", filepath.Join(dir, "s"))
+	if err != nil {
+		return nil, err
+	}
+	unixListener := listener.(*net.UnixListener)
+	unixListener.SetUnlinkOnClose(false)
+	if err := os.Chmod(filepath.Join(dir, "s"), mode); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to set the mode of the socket %s: %w", path, err)
+	}
+	if err := os.Rename(filepath.Join(dir, "s"), path); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	return &unixSocketListener{UnixListener: unixListener, path: path}, nil
+}
+
+// unixSocketListener is a Unix domain socket listener removing the socket file when closed.
+type unixSocketListener struct {
+	*net.UnixListener
+	path string
+}
+
+func (l *unixSocketListener) Close() error {
+	err := l.UnixListener.Close()
+	if removeErr := os.Remove(l.path); removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) && err == nil {
+		err = removeErr
+	}
+	return err
+}
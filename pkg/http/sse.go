@@ -0,0 +1,112 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+
+	localmcp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/mcp"
+)
+
+// SSEHandler serves the legacy HTTP+SSE transport.
+//
+// GET requests to /sse open a new session and stream the server messages, the endpoint event
+// points the client to /message?sessionId=<id> (prefixed with the configured public base URL)
+// where the client messages are POSTed.
+type SSEHandler struct {
+	mcpServer *localmcp.Server
+	baseURL   string
+
+	mu       sync.Mutex
+	sessions map[string]*mcp.SSEServerTransport
+}
+
+var _ http.Handler = &SSEHandler{}
+
+// NewSSEHandler creates a new SSE handler for the server, baseURL is the public base URL
+// used when sending the endpoint message (may be empty to use a relative endpoint).
+func NewSSEHandler(mcpServer *localmcp.Server, baseURL string) *SSEHandler {
+	return &SSEHandler{
+		mcpServer: mcpServer,
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		sessions:  make(map[string]*mcp.SSEServerTransport),
+	}
+}
+
+func (h *SSEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == sseEndpoint:
+		h.serveStream(w, r)
+	case r.Method == http.MethodPost && r.URL.Path == sseMessageEndpoint:
+		h.serveMessage(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *SSEHandler) serveStream(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	sessionID := newSessionID()
+	transport := &mcp.SSEServerTransport{
+		Endpoint: h.baseURL + sseMessageEndpoint + "?sessionId=" + sessionID,
+		Response: w,
+	}
+
+	h.mu.Lock()
+	h.sessions[sessionID] = transport
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.sessions, sessionID)
+		h.mu.Unlock()
+	}()
+
+	session, err := h.mcpServer.Connect(r.Context(), transport, r.Header.Clone())
+	if err != nil {
+		klog.V(1).Infof("SSE session connection failed: %v", err)
+		http.Error(w, "connection failed", http.StatusInternalServerError)
+		return
+	}
+	// close the session when the GET request exits
+	defer func() { _ = session.Close() }()
+
+	closed := make(chan struct{})
+	go func() {
+		_ = session.Wait()
+		close(closed)
+	}()
+	select {
+	case <-r.Context().Done():
+	case <-closed:
+	}
+}
+
+func (h *SSEHandler) serveMessage(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.URL.Query().Get("sessionId")
+	if sessionID == "" {
+		http.Error(w, "Missing sessionId", http.StatusBadRequest)
+		return
+	}
+	h.mu.Lock()
+	transport := h.sessions[sessionID]
+	h.mu.Unlock()
+	if transport == nil {
+		http.Error(w, "Invalid session ID", http.StatusNotFound)
+		return
+	}
+	transport.ServeHTTP(w, r)
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
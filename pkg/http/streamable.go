@@ -0,0 +1,473 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containers/kubernetes-mcp-server/pkg/config"
+	internalk8s "github.com/containers/kubernetes-mcp-server/pkg/kubernetes"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/auth"
+	localconfig "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/impersonate"
+	localmcp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/mcp"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/sessions"
+)
+
+const sessionIDHeader = "Mcp-Session-Id"
+
+// maxSessionRefreshInterval is the maximum interval between the refreshes of the record of a session in use,
+// which also detect the sessions deleted through another server instance.
+const maxSessionRefreshInterval = time.Minute
+
+// newStreamable returns the constructor of the streamable HTTP handlers of the sessions configuration: stateless
+// unless the resumption is enabled, in which case the sessions and their events are kept in the session store.
+func newStreamable(staticConfig *config.StaticConfig, extensions *localconfig.Config) (func(mcpServer *localmcp.Server, endpoint string) http.Handler, error) {
+	cfg := extensions.Sessions
+	if !cfg.Resumption {
+		return func(mcpServer *localmcp.Server, _ string) http.Handler {
+			return mcp.NewStreamableHTTPHandler(
+				func(*http.Request) *mcp.Server { return mcpServer.McpServer() },
+				&mcp.StreamableHTTPOptions{Stateless: true},
+			)
+		}, nil
+	}
+	idleTimeout, err := cfg.IdleTimeoutDuration()
+	if err != nil {
+		return nil, err
+	}
+	store, err := sessions.NewStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	owner := sessionOwner(staticConfig, extensions.Impersonation)
+	return func(mcpServer *localmcp.Server, endpoint string) http.Handler {
+		return newStreamableHandler(mcpServer.McpServer, store, endpoint, idleTimeout, owner)
+	}, nil
+}
+
+// sessionOwner returns the identity of the client of a request, which the sessions it creates are bound to:
+// the principal authenticated with an API key or a client certificate, the user of the OAuth token verified
+// by the authorization middleware, or a hash of any other bearer token. Empty for the unauthenticated clients.
+func sessionOwner(staticConfig *config.StaticConfig, impersonation localconfig.ImpersonationConfig) func(r *http.Request) string {
+	verified := staticConfig.RequireOAuth && (staticConfig.AuthorizationURL != "" || staticConfig.ValidateToken)
+	return func(r *http.Request) string {
+		if principal := auth.PrincipalFromContext(r.Context()); principal != nil {
+			return principal.Method + ":" + principal.Name
+		}
+		authorization := r.Header.Get(string(internalk8s.OAuthAuthorizationHeader))
+		if authorization == "" {
+			authorization = r.Header.Get(string(internalk8s.CustomAuthorizationHeader))
+		}
+		if authorization == "" {
+			return ""
+		}
+		if token, ok := strings.CutPrefix(authorization, "Bearer "); ok && verified {
+			if identity, err := impersonate.IdentityFromToken(impersonation, token); err == nil {
+				return "user:" + identity.User
+			}
+		}
+		sum := sha256.Sum256([]byte(authorization))
+		return "token:" + hex.EncodeToString(sum[:])
+	}
+}
+
+// streamableHandler serves the stateful streamable HTTP sessions of an endpoint, recording them in the session
+// store so that any server instance sharing the store serves their requests: a session unknown to the instance
+// is restored from its record, with the initialization negotiated by the instance that created it, and its
+// streams are resumed from the stored events. The sessions are only served to the client that created them.
+type streamableHandler struct {
+	getServer   func() *mcp.Server
+	store       sessions.Store
+	endpoint    string
+	idleTimeout time.Duration
+	owner       func(r *http.Request) string
+
+	mu       sync.Mutex
+	sessions map[string]*streamableSession
+}
+
+// streamableSession is a session served by the instance, closed once idle for the idle timeout. Its record
+// is kept in the store until it expires, so that it's restored by the next request.
+type streamableSession struct {
+	session   *mcp.ServerSession
+	transport *mcp.StreamableServerTransport
+	owner     string
+
+	mu sync.Mutex
+	// requests counts the POST requests in progress, pausing the idle timer
+	requests int
+	timer    *time.Timer
+	// refreshed is when the record was last saved
+	refreshed time.Time
+	// logLevel is the log level set by the client
+	logLevel mcp.LoggingLevel
+}
+
+func newStreamableHandler(getServer func() *mcp.Server, store sessions.Store, endpoint string, idleTimeout time.Duration, owner func(r *http.Request) string) *streamableHandler {
+	h := &streamableHandler{
+		getServer:   getServer,
+		store:       store,
+		endpoint:    endpoint,
+		idleTimeout: idleTimeout,
+		owner:       owner,
+		sessions:    make(map[string]*streamableSession),
+	}
+	getServer().AddReceivingMiddleware(h.recordSessions)
+	return h
+}
+
+func (h *streamableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !acceptsStreamable(r) {
+		if r.Method == http.MethodGet {
+			http.Error(w, "Accept must contain 'text/event-stream' for GET requests", http.StatusBadRequest)
+		} else {
+			http.Error(w, "Accept must contain both 'application/json' and 'text/event-stream'", http.StatusBadRequest)
+		}
+		return
+	}
+	sessionID := r.Header.Get(sessionIDHeader)
+	owner := h.owner(r)
+	switch r.Method {
+	case http.MethodDelete:
+		if sessionID == "" {
+			http.Error(w, "Bad Request: DELETE requires an Mcp-Session-Id header", http.StatusBadRequest)
+			return
+		}
+		if err := h.checkOwner(r.Context(), sessionID, owner); err != nil {
+			sessionError(w, sessionID, err)
+			return
+		}
+		h.closeLocal(sessionID)
+		if err := h.store.Delete(r.Context(), sessionID); err != nil {
+			klog.Errorf("Failed to delete the session %s: %v", sessionID, err)
+			http.Error(w, "failed to delete the session", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case http.MethodGet:
+		if sessionID == "" {
+			http.Error(w, "GET requires an active session", http.StatusMethodNotAllowed)
+			return
+		}
+	case http.MethodPost:
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method Not Allowed: streamable MCP servers support GET, POST, and DELETE requests", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if sessionID == "" {
+		h.serveNew(w, r, owner)
+		return
+	}
+	s, err := h.session(r.Context(), sessionID, owner)
+	if err != nil {
+		sessionError(w, sessionID, err)
+		return
+	}
+	if r.Method == http.MethodPost {
+		s.startRequest()
+		defer s.endRequest(h.idleTimeout)
+	}
+	s.transport.ServeHTTP(w, r)
+}
+
+// sessionError responds with the error looking up a session, the sessions of other clients are not found.
+func sessionError(w http.ResponseWriter, sessionID string, err error) {
+	if errors.Is(err, sessions.ErrSessionNotFound) {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	klog.Errorf("Failed to restore the session %s: %v", sessionID, err)
+	http.Error(w, "failed to restore the session", http.StatusInternalServerError)
+}
+
+// acceptsStreamable checks the Accept header, allowing multiple headers: the streams are required and the
+// POST requests must also accept JSON.
+func acceptsStreamable(r *http.Request) bool {
+	var jsonOK, streamOK bool
+	for _, accept := range strings.Split(strings.Join(r.Header.Values("Accept"), ","), ",") {
+		switch strings.TrimSpace(accept) {
+		case "application/json", "application/*":
+			jsonOK = true
+		case "text/event-stream", "text/*":
+			streamOK = true
+		case "*/*":
+			jsonOK, streamOK = true, true
+		}
+	}
+	switch r.Method {
+	case http.MethodGet:
+		return streamOK
+	case http.MethodDelete:
+		return true
+	default:
+		return jsonOK && streamOK
+	}
+}
+
+// serveNew serves the initialization of a new session of the owner, recorded in the store once initialized.
+func (h *streamableHandler) serveNew(w http.ResponseWriter, r *http.Request, owner string) {
+	sessionID := rand.Text()
+	h.mu.Lock()
+	s, err := h.connect(r.Context(), sessionID, owner, nil)
+	h.mu.Unlock()
+	if err != nil {
+		klog.Errorf("Failed to connect the session: %v", err)
+		http.Error(w, "failed connection", http.StatusInternalServerError)
+		return
+	}
+	s.startRequest()
+	defer s.endRequest(h.idleTimeout)
+	s.transport.ServeHTTP(w, r)
+	if s.session.InitializeParams() == nil {
+		// the initialization failed
+		_ = s.session.Close()
+	}
+}
+
+// recordSessions is the receiving middleware of the server recording the sessions of the handler in the store
+// once initialized, before the client is answered, and their log level once set by the client.
+func (h *streamableHandler) recordSessions(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		result, err := next(ctx, method, req)
+		if err != nil || (method != "initialize" && method != "logging/setLevel") {
+			return result, err
+		}
+		session, _ := req.GetSession().(*mcp.ServerSession)
+		if session == nil {
+			return result, err
+		}
+		h.mu.Lock()
+		s, ok := h.sessions[session.ID()]
+		h.mu.Unlock()
+		if !ok || s.session != session {
+			// served by another handler or transport
+			return result, err
+		}
+		ctx = context.WithoutCancel(ctx)
+		if method == "initialize" {
+			record := &sessions.Session{
+				ID:               session.ID(),
+				Endpoint:         h.endpoint,
+				Owner:            s.owner,
+				InitializeParams: session.InitializeParams(),
+				LogLevel:         s.level(),
+				LastActive:       time.Now(),
+			}
+			if saveErr := h.store.Save(ctx, record); saveErr != nil {
+				klog.Errorf("Failed to save the session %s: %v", session.ID(), saveErr)
+			}
+			return result, err
+		}
+		params, _ := req.GetParams().(*mcp.SetLoggingLevelParams)
+		if params == nil {
+			return result, err
+		}
+		s.mu.Lock()
+		s.logLevel = params.Level
+		s.mu.Unlock()
+		record, loadErr := h.store.Load(ctx, session.ID())
+		if loadErr == nil {
+			record.LogLevel = params.Level
+			loadErr = h.store.Save(ctx, record)
+		}
+		if loadErr != nil {
+			klog.Warningf("Failed to save the log level of the session %s: %v", session.ID(), loadErr)
+		}
+		return result, err
+	}
+}
+
+// checkOwner returns ErrSessionNotFound unless the session exists and belongs to the owner.
+func (h *streamableHandler) checkOwner(ctx context.Context, sessionID, owner string) error {
+	h.mu.Lock()
+	s, ok := h.sessions[sessionID]
+	h.mu.Unlock()
+	if ok {
+		if s.owner != owner {
+			return sessions.ErrSessionNotFound
+		}
+		return nil
+	}
+	record, err := h.store.Load(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if record.Endpoint != h.endpoint || record.Owner != owner {
+		return sessions.ErrSessionNotFound
+	}
+	return nil
+}
+
+// session returns the session of the owner served by the instance, restoring it from the store if needed. The
+// record of the session is refreshed at most once per refresh interval, the session is closed if it was deleted.
+// The store is only accessed without h.mu held.
+func (h *streamableHandler) session(ctx context.Context, sessionID, owner string) (*streamableSession, error) {
+	h.mu.Lock()
+	s, ok := h.sessions[sessionID]
+	h.mu.Unlock()
+	if ok {
+		if s.owner != owner {
+			return nil, sessions.ErrSessionNotFound
+		}
+		s.mu.Lock()
+		due := time.Since(s.refreshed) > h.refreshInterval()
+		if due {
+			s.refreshed = time.Now()
+		}
+		s.mu.Unlock()
+		if !due {
+			return s, nil
+		}
+	}
+	record, err := h.store.Load(ctx, sessionID)
+	if err == nil && (record.Endpoint != h.endpoint || record.Owner != owner) {
+		err = sessions.ErrSessionNotFound
+	}
+	switch {
+	case ok && errors.Is(err, sessions.ErrSessionNotFound):
+		h.mu.Lock()
+		if h.sessions[sessionID] == s {
+			delete(h.sessions, sessionID)
+		}
+		h.mu.Unlock()
+		_ = s.session.Close()
+		return nil, err
+	case ok && err != nil:
+		klog.Warningf("Failed to refresh the session %s: %v", sessionID, err)
+		return s, nil
+	case err != nil:
+		return nil, err
+	}
+	record.LastActive = time.Now()
+	if ok {
+		record.LogLevel = s.level()
+	}
+	if err := h.store.Save(ctx, record); err != nil {
+		if ok {
+			klog.Warningf("Failed to refresh the session %s: %v", sessionID, err)
+			return s, nil
+		}
+		return nil, err
+	}
+	if ok {
+		return s, nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.sessions[sessionID]; ok {
+		// restored by a concurrent request
+		if s.owner != owner {
+			return nil, sessions.ErrSessionNotFound
+		}
+		return s, nil
+	}
+	s, err = h.connect(ctx, sessionID, owner, &mcp.ServerSessionState{
+		InitializeParams:  record.InitializeParams,
+		InitializedParams: &mcp.InitializedParams{},
+		LogLevel:          record.LogLevel,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.logLevel = record.LogLevel
+	klog.V(2).Infof("Restored the session %s from the session store", sessionID)
+	return s, nil
+}
+
+// refreshInterval returns the interval between the refreshes of the records, short enough for the sessions
+// in use to never expire.
+func (h *streamableHandler) refreshInterval() time.Duration {
+	if h.idleTimeout > 0 {
+		return min(maxSessionRefreshInterval, h.idleTimeout/2)
+	}
+	return maxSessionRefreshInterval
+}
+
+// connect connects a session of the owner, restored with the state if set. It must be called with h.mu held.
+func (h *streamableHandler) connect(ctx context.Context, sessionID, owner string, state *mcp.ServerSessionState) (*streamableSession, error) {
+	transport := &mcp.StreamableServerTransport{
+		SessionID:  sessionID,
+		EventStore: sessions.Events(h.store),
+	}
+	var opts *mcp.ServerSessionOptions
+	if state != nil {
+		opts = &mcp.ServerSessionOptions{State: state}
+	}
+	// the context is detached once the session is connected
+	session, err := h.getServer().Connect(ctx, transport, opts)
+	if err != nil {
+		return nil, err
+	}
+	// the record is saved once initialized or was just refreshed
+	s := &streamableSession{session: session, transport: transport, owner: owner, refreshed: time.Now()}
+	if h.idleTimeout > 0 {
+		s.timer = time.AfterFunc(h.idleTimeout, func() { _ = session.Close() })
+	}
+	h.sessions[sessionID] = s
+	go func() {
+		_ = session.Wait()
+		s.mu.Lock()
+		if s.timer != nil {
+			s.timer.Stop()
+			s.timer = nil
+		}
+		s.mu.Unlock()
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.sessions[sessionID] == s {
+			delete(h.sessions, sessionID)
+		}
+	}()
+	return s, nil
+}
+
+// closeLocal closes the session if served by the instance.
+func (h *streamableHandler) closeLocal(sessionID string) {
+	h.mu.Lock()
+	s, ok := h.sessions[sessionID]
+	delete(h.sessions, sessionID)
+	h.mu.Unlock()
+	if ok {
+		_ = s.session.Close()
+	}
+}
+
+// level returns the log level set by the client.
+func (s *streamableSession) level() mcp.LoggingLevel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logLevel
+}
+
+// startRequest pauses the idle timer while a POST request is in progress.
+func (s *streamableSession) startRequest() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timer != nil && s.requests == 0 {
+		s.timer.Stop()
+	}
+	s.requests++
+}
+
+// endRequest restarts the idle timer once no POST request is in progress.
+func (s *streamableSession) endRequest(idleTimeout time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests--
+	if s.timer != nil && s.requests == 0 {
+		s.timer.Reset(idleTimeout)
+	}
+}
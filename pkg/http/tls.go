@@ -0,0 +1,136 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/klog/v2"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/auth"
+	localconfig "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+)
+
+// tlsConfig returns the TLS configuration for the HTTP server, the server certificate is reloaded
+// when the files change until the context is cancelled.
+// Client certificates are requested (but not required) if mTLS authentication is enabled.
+func tlsConfig(ctx context.Context, extensions *localconfig.Config) (*tls.Config, error) {
+	minVersion, err := localconfig.TLSVersion(extensions.TLS.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	reloader, err := NewCertificateReloader(extensions.TLS.CertFile, extensions.TLS.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := reloader.Watch(ctx); err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     minVersion,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if extensions.Authentication.MTLS.Enabled {
+		clientCAs, err := auth.LoadClientCAs(extensions.Authentication.MTLS.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = clientCAs
+		// Clients may still authenticate with API keys or OAuth
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
+// CertificateReloader serves the certificate loaded from the certificate and key files,
+// reloading it whenever the files change (e.g. renewed by cert-manager).
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+// NewCertificateReloader loads the PEM encoded certificate and key files.
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	c := &CertificateReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reload loads the certificate and key files, the previous certificate is kept if they can't be loaded.
+func (c *CertificateReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate %s and key %s: %w", c.certFile, c.keyFile, err)
+	}
+	c.mu.Lock()
+	c.cert = &cert
+	c.mu.Unlock()
+	return nil
+}
+
+// GetCertificate returns the current certificate, to be used as tls.Config GetCertificate.
+func (c *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// Watch reloads the certificate on changes of the certificate and key files until the context is cancelled.
+//
+// The parent directories are watched so that atomic replacements (e.g. Kubernetes Secret volume updates,
+// which swap a symlink) are detected.
+func (c *CertificateReloader) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	for _, dir := range []string{filepath.Dir(c.certFile), filepath.Dir(c.keyFile)} {
+		if err := watcher.Add(dir); err != nil {
+			_ = watcher.Close()
+			return fmt.Errorf("failed to watch TLS certificate directory %s: %w", dir, err)
+		}
+	}
+	go func() {
+		defer func() { _ = watcher.Close() }()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !c.isCertificateFile(event.Name) {
+					continue
+				}
+				// Certificate and key may be written separately, failures are retried on the next event
+				if err := c.Reload(); err != nil {
+					klog.V(2).Infof("TLS certificate not reloaded: %v", err)
+					continue
+				}
+				klog.V(1).Infof("TLS certificate reloaded from %s", c.certFile)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				klog.Warningf("TLS certificate watch error: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+func (c *CertificateReloader) isCertificateFile(name string) bool {
+	base := filepath.Base(name)
+	// Kubernetes Secret volumes update the files through the ..data symlink
+	return base == filepath.Base(c.certFile) || base == filepath.Base(c.keyFile) || strings.HasPrefix(base, "..")
+}
@@ -0,0 +1,197 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+
+	localmcp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/mcp"
+)
+
+// WebSocketSubprotocol is the WebSocket subprotocol of the MCP transport, negotiated if the client requests it.
+const WebSocketSubprotocol = "mcp"
+
+// websocketPingInterval is the interval of the pings keeping idle connections open through proxies.
+const websocketPingInterval = 30 * time.Second
+
+// websocketReadTimeout is how long the server waits for a message or the pong of a ping before closing the
+// connection of an unresponsive client.
+const websocketReadTimeout = 2 * websocketPingInterval
+
+// WebSocketHandler serves the WebSocket transport.
+//
+// Each connection upgraded at /ws is a session, the JSON-RPC messages are exchanged as WebSocket text messages.
+// As for SSE, the header of the upgrade request is used to authenticate the Kubernetes API calls of the session.
+type WebSocketHandler struct {
+	mcpServer *localmcp.Server
+	upgrader  websocket.Upgrader
+}
+
+var _ http.Handler = &WebSocketHandler{}
+
+// NewWebSocketHandler creates a new WebSocket handler for the server.
+// Cross-origin upgrade requests (e.g. from browsers on other sites) are rejected.
+func NewWebSocketHandler(mcpServer *localmcp.Server) *WebSocketHandler {
+	return &WebSocketHandler{
+		mcpServer: mcpServer,
+		upgrader:  websocket.Upgrader{Subprotocols: []string{WebSocketSubprotocol}},
+	}
+}
+
+func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// the upgrader replies with the error
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		klog.V(1).Infof("WebSocket upgrade failed: %v", err)
+		return
+	}
+	// the reads (in the transport) fail once the limit or the deadline is exceeded, closing the session
+	conn.SetReadLimit(maxRequestBodySize)
+	extendReadDeadline := func(string) error { return conn.SetReadDeadline(time.Now().Add(websocketReadTimeout)) }
+	_ = extendReadDeadline("")
+	conn.SetPongHandler(extendReadDeadline)
+	transport := &WebSocketTransport{Conn: conn}
+	session, err := h.mcpServer.Connect(r.Context(), transport, r.Header.Clone())
+	if err != nil {
+		klog.V(1).Infof("WebSocket session connection failed: %v", err)
+		_ = conn.Close()
+		return
+	}
+	// close the session when the connection is closed or the server shuts down
+	defer func() { _ = session.Close() }()
+
+	closed := make(chan struct{})
+	go func() {
+		_ = session.Wait()
+		close(closed)
+	}()
+	ticker := time.NewTicker(websocketPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-closed:
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(websocketPingInterval)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// WebSocketTransport is an MCP transport over an established WebSocket connection, for both servers and clients.
+type WebSocketTransport struct {
+	Conn *websocket.Conn
+}
+
+var _ mcp.Transport = &WebSocketTransport{}
+
+// Connect returns the connection of the transport, it may only be called once.
+func (t *WebSocketTransport) Connect(context.Context) (mcp.Connection, error) {
+	c := &websocketConnection{
+		conn:      t.Conn,
+		sessionID: newSessionID(),
+		messages:  make(chan jsonrpc.Message),
+		closed:    make(chan struct{}),
+	}
+	go c.readMessages()
+	return c, nil
+}
+
+// websocketConnection reads the messages in a goroutine, so Read can be cancelled by its context and by Close.
+type websocketConnection struct {
+	conn      *websocket.Conn
+	sessionID string
+
+	messages chan jsonrpc.Message
+	// readErr is the error that ended the reads, set before closed is closed
+	readErr   error
+	closed    chan struct{}
+	closeOnce sync.Once
+	writeMu   sync.Mutex
+}
+
+var _ mcp.Connection = &websocketConnection{}
+
+func (c *websocketConnection) readMessages() {
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				err = io.EOF
+			}
+			c.close(err)
+			return
+		}
+		message, err := jsonrpc.DecodeMessage(data)
+		if err != nil {
+			c.close(err)
+			return
+		}
+		select {
+		case c.messages <- message:
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+func (c *websocketConnection) Read(ctx context.Context) (jsonrpc.Message, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case message := <-c.messages:
+		return message, nil
+	case <-c.closed:
+		return nil, c.readErr
+	}
+}
+
+func (c *websocketConnection) Write(ctx context.Context, message jsonrpc.Message) error {
+	data, err := jsonrpc.EncodeMessage(message)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-c.closed:
+		return errors.New("websocket connection closed")
+	default:
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	deadline, _ := ctx.Deadline()
+	if err := c.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func (c *websocketConnection) Close() error {
+	c.close(io.EOF)
+	return nil
+}
+
+// close closes the connection once, sending a close message to the peer (if the connection is still open).
+func (c *websocketConnection) close(err error) {
+	c.closeOnce.Do(func() {
+		c.readErr = err
+		close(c.closed)
+		message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+		_ = c.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+		_ = c.conn.Close()
+	})
+}
+
+func (c *websocketConnection) SessionID() string {
+	return c.sessionID
+}
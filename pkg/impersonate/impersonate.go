@@ -0,0 +1,285 @@
+// Package impersonate provides the Kubernetes clients that impersonate the authenticated MCP user.
+//
+// kubernetes-mcp-server either uses the server credentials or passes the user's bearer token through
+// to the Kubernetes API server. Impersonation keeps the server credentials (which must be allowed to
+// impersonate users and groups) while Kubernetes RBAC is evaluated for the user and groups read from
+// the claims of the validated OAuth token.
+package impersonate
+
+import (
+	"container/list"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/containers/kubernetes-mcp-server/pkg/config"
+	internalk8s "github.com/containers/kubernetes-mcp-server/pkg/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/klog/v2"
+
+	localconfig "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+)
+
+// maxCachedClients is the maximum number of impersonating clients (target and identity combinations) kept in memory.
+const maxCachedClients = 128
+
+// Identity is the Kubernetes user impersonated on behalf of the authenticated MCP user.
+type Identity struct {
+	User   string
+	Groups []string
+}
+
+func (i Identity) String() string {
+	return fmt.Sprintf("%s (groups: %s)", i.User, strings.Join(i.Groups, ","))
+}
+
+// IdentityFromToken maps the claims of the provided JWT to the impersonated identity.
+//
+// The token signature is not verified, the token must have been verified by the authorization
+// middleware (with the authorization server keys or a TokenReview) before calling this function.
+func IdentityFromToken(cfg localconfig.ImpersonationConfig, token string) (*Identity, error) {
+	claims, err := parseClaims(token)
+	if err != nil {
+		return nil, err
+	}
+	user, ok := lookupClaim(claims, cfg.UserClaim).(string)
+	if !ok || user == "" {
+		return nil, fmt.Errorf("token has no %q claim to impersonate", cfg.UserClaim)
+	}
+	identity := &Identity{User: cfg.UserPrefix + user}
+	if cfg.GroupsClaim != "" {
+		switch groups := lookupClaim(claims, cfg.GroupsClaim).(type) {
+		case string:
+			identity.Groups = append(identity.Groups, cfg.GroupsPrefix+groups)
+		case []any:
+			for _, group := range groups {
+				if group, ok := group.(string); ok && group != "" {
+					identity.Groups = append(identity.Groups, cfg.GroupsPrefix+group)
+				}
+			}
+		}
+	}
+	for _, group := range cfg.ExtraGroups {
+		if !slices.Contains(identity.Groups, group) {
+			identity.Groups = append(identity.Groups, group)
+		}
+	}
+	return identity, nil
+}
+
+func parseClaims(token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("impersonation requires a JWT bearer token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JWT payload: %w", err)
+	}
+	claims := make(map[string]any)
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("failed to parse JWT claims: %w", err)
+	}
+	return claims, nil
+}
+
+// lookupClaim returns the value of the claim, nested claims are separated by dots (e.g. realm_access.roles).
+// Claims whose name contains dots are matched first.
+func lookupClaim(claims map[string]any, name string) any {
+	if value, ok := claims[name]; ok {
+		return value
+	}
+	head, rest, found := strings.Cut(name, ".")
+	if !found {
+		return nil
+	}
+	if nested, ok := claims[head].(map[string]any); ok {
+		return lookupClaim(nested, rest)
+	}
+	return nil
+}
+
+// KubeconfigSource provides the kubeconfig of the targets of the cluster providers whose targets aren't the
+// contexts of the server kubeconfig (e.g. the cluster registry).
+type KubeconfigSource interface {
+	// Kubeconfig returns a copy of the kubeconfig with the server credentials of the target, with the
+	// target as current context.
+	Kubeconfig(target string) (*clientcmdapi.Config, error)
+}
+
+// Clients creates and caches the impersonating Kubernetes clients for each target and identity.
+type Clients struct {
+	staticConfig *config.StaticConfig
+
+	mu       sync.Mutex
+	source   KubeconfigSource
+	order    *list.List
+	managers map[string]*list.Element
+}
+
+type cachedManager struct {
+	key     string
+	manager *internalk8s.Manager
+}
+
+// NewClients creates the impersonating client factory for the kubeconfig (or in-cluster configuration)
+// of the provided StaticConfig.
+func NewClients(staticConfig *config.StaticConfig) *Clients {
+	return &Clients{
+		staticConfig: staticConfig,
+		order:        list.New(),
+		managers:     make(map[string]*list.Element),
+	}
+}
+
+// Kubernetes returns a Kubernetes client for the target (kubeconfig context, empty for the default one)
+// that impersonates the provided identity, or uses the plain server credentials if the identity is nil.
+func (c *Clients) Kubernetes(target string, identity *Identity) (*internalk8s.Kubernetes, error) {
+	key := target
+	if identity != nil {
+		key = strings.Join(append([]string{target, identity.User}, identity.Groups...), "\x00")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.managers[key]; ok {
+		c.order.MoveToFront(element)
+		return deriveKubernetes(element.Value.(*cachedManager).manager)
+	}
+
+	manager, err := c.newManager(target, identity)
+	if err != nil {
+		return nil, err
+	}
+	klog.V(3).Infof("created Kubernetes client for target %q impersonating %v", target, identity)
+	c.managers[key] = c.order.PushFront(&cachedManager{key: key, manager: manager})
+	for c.order.Len() > maxCachedClients {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.managers, oldest.Value.(*cachedManager).key)
+		oldest.Value.(*cachedManager).manager.Close()
+	}
+	return deriveKubernetes(manager)
+}
+
+// SetKubeconfigSource sets the source of the kubeconfig of the targets (nil for the server kubeconfig),
+// discarding the cached clients.
+func (c *Clients) SetKubeconfigSource(source KubeconfigSource) {
+	c.Reset()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.source = source
+}
+
+// Reset discards the cached clients, e.g. after the kubeconfig changed.
+func (c *Clients) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, element := range c.managers {
+		element.Value.(*cachedManager).manager.Close()
+	}
+	c.order.Init()
+	c.managers = make(map[string]*list.Element)
+}
+
+func deriveKubernetes(manager *internalk8s.Manager) (*internalk8s.Kubernetes, error) {
+	// The impersonating managers are created without require_oauth, so the derived client
+	// uses the server credentials (with the impersonation settings) instead of a bearer token.
+	return manager.Derived(context.Background())
+}
+
+// newManager creates a kubernetes-mcp-server Manager for the target from a copy of the kubeconfig
+// that impersonates the identity.
+func (c *Clients) newManager(target string, identity *Identity) (*internalk8s.Manager, error) {
+	kubeconfig, err := c.kubeconfig(target)
+	if err != nil {
+		return nil, err
+	}
+	for _, kubeconfigContext := range kubeconfig.Contexts {
+		if kubeconfigContext.AuthInfo == "" {
+			kubeconfigContext.AuthInfo = "impersonated-user"
+		}
+		if _, ok := kubeconfig.AuthInfos[kubeconfigContext.AuthInfo]; !ok {
+			kubeconfig.AuthInfos[kubeconfigContext.AuthInfo] = clientcmdapi.NewAuthInfo()
+		}
+	}
+	if identity != nil {
+		for _, authInfo := range kubeconfig.AuthInfos {
+			authInfo.Impersonate = identity.User
+			authInfo.ImpersonateGroups = identity.Groups
+		}
+	}
+
+	staticConfig := *c.staticConfig
+	staticConfig.RequireOAuth = false
+	return newKubeconfigManager(&staticConfig, kubeconfig)
+}
+
+// newKubeconfigManager creates a kubernetes-mcp-server Manager for the kubeconfig with the exported constructor,
+// which loads the kubeconfig from a file. The file is written to a private temporary directory removed once the
+// manager is created, the manager keeps the loaded kubeconfig in memory.
+func newKubeconfigManager(staticConfig *config.StaticConfig, kubeconfig *clientcmdapi.Config) (*internalk8s.Manager, error) {
+	dir, err := os.MkdirTemp("", "impersonate-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the kubeconfig directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	staticConfig.KubeConfig = filepath.Join(dir, "kubeconfig")
+	if err := clientcmd.WriteToFile(*kubeconfig, staticConfig.KubeConfig); err != nil {
+		return nil, fmt.Errorf("failed to write the kubeconfig: %w", err)
+	}
+	return internalk8s.NewKubeconfigManager(staticConfig, "")
+}
+
+// kubeconfig returns the kubeconfig with the server credentials, with the target as current context.
+func (c *Clients) kubeconfig(target string) (*clientcmdapi.Config, error) {
+	if c.source != nil {
+		return c.source.Kubeconfig(target)
+	}
+	if internalk8s.IsInCluster(c.staticConfig) {
+		if target != "" {
+			return nil, fmt.Errorf("unable to impersonate for other context/cluster in-cluster")
+		}
+		restConfig, err := internalk8s.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create in-cluster kubernetes rest config: %w", err)
+		}
+		kubeconfig := clientcmdapi.NewConfig()
+		kubeconfig.Clusters["cluster"] = &clientcmdapi.Cluster{
+			Server:                   restConfig.Host,
+			CertificateAuthority:     restConfig.CAFile,
+			CertificateAuthorityData: restConfig.CAData,
+			InsecureSkipTLSVerify:    restConfig.Insecure,
+		}
+		kubeconfig.AuthInfos["user"] = &clientcmdapi.AuthInfo{
+			Token:     restConfig.BearerToken,
+			TokenFile: restConfig.BearerTokenFile,
+		}
+		kubeconfig.Contexts["in-cluster"] = &clientcmdapi.Context{Cluster: "cluster", AuthInfo: "user"}
+		kubeconfig.CurrentContext = "in-cluster"
+		return kubeconfig, nil
+	}
+
+	pathOptions := clientcmd.NewDefaultPathOptions()
+	if c.staticConfig.KubeConfig != "" {
+		pathOptions.LoadingRules.ExplicitPath = c.staticConfig.KubeConfig
+	}
+	kubeconfig, err := pathOptions.LoadingRules.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	if target != "" {
+		kubeconfig.CurrentContext = target
+	}
+	if _, ok := kubeconfig.Contexts[kubeconfig.CurrentContext]; !ok {
+		return nil, fmt.Errorf("failed to get kubeconfig context %q", kubeconfig.CurrentContext)
+	}
+	return kubeconfig, nil
+}
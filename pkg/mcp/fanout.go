@@ -0,0 +1,198 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	k8sapi "github.com/containers/kubernetes-mcp-server/pkg/api"
+	internalk8s "github.com/containers/kubernetes-mcp-server/pkg/kubernetes"
+	k8smcp "github.com/containers/kubernetes-mcp-server/pkg/mcp"
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/utils/ptr"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/auth"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/clusters"
+)
+
+// ClusterSelectorParameterName is the parameter of the read-only tools running against all the clusters
+// matching a label selector (e.g. env=prod,region=eu) instead of a single cluster.
+const ClusterSelectorParameterName = "cluster_selector"
+
+// labeledClusters is implemented by the cluster providers whose clusters have labels (the cluster registry).
+type labeledClusters interface {
+	Clusters() []*clusters.Cluster
+}
+
+// withClusterSelector adds the cluster selector parameter to the read-only cluster aware tools if the provider
+// has several clusters with labels.
+func withClusterSelector(p internalk8s.Provider, targets []string) k8smcp.ToolMutator {
+	registry, ok := p.(labeledClusters)
+	if !ok || len(targets) <= 1 {
+		return func(tool k8sapi.ServerTool) k8sapi.ServerTool { return tool }
+	}
+	description := clusterSelectorDescription(p.GetTargetParameterName(), registry.Clusters())
+	return func(tool k8sapi.ServerTool) k8sapi.ServerTool {
+		if !tool.IsClusterAware() || !ptr.Deref(tool.Tool.Annotations.ReadOnlyHint, false) || tool.Tool.InputSchema == nil {
+			return tool
+		}
+		schema := *tool.Tool.InputSchema
+		schema.Properties = maps.Clone(schema.Properties)
+		schema.Properties[ClusterSelectorParameterName] = &jsonschema.Schema{Type: "string", Description: description}
+		tool.Tool.InputSchema = &schema
+		return tool
+	}
+}
+
+// clusterSelectorDescription describes the cluster selector parameter with the labels of the clusters.
+func clusterSelectorDescription(targetParameterName string, registered []*clusters.Cluster) string {
+	values := map[string][]string{}
+	for _, cluster := range registered {
+		for key, value := range cluster.Labels {
+			if !slices.Contains(values[key], value) {
+				values[key] = append(values[key], value)
+			}
+		}
+	}
+	description := fmt.Sprintf("Optional label selector (e.g. env=prod,region=eu) running the tool against all the matching "+
+		"clusters instead of a single %s, the results are tagged by cluster. Not allowed together with %s",
+		targetParameterName, targetParameterName)
+	if len(values) == 0 {
+		return description
+	}
+	labelValues := make([]string, 0, len(values))
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		slices.Sort(values[key])
+		labelValues = append(labelValues, key+"="+strings.Join(values[key], "|"))
+	}
+	return description + ". The cluster labels are: " + strings.Join(labelValues, ", ")
+}
+
+// selectClusters returns the clusters of the provider matching the cluster selector of the tool call.
+func (s *state) selectClusters(p internalk8s.Provider, tool k8sapi.ServerTool, arguments toolCallRequest, selector string) ([]string, error) {
+	registry, ok := p.(labeledClusters)
+	if !ok {
+		return nil, fmt.Errorf("%s requires the cluster registry", ClusterSelectorParameterName)
+	}
+	if !ptr.Deref(tool.Tool.Annotations.ReadOnlyHint, false) {
+		return nil, fmt.Errorf("%s is only supported by the read-only tools", ClusterSelectorParameterName)
+	}
+	if cluster := arguments.GetString(p.GetTargetParameterName(), ""); cluster != "" {
+		return nil, fmt.Errorf("%s and %s are mutually exclusive", p.GetTargetParameterName(), ClusterSelectorParameterName)
+	}
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: %w", ClusterSelectorParameterName, selector, err)
+	}
+	var targets []string
+	for _, cluster := range registry.Clusters() {
+		if parsed.Matches(labels.Set(cluster.Labels)) {
+			targets = append(targets, cluster.Name)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no cluster matches the %s %q", ClusterSelectorParameterName, selector)
+	}
+	if maxClusters := s.configuration.Extensions.FanOut.MaxClusters; maxClusters > 0 && len(targets) > maxClusters {
+		return nil, fmt.Errorf("the %s %q matches %d clusters, more than the %d allowed", ClusterSelectorParameterName, selector, len(targets), maxClusters)
+	}
+	return targets, nil
+}
+
+// clusterResult is the result of a tool call fanned out to a cluster.
+type clusterResult struct {
+	content string
+	err     error
+}
+
+// fanOut runs the read-only tool against the clusters matching the selector, at most max_concurrency at a time,
+// and returns their results merged and tagged by cluster. The failures of the clusters are reported in the
+// result, which is an error only if all the clusters failed.
+func (s *Server) fanOut(ctx context.Context, st *state, p internalk8s.Provider, tool k8sapi.ServerTool, arguments toolCallRequest,
+	selector string, principal *auth.Principal) *mcp.CallToolResult {
+	targets, err := st.selectClusters(p, tool, arguments, selector)
+	if err != nil {
+		return st.newTextResult("", err)
+	}
+	timeout, _ := st.configuration.Extensions.FanOut.ClusterTimeoutDuration()
+	semaphore := make(chan struct{}, max(1, st.configuration.Extensions.FanOut.MaxConcurrency))
+	results := make([]clusterResult, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Go(func() {
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+				results[i].err = ctx.Err()
+				return
+			}
+			defer func() { <-semaphore }()
+			results[i].content, results[i].err = s.callCluster(ctx, st, p, tool, arguments, target, principal, timeout)
+		})
+	}
+	wg.Wait()
+
+	failed := 0
+	var merged strings.Builder
+	for i, target := range targets {
+		if results[i].err != nil {
+			failed++
+			_, _ = fmt.Fprintf(&merged, "\n--- cluster: %s (failed) ---\n%s\n", target, results[i].err.Error())
+			continue
+		}
+		_, _ = fmt.Fprintf(&merged, "\n--- cluster: %s ---\n%s\n", target, strings.TrimSuffix(results[i].content, "\n"))
+	}
+	summary := fmt.Sprintf("%s %q matched %d clusters: %d succeeded, %d failed\n",
+		ClusterSelectorParameterName, selector, len(targets), len(targets)-failed, failed)
+	result := st.newTextResult(summary+merged.String(), nil)
+	result.IsError = failed == len(targets)
+	return result
+}
+
+// callCluster runs the tool against the cluster, the clusters not answering within the timeout (if any) fail.
+// The tool handler is given the context of the timeout and is waited for, so it doesn't outlive the tool call.
+func (s *Server) callCluster(ctx context.Context, st *state, p internalk8s.Provider, tool k8sapi.ServerTool, arguments toolCallRequest,
+	cluster string, principal *auth.Principal, timeout time.Duration) (string, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	content, err := s.callClusterHandler(ctx, st, p, tool, arguments, cluster, principal)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("no answer within %s", timeout)
+	}
+	return content, err
+}
+
+// callClusterHandler calls the tool handler with the arguments targeting the cluster.
+func (s *Server) callClusterHandler(ctx context.Context, st *state, p internalk8s.Provider, tool k8sapi.ServerTool, arguments toolCallRequest,
+	cluster string, principal *auth.Principal) (string, error) {
+	k, err := st.derivedKubernetes(ctx, p, cluster, principal)
+	if err != nil {
+		return "", err
+	}
+	args := maps.Clone(arguments)
+	delete(args, ClusterSelectorParameterName)
+	args[p.GetTargetParameterName()] = cluster
+	result, err := tool.Handler(k8sapi.ToolHandlerParams{
+		Context:         ctx,
+		Kubernetes:      k,
+		ToolCallRequest: args,
+		ListOutput:      st.configuration.ListOutput(),
+	})
+	if err == nil {
+		err = result.Error
+	}
+	if err != nil {
+		return "", err
+	}
+	return result.Content, nil
+}
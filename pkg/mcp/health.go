@@ -0,0 +1,44 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+
+	internalk8s "github.com/containers/kubernetes-mcp-server/pkg/kubernetes"
+)
+
+// CheckKubeconfig returns the error of the last kubeconfig load, nil if the Kubernetes cluster provider loaded.
+// A kubeconfig change that fails to load is reported even though the server keeps the previous provider.
+func (s *Server) CheckKubeconfig(context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.providerErr != nil {
+		return fmt.Errorf("failed to load the kubeconfig: %w", s.providerErr)
+	}
+	return nil
+}
+
+// CheckCluster checks the default cluster is reachable with the server credentials,
+// whatever the credentials used by the tool calls (OAuth tokens, impersonation).
+func (s *Server) CheckCluster(ctx context.Context) error {
+	p := s.provider()
+	if p == nil {
+		return fmt.Errorf("kubernetes cluster provider is not initialized")
+	}
+	target := p.GetDefaultTarget()
+	var k *internalk8s.Kubernetes
+	var err error
+	if clients := s.state().clients; clients != nil {
+		k, err = clients.Kubernetes(target, nil)
+	} else {
+		k, err = p.GetDerivedKubernetes(ctx, target)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create the client of the default cluster: %w", err)
+	}
+	err = k.AccessControlClientset().DiscoveryClient().RESTClient().Get().AbsPath("/version").Do(ctx).Error()
+	if err != nil {
+		return fmt.Errorf("the default cluster is unreachable: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package mcp
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	k8sapi "github.com/containers/kubernetes-mcp-server/pkg/api"
+
+	localtoolsets "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/toolsets"
+)
+
+// unscopedTools are the built-in tools operating on namespaced resources without a namespace argument,
+// they can't be restricted to the configured namespaces.
+var unscopedTools = []string{"pods_list", "resources_create_or_update"}
+
+// namespaceScoped restricts the tool to the configured namespaces, returning false if the tool can't be restricted.
+//
+// The namespace argument defaults to the first configured namespace and calls for other namespaces
+// (or all namespaces) are rejected. This is a guardrail for the agents, not a replacement for RBAC.
+func (c *Configuration) namespaceScoped(tool k8sapi.ServerTool) (k8sapi.ServerTool, bool) {
+	namespaces := c.Extensions.Namespaces
+	if len(namespaces) == 0 {
+		return tool, true
+	}
+	if tool.Tool.InputSchema == nil || tool.Tool.InputSchema.Properties["namespace"] == nil {
+		return tool, !slices.Contains(unscopedTools, tool.Tool.Name)
+	}
+	handler := tool.Handler
+	tool.Handler = func(params k8sapi.ToolHandlerParams) (*k8sapi.ToolCallResult, error) {
+		var err error
+		params = localtoolsets.WithArguments(params, func(args map[string]any) {
+			if allNamespaces, _ := args["all_namespaces"].(bool); allNamespaces {
+				err = fmt.Errorf("all_namespaces is not allowed, namespaces are restricted to: %s", strings.Join(namespaces, ", "))
+				return
+			}
+			namespace, _ := args["namespace"].(string)
+			if namespace == "" {
+				args["namespace"] = namespaces[0]
+			} else if !slices.Contains(namespaces, namespace) {
+				err = fmt.Errorf("namespace %q is not allowed, namespaces are restricted to: %s", namespace, strings.Join(namespaces, ", "))
+			}
+		})
+		if err != nil {
+			return k8sapi.NewToolCallResult("", err), nil
+		}
+		return handler(params)
+	}
+	return tool, true
+}
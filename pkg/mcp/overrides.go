@@ -0,0 +1,103 @@
+package mcp
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	k8sapi "github.com/containers/kubernetes-mcp-server/pkg/api"
+	internalk8s "github.com/containers/kubernetes-mcp-server/pkg/kubernetes"
+	"github.com/google/jsonschema-go/jsonschema"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+)
+
+// clusterRestricted restricts the cluster aware tool of the toolset to the targets allowed by the cluster overrides,
+// returning false if the tool isn't allowed on any target.
+//
+// The target parameter only offers the allowed targets, it's required if the default target isn't allowed,
+// and the calls for the other targets are rejected.
+func (c *Configuration) clusterRestricted(toolset string, tool k8sapi.ServerTool, p internalk8s.Provider, targets []string) (k8sapi.ServerTool, bool) {
+	if !tool.IsClusterAware() || len(c.Extensions.ClusterOverrides) == 0 {
+		return tool, true
+	}
+	allowed := make([]string, 0, len(targets))
+	for _, target := range targets {
+		if c.isToolAllowedOn(target, toolset, tool) {
+			allowed = append(allowed, target)
+		}
+	}
+	if len(allowed) == 0 {
+		return tool, false
+	}
+	if len(allowed) == len(targets) {
+		return tool, true
+	}
+	parameter, defaultTarget := p.GetTargetParameterName(), p.GetDefaultTarget()
+	schema := jsonschema.Schema{Type: "object"}
+	if tool.Tool.InputSchema != nil {
+		schema = *tool.Tool.InputSchema
+	}
+	schema.Properties = maps.Clone(schema.Properties)
+	if schema.Properties == nil {
+		schema.Properties = make(map[string]*jsonschema.Schema)
+	}
+	property := &jsonschema.Schema{Type: "string"}
+	for _, target := range allowed {
+		property.Enum = append(property.Enum, target)
+	}
+	if slices.Contains(allowed, defaultTarget) {
+		property.Description = fmt.Sprintf("Optional parameter selecting which %s to run the tool in, the tool is only allowed "+
+			"on these. Defaults to %s if not set", parameter, defaultTarget)
+	} else {
+		property.Description = fmt.Sprintf("The %s to run the tool in, the tool is only allowed on these", parameter)
+		schema.Required = append(slices.Clone(schema.Required), parameter)
+	}
+	schema.Properties[parameter] = property
+	tool.Tool.InputSchema = &schema
+
+	handler := tool.Handler
+	tool.Handler = func(params k8sapi.ToolHandlerParams) (*k8sapi.ToolCallResult, error) {
+		target, _ := params.GetArguments()[parameter].(string)
+		if target == "" {
+			target = defaultTarget
+		}
+		if !slices.Contains(allowed, target) {
+			return k8sapi.NewToolCallResult("", fmt.Errorf("tool %s is not allowed on the %s %q, it's only allowed on: %s",
+				tool.Tool.Name, parameter, target, strings.Join(allowed, ", "))), nil
+		}
+		return handler(params)
+	}
+	return tool, true
+}
+
+// isToolAllowedOn returns false if the overrides of the target disallow the tool of the toolset.
+func (c *Configuration) isToolAllowedOn(target, toolset string, tool k8sapi.ServerTool) bool {
+	override, ok := c.Extensions.ClusterOverrides[target]
+	if !ok {
+		return true
+	}
+	if override.ReadOnly && !ptr.Deref(tool.Tool.Annotations.ReadOnlyHint, false) {
+		return false
+	}
+	if override.DisableDestructive && ptr.Deref(tool.Tool.Annotations.DestructiveHint, false) {
+		return false
+	}
+	if override.Toolsets != nil && !slices.Contains(override.Toolsets, toolset) {
+		return false
+	}
+	if override.EnabledTools != nil && !slices.Contains(override.EnabledTools, tool.Tool.Name) {
+		return false
+	}
+	return !slices.Contains(override.DisabledTools, tool.Tool.Name)
+}
+
+// warnUnknownClusterOverrides logs the cluster overrides not matching any target of the provider.
+func (c *Configuration) warnUnknownClusterOverrides(p internalk8s.Provider, targets []string) {
+	for _, name := range slices.Sorted(maps.Keys(c.Extensions.ClusterOverrides)) {
+		if !slices.Contains(targets, name) {
+			klog.Warningf("The cluster_overrides.%s configuration doesn't match any %s", name, p.GetTargetParameterName())
+		}
+	}
+}
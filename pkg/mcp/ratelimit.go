@@ -0,0 +1,111 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	internalk8s "github.com/containers/kubernetes-mcp-server/pkg/kubernetes"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+
+	localconfig "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/impersonate"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/ratelimit"
+)
+
+// rateLimitMiddleware enforces the rate limits and concurrency quotas of the client sending the request.
+//
+// Rejected tool calls return an error result with the retry hint in the retryAfterSeconds metadata,
+// other requests fail with an error including the retry hint.
+func (s *Server) rateLimitMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		st := s.state()
+		if st.limiter == nil || strings.HasPrefix(method, "notifications/") {
+			return next(ctx, method, req)
+		}
+		key := s.rateLimitKey(ctx, st, req)
+		if err := st.limiter.Allow(key); err != nil {
+			return rateLimited(key, method, err)
+		}
+		if method != "tools/call" {
+			return next(ctx, method, req)
+		}
+		ctx, done, err := st.limiter.StartToolCall(ctx, key)
+		if err != nil {
+			return rateLimited(key, method, err)
+		}
+		defer done()
+		return next(ctx, method, req)
+	}
+}
+
+func rateLimited(key, method string, err error) (mcp.Result, error) {
+	klog.V(2).Infof("Rate limited %s request from %s: %v", method, key, err)
+	var limitErr *ratelimit.Error
+	if method != "tools/call" || !errors.As(err, &limitErr) {
+		return nil, err
+	}
+	return &mcp.CallToolResult{
+		Meta:    mcp.Meta{"retryAfterSeconds": limitErr.RetryAfterSeconds()},
+		IsError: true,
+		Content: []mcp.Content{&mcp.TextContent{Text: err.Error()}},
+	}, nil
+}
+
+// rateLimitKey identifies the client of the request by the authenticated identity (API key, client certificate
+// or OAuth user of a verified token), falling back to the address of the client or the session.
+//
+// The address comes first for the unauthenticated clients since the session ID of the stateless streamable HTTP
+// requests is chosen by the client (a new one for each request if none).
+func (s *Server) rateLimitKey(ctx context.Context, st *state, req mcp.Request) string {
+	info := s.requestInfo(ctx, req)
+	if st.limiter.KeyBy() == localconfig.RateLimitKeyByIdentity {
+		if info.principal != nil {
+			return info.principal.Method + ":" + info.principal.Name
+		}
+		if user := st.verifiedTokenUser(info.header); user != "" {
+			return "user:" + user
+		}
+		if host := remoteHost(info.remoteAddress); host != "" {
+			return "address:" + host
+		}
+	}
+	if session := req.GetSession(); session != nil && session.ID() != "" {
+		return "session:" + session.ID()
+	}
+	if host := remoteHost(info.remoteAddress); host != "" {
+		return "address:" + host
+	}
+	return "anonymous"
+}
+
+// verifiedTokenUser returns the user of the OAuth bearer token of the request, if the token was verified by the
+// authorization middleware (with the authorization server keys or a TokenReview).
+func (st *state) verifiedTokenUser(header http.Header) string {
+	staticConfig := st.configuration.StaticConfig
+	if !staticConfig.RequireOAuth || (staticConfig.AuthorizationURL == "" && !staticConfig.ValidateToken) {
+		return ""
+	}
+	authorization, _ := withRequestAuthorization(context.Background(), header).Value(internalk8s.OAuthAuthorizationHeader).(string)
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok {
+		return ""
+	}
+	identity, err := impersonate.IdentityFromToken(st.configuration.Extensions.Impersonation, token)
+	if err != nil {
+		return ""
+	}
+	return identity.User
+}
+
+// remoteHost returns the host of the remote address of a client, without the port which changes with each
+// connection.
+func remoteHost(address string) string {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
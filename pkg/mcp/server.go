@@ -3,14 +3,457 @@ package mcp
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
 
 	k8sapi "github.com/containers/kubernetes-mcp-server/pkg/api"
-	localapi "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/api"
+	"github.com/containers/kubernetes-mcp-server/pkg/config"
+	internalk8s "github.com/containers/kubernetes-mcp-server/pkg/kubernetes"
+	k8smcp "github.com/containers/kubernetes-mcp-server/pkg/mcp"
+	"github.com/containers/kubernetes-mcp-server/pkg/output"
+	"github.com/containers/kubernetes-mcp-server/pkg/version"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
+	authenticationapiv1 "k8s.io/api/authentication/v1"
+	"k8s.io/utils/ptr"
+
+	localapi "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/api"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/auth"
+	localconfig "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/impersonate"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/ratelimit"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/redact"
+	localtoolsets "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/toolsets"
 )
 
-// RegisterToolsetResources registers MCP resources from toolsets that implement ResourceProvider
-func RegisterToolsetResources(mcpServer *mcp.Server, toolsets []k8sapi.Toolset) error {
+// Configuration holds the kubernetes-mcp-server StaticConfig together with the extension configuration.
+type Configuration struct {
+	*config.StaticConfig
+	Extensions *localconfig.Config
+
+	listOutput output.Output
+	toolsets   []k8sapi.Toolset
+}
+
+// Toolsets returns the toolsets enabled in the StaticConfig, resolved on the first call.
+func (c *Configuration) Toolsets() []k8sapi.Toolset {
+	if c.toolsets == nil {
+		for _, toolset := range c.StaticConfig.Toolsets {
+			c.toolsets = append(c.toolsets, localtoolsets.ToolsetFromString(toolset))
+		}
+	}
+	return c.toolsets
+}
+
+// ListOutput returns the output format for resource list operations, resolved on the first call.
+func (c *Configuration) ListOutput() output.Output {
+	if c.listOutput == nil {
+		c.listOutput = output.FromString(c.StaticConfig.ListOutput)
+	}
+	return c.listOutput
+}
+
+// toolsetTools returns the tools of the toolset, built with the toolset configuration if it's configurable.
+func (c *Configuration) toolsetTools(toolset k8sapi.Toolset, o internalk8s.Openshift) ([]k8sapi.ServerTool, error) {
+	configurable, ok := toolset.(localapi.ConfigurableToolset)
+	if !ok {
+		return toolset.GetTools(o), nil
+	}
+	cfg, err := c.Extensions.ToolsetConfig(configurable)
+	if err != nil {
+		return nil, err
+	}
+	return configurable.GetConfiguredTools(o, cfg), nil
+}
+
+func (c *Configuration) isToolApplicable(tool k8sapi.ServerTool) bool {
+	if c.ReadOnly && !ptr.Deref(tool.Tool.Annotations.ReadOnlyHint, false) {
+		return false
+	}
+	if c.DisableDestructive && ptr.Deref(tool.Tool.Annotations.DestructiveHint, false) {
+		return false
+	}
+	if c.EnabledTools != nil && !slices.Contains(c.EnabledTools, tool.Tool.Name) {
+		return false
+	}
+	if c.DisabledTools != nil && slices.Contains(c.DisabledTools, tool.Tool.Name) {
+		return false
+	}
+	return true
+}
+
+// Server is the MCP server exposing the kubernetes-mcp-server toolsets (and any custom toolsets)
+// through the Model Context Protocol SDK.
+//
+// It replicates the kubernetes-mcp-server server behavior while providing the extension points
+// (e.g. output redaction) that aren't available in the upstream implementation.
+type Server struct {
+	server *mcp.Server
+
+	// reloadMu serializes the configuration and kubeconfig reloads
+	reloadMu     sync.Mutex
+	mu           sync.RWMutex
+	current      *state
+	p            internalk8s.Provider
+	enabledTools []string
+	resources    []string
+	// providerErr is the error of the last Kubernetes cluster provider (kubeconfig) load, nil if it succeeded
+	providerErr error
+
+	// sessionHeaders holds the header of the request that established a session
+	// for transports that don't propagate it with each request (SSE).
+	sessionHeaders sync.Map
+	// drain tracks the in-flight tool calls for the graceful shutdown
+	drain drainer
+}
+
+// state holds the configuration and the components derived from it, replaced as a whole when the
+// configuration is reloaded. Request handlers use the state snapshot at the time of the request.
+type state struct {
+	configuration *Configuration
+	redactor      *redact.Redactor
+	// limiter enforces the rate limits per client (nil if disabled)
+	limiter *ratelimit.Limiter
+	// clients provides the Kubernetes clients impersonating the authenticated users, the clients
+	// for API key and client certificate principals, and the server credentials clients of the health checks
+	// when OAuth is required (nil if none is enabled)
+	clients *impersonate.Clients
+}
+
+func newState(configuration Configuration, previous *state) (*state, error) {
+	if configuration.Extensions == nil {
+		configuration.Extensions = localconfig.Default()
+	}
+	redactor, err := redact.New(configuration.Extensions.Redaction)
+	if err != nil {
+		return nil, err
+	}
+	// resolved before the configuration is shared, the concurrent tool calls only read them
+	configuration.Toolsets()
+	configuration.ListOutput()
+	st := &state{configuration: &configuration, redactor: redactor}
+	// keep the rate limits state if the limits didn't change
+	if previous != nil && reflect.DeepEqual(previous.configuration.Extensions.RateLimit, configuration.Extensions.RateLimit) {
+		st.limiter = previous.limiter
+	} else {
+		st.limiter = ratelimit.New(configuration.Extensions.RateLimit)
+	}
+	if configuration.Extensions.Impersonation.Enabled || configuration.Extensions.Authentication.Enabled() ||
+		configuration.StaticConfig.RequireOAuth {
+		st.clients = impersonate.NewClients(configuration.StaticConfig)
+	}
+	return st, nil
+}
+
+// NewServer creates a new MCP server for the provided configuration.
+func NewServer(configuration Configuration) (*Server, error) {
+	st, err := newState(configuration, nil)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		server: mcp.NewServer(
+			&mcp.Implementation{Name: version.BinaryName, Version: version.Version},
+			&mcp.ServerOptions{HasTools: true},
+		),
+		current: st,
+	}
+	s.server.AddReceivingMiddleware(s.drainMiddleware, toolCallLoggingMiddleware, s.rateLimitMiddleware)
+	if err := s.reloadKubernetesClusterProvider(st); err != nil {
+		return nil, err
+	}
+	if err := s.reloadResources(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload applies the configuration at runtime: the toolsets, the tool filters (read-only, destructive,
+// enabled and disabled tools), the list output, the denied resources, the redaction and the rate limits.
+// The tools and resources of the connected clients are updated (notifying list changes).
+// The transport settings (port, TLS) aren't reloaded, and the configurations changing the authentication of the
+// clients are rejected.
+func (s *Server) Reload(configuration Configuration) error {
+	previous := s.state()
+	st, err := newState(configuration, previous)
+	if err != nil {
+		return err
+	}
+	if changed := authenticationChanges(previous.configuration, st.configuration); len(changed) > 0 {
+		return fmt.Errorf("changes to %s require a restart of the server", strings.Join(changed, ", "))
+	}
+	// the state is only replaced once the provider of the configuration is loaded
+	if err := s.reloadKubernetesClusterProvider(st); err != nil {
+		return err
+	}
+	if previous.clients != nil {
+		previous.clients.Reset()
+	}
+	return s.reloadResources()
+}
+
+// authenticationChanges returns the changed settings authenticating the clients. The HTTP middleware verifying
+// the clients is set up once with the startup configuration, applying them to the tool handlers only would
+// e.g. impersonate the users of tokens that aren't verified.
+func authenticationChanges(previous, configuration *Configuration) []string {
+	previousStatic, static := previous.StaticConfig, configuration.StaticConfig
+	settings := []struct {
+		name    string
+		changed bool
+	}{
+		{"require_oauth", previousStatic.RequireOAuth != static.RequireOAuth},
+		{"authorization_url", previousStatic.AuthorizationURL != static.AuthorizationURL},
+		{"validate_token", previousStatic.ValidateToken != static.ValidateToken},
+		{"oauth_audience", previousStatic.OAuthAudience != static.OAuthAudience},
+		{"certificate_authority", previousStatic.CertificateAuthority != static.CertificateAuthority},
+		{"authentication", !reflect.DeepEqual(previous.Extensions.Authentication, configuration.Extensions.Authentication)},
+		{"impersonation", !reflect.DeepEqual(previous.Extensions.Impersonation, configuration.Extensions.Impersonation)},
+	}
+	changed := make([]string, 0)
+	for _, setting := range settings {
+		if setting.changed {
+			changed = append(changed, setting.name)
+		}
+	}
+	return changed
+}
+
+// state returns the current configuration state.
+func (s *Server) state() *state {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// reloadKubernetesClusterProvider loads the Kubernetes cluster provider and the tools of the state (the current
+// one if nil), replacing the current provider, tools and state once they're all loaded (the current ones are
+// kept on failure).
+func (s *Server) reloadKubernetesClusterProvider(st *state) (err error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.providerErr = err
+		s.mu.Unlock()
+	}()
+	if st == nil {
+		st = s.state()
+	}
+	ctx := context.Background()
+	p, err := internalk8s.NewProvider(st.configuration.StaticConfig)
+	if err != nil {
+		return err
+	}
+
+	targets, err := p.GetTargets(ctx)
+	if err != nil {
+		p.Close()
+		return err
+	}
+
+	st.configuration.warnUnknownClusterOverrides(p, targets)
+
+	filter := k8smcp.CompositeFilter(
+		st.configuration.isToolApplicable,
+		k8smcp.ShouldIncludeTargetListTool(p.GetTargetParameterName(), targets),
+	)
+
+	targetMutator := k8smcp.WithTargetParameter(
+		p.GetDefaultTarget(),
+		p.GetTargetParameterName(),
+		targets,
+	)
+	selectorMutator := withClusterSelector(p, targets)
+	mutator := func(tool k8sapi.ServerTool) k8sapi.ServerTool { return selectorMutator(targetMutator(tool)) }
+
+	applicableTools := make([]k8sapi.ServerTool, 0)
+	for _, toolset := range st.configuration.Toolsets() {
+		tools, err := st.configuration.toolsetTools(toolset, p)
+		if err != nil {
+			p.Close()
+			return err
+		}
+		for _, tool := range tools {
+			tool, allowed := st.configuration.clusterRestricted(toolset.GetName(), mutator(tool), p, targets)
+			if !allowed {
+				continue
+			}
+			tool, scoped := st.configuration.namespaceScoped(tool)
+			if !scoped || !filter(tool) {
+				continue
+			}
+			applicableTools = append(applicableTools, tool)
+		}
+	}
+
+	mcpTools := make([]*mcp.Tool, 0, len(applicableTools))
+	for _, tool := range applicableTools {
+		mcpTool, err := ServerToolToMcpTool(tool)
+		if err != nil {
+			p.Close()
+			return err
+		}
+		mcpTools = append(mcpTools, mcpTool)
+	}
+
+	s.mu.Lock()
+	// close the old provider
+	if s.p != nil {
+		s.p.Close()
+	}
+	s.p = p
+	s.current = st
+	if st.clients != nil {
+		// the kubeconfig changed, discard the cached clients
+		st.clients.SetKubeconfigSource(kubeconfigSource(p))
+	}
+	previousTools := s.enabledTools
+	s.enabledTools = make([]string, 0, len(applicableTools))
+	for _, tool := range applicableTools {
+		s.enabledTools = append(s.enabledTools, tool.Tool.Name)
+	}
+	s.mu.Unlock()
+
+	s.setTools(previousTools, applicableTools, mcpTools)
+
+	// start new watch
+	p.WatchTargets(func() error { return s.reloadKubernetesClusterProvider(nil) })
+	return nil
+}
+
+// reloadResources replaces the registered toolset resources with the ones of the current toolsets.
+func (s *Server) reloadResources() error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	st := s.state()
+	if len(s.resources) > 0 {
+		s.server.RemoveResources(s.resources...)
+	}
+	resources, err := registerToolsetResources(s.server, st.configuration.Toolsets(), st.redactor)
+	s.resources = resources
+	if err != nil {
+		return fmt.Errorf("failed to register toolset resources: %w", err)
+	}
+	return nil
+}
+
+// setTools replaces the previously registered tools with the provided ones and their MCP definitions.
+func (s *Server) setTools(previousTools []string, tools []k8sapi.ServerTool, mcpTools []*mcp.Tool) {
+	removedTools := make([]string, 0)
+	for _, name := range previousTools {
+		if !slices.ContainsFunc(tools, func(tool k8sapi.ServerTool) bool { return tool.Tool.Name == name }) {
+			removedTools = append(removedTools, name)
+		}
+	}
+	if len(removedTools) > 0 {
+		s.server.RemoveTools(removedTools...)
+	}
+	for i, tool := range tools {
+		s.server.AddTool(mcpTools[i], s.toolHandler(tool))
+	}
+}
+
+// kubeconfigSource returns the source of the kubeconfig of the targets of the provider for the impersonating
+// clients, nil if its targets are the contexts of the server kubeconfig.
+func kubeconfigSource(p internalk8s.Provider) impersonate.KubeconfigSource {
+	if source, ok := p.(impersonate.KubeconfigSource); ok {
+		return source
+	}
+	return nil
+}
+
+// provider returns the current Kubernetes cluster provider.
+func (s *Server) provider() internalk8s.Provider {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.p
+}
+
+// ServeStdio serves the MCP server over the standard input/output streams.
+func (s *Server) ServeStdio(ctx context.Context) error {
+	return s.server.Run(ctx, &mcp.StdioTransport{})
+}
+
+// McpServer returns the underlying Model Context Protocol SDK server.
+// Used by the HTTP transports to create the streamable HTTP and SSE handlers.
+func (s *Server) McpServer() *mcp.Server {
+	return s.server
+}
+
+// requestInfo is the information of the HTTP request of an MCP request, or of the request that established
+// its session.
+type requestInfo struct {
+	header http.Header
+	// principal is the client authenticated with an API key or a client certificate, nil otherwise
+	principal *auth.Principal
+	// remoteAddress is the network address of the client
+	remoteAddress string
+}
+
+// Connect connects the server over the provided transport, the header and the principal (in the context)
+// of the request establishing the session are used to authenticate the Kubernetes API calls of the session.
+func (s *Server) Connect(ctx context.Context, transport mcp.Transport, header http.Header) (*mcp.ServerSession, error) {
+	session, err := s.server.Connect(ctx, transport, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.sessionHeaders.Store(session, header)
+	go func() {
+		_ = session.Wait()
+		s.sessionHeaders.Delete(session)
+	}()
+	return session, nil
+}
+
+// KubernetesApiVerifyToken verifies the given token with the audience by
+// sending an TokenReview request to API Server for the specified cluster.
+func (s *Server) KubernetesApiVerifyToken(ctx context.Context, cluster, token, audience string) (*authenticationapiv1.UserInfo, []string, error) {
+	p := s.provider()
+	if p == nil {
+		return nil, nil, fmt.Errorf("kubernetes cluster provider is not initialized")
+	}
+	return p.VerifyToken(ctx, cluster, token, audience)
+}
+
+// GetTargetParameterName returns the parameter name used for target identification in MCP requests
+func (s *Server) GetTargetParameterName() string {
+	p := s.provider()
+	if p == nil {
+		return "" // fallback for uninitialized provider
+	}
+	return p.GetTargetParameterName()
+}
+
+// GetEnabledTools returns the names of the tools exposed by the server.
+func (s *Server) GetEnabledTools() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.enabledTools)
+}
+
+// Close releases the resources held by the server.
+func (s *Server) Close() {
+	if p := s.provider(); p != nil {
+		p.Close()
+	}
+	if st := s.state(); st.clients != nil {
+		st.clients.Reset()
+	}
+}
+
+// RegisterToolsetResources registers MCP resources from toolsets that implement ResourceProvider.
+// The resource contents are filtered through the provided redactor (which may be nil).
+func RegisterToolsetResources(mcpServer *mcp.Server, toolsets []k8sapi.Toolset, redactor *redact.Redactor) error {
+	_, err := registerToolsetResources(mcpServer, toolsets, redactor)
+	return err
+}
+
+// registerToolsetResources registers the toolset resources and returns the URIs of the registered resources.
+func registerToolsetResources(mcpServer *mcp.Server, toolsets []k8sapi.Toolset, redactor *redact.Redactor) ([]string, error) {
+	uris := make([]string, 0)
 	for _, toolset := range toolsets {
 		if resourceProvider, ok := toolset.(localapi.ResourceProvider); ok {
 			err := resourceProvider.RegisterResources(func(uri, name, mimeType string, handler func(context.Context) (string, error)) error {
@@ -29,18 +472,19 @@ func RegisterToolsetResources(mcpServer *mcp.Server, toolsets []k8sapi.Toolset)
 							{
 								URI:      uri,
 								MIMEType: mimeType,
-								Text:     content,
+								Text:     redactor.Redact(content),
 							},
 						},
 					}, nil
 				}
 				mcpServer.AddResource(resource, resourceHandler)
+				uris = append(uris, uri)
 				return nil
 			})
 			if err != nil {
-				return err
+				return uris, err
 			}
 		}
 	}
-	return nil
+	return uris, nil
 }
@@ -0,0 +1,108 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+)
+
+// ErrShuttingDown is returned for the requests refused while the server is shutting down.
+var ErrShuttingDown = errors.New("the server is shutting down, retry on another instance")
+
+// drainer tracks the in-flight tool calls, so that on shutdown new sessions and tool calls are refused
+// while the in-flight tool calls complete.
+type drainer struct {
+	mu       sync.Mutex
+	draining bool
+	calls    int
+	// drained is closed once draining with no tool call in flight
+	drained chan struct{}
+}
+
+// start registers a tool call, returning false if the server is draining.
+func (d *drainer) start() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return false
+	}
+	d.calls++
+	return true
+}
+
+// done unregisters a tool call started with start.
+func (d *drainer) done() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls--
+	if d.draining && d.calls == 0 {
+		close(d.drained)
+	}
+}
+
+// drain stops accepting tool calls, the returned channel is closed once no tool call is in flight.
+func (d *drainer) drain() <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.draining {
+		d.draining = true
+		d.drained = make(chan struct{})
+		if d.calls == 0 {
+			close(d.drained)
+		}
+	}
+	return d.drained
+}
+
+func (d *drainer) isDraining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.draining
+}
+
+func (d *drainer) inFlight() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.calls
+}
+
+// drainMiddleware refuses the new sessions and tool calls once the server is shutting down,
+// and tracks the in-flight tool calls.
+func (s *Server) drainMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		switch method {
+		case "initialize":
+			if s.drain.isDraining() {
+				return nil, ErrShuttingDown
+			}
+		case "tools/call":
+			if !s.drain.start() {
+				return &mcp.CallToolResult{
+					IsError: true,
+					Content: []mcp.Content{&mcp.TextContent{Text: ErrShuttingDown.Error()}},
+				}, nil
+			}
+			defer s.drain.done()
+		}
+		return next(ctx, method, req)
+	}
+}
+
+// Shutdown stops accepting new sessions and tool calls, then waits for the in-flight tool calls to
+// complete until the context is done. The sessions aren't closed, the transports close them once drained.
+func (s *Server) Shutdown(ctx context.Context) error {
+	drained := s.drain.drain()
+	if calls := s.drain.inFlight(); calls > 0 {
+		klog.V(0).Infof("Waiting for %d in-flight tool calls to complete", calls)
+	}
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d tool calls still in progress: %w", s.drain.inFlight(), ctx.Err())
+	}
+}
@@ -0,0 +1,212 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	k8sapi "github.com/containers/kubernetes-mcp-server/pkg/api"
+	internalk8s "github.com/containers/kubernetes-mcp-server/pkg/kubernetes"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/auth"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/impersonate"
+)
+
+// toolCallRequest implements the kubernetes-mcp-server ToolCallRequest for the decoded tool call arguments.
+type toolCallRequest map[string]any
+
+func (r toolCallRequest) GetArguments() map[string]any {
+	return r
+}
+
+// GetString returns the string argument with the provided name, or the default value if not set.
+func (r toolCallRequest) GetString(name, defaultValue string) string {
+	if value, ok := r[name].(string); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// ServerToolToMcpTool converts a kubernetes-mcp-server ServerTool definition to a Model Context Protocol SDK Tool.
+func ServerToolToMcpTool(tool k8sapi.ServerTool) (*mcp.Tool, error) {
+	mcpTool := &mcp.Tool{
+		Name:        tool.Tool.Name,
+		Description: tool.Tool.Description,
+		Annotations: &mcp.ToolAnnotations{
+			Title:           tool.Tool.Annotations.Title,
+			ReadOnlyHint:    ptr.Deref(tool.Tool.Annotations.ReadOnlyHint, false),
+			DestructiveHint: tool.Tool.Annotations.DestructiveHint,
+			IdempotentHint:  ptr.Deref(tool.Tool.Annotations.IdempotentHint, false),
+			OpenWorldHint:   tool.Tool.Annotations.OpenWorldHint,
+		},
+		InputSchema: json.RawMessage(`{"type":"object","properties":{}}`),
+	}
+	if tool.Tool.InputSchema != nil {
+		schema, err := json.Marshal(tool.Tool.InputSchema)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal tool input schema for tool %s: %v", tool.Tool.Name, err)
+		}
+		// Some clients have trouble parsing a schema without properties
+		// https://github.com/containers/kubernetes-mcp-server/issues/340
+		if string(schema) != `{"type":"object"}` {
+			mcpTool.InputSchema = json.RawMessage(schema)
+		}
+	}
+	return mcpTool, nil
+}
+
+// toolHandler returns the Model Context Protocol SDK handler that runs the ServerTool against
+// the Kubernetes cluster targeted by the tool call.
+func (s *Server) toolHandler(tool k8sapi.ServerTool) mcp.ToolHandler {
+	return func(ctx context.Context, request *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		arguments := toolCallRequest{}
+		if len(request.Params.Arguments) > 0 {
+			if err := json.Unmarshal(request.Params.Arguments, &arguments); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal arguments for tool %s: %w", tool.Tool.Name, err)
+			}
+		}
+		st := s.state()
+		info := s.requestInfo(ctx, request)
+		header, principal := info.header, info.principal
+		if principal != nil {
+			if err := principal.CanCall(tool); err != nil {
+				return st.newTextResult("", err), nil
+			}
+		}
+		ctx = withRequestAuthorization(ctx, header)
+		// Clients tracking the progress are notified of the start of the call, so that if the connection of a
+		// resumable stream drops before the result, the client resumes the stream from this event.
+		if token := request.Params.GetProgressToken(); token != nil && request.Session != nil {
+			progress := &mcp.ProgressNotificationParams{ProgressToken: token, Message: "started " + tool.Tool.Name}
+			if err := request.Session.NotifyProgress(ctx, progress); err != nil {
+				klog.V(2).Infof("failed to notify the progress of tool %s: %v", tool.Tool.Name, err)
+			}
+		}
+
+		p := s.provider()
+		if selector := arguments.GetString(ClusterSelectorParameterName, ""); selector != "" {
+			return s.fanOut(ctx, st, p, tool, arguments, selector, principal), nil
+		}
+
+		// get the correct derived Kubernetes client for the target specified in the request
+		cluster := arguments.GetString(p.GetTargetParameterName(), p.GetDefaultTarget())
+		k, err := st.derivedKubernetes(ctx, p, cluster, principal)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := tool.Handler(k8sapi.ToolHandlerParams{
+			Context:         ctx,
+			Kubernetes:      k,
+			ToolCallRequest: arguments,
+			ListOutput:      st.configuration.ListOutput(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return st.newTextResult(result.Content, result.Error), nil
+	}
+}
+
+// derivedKubernetes returns the Kubernetes client for the cluster.
+//
+// Principals authenticated with an API key or a client certificate use the server credentials,
+// OAuth users use their bearer token. In both cases the user is impersonated instead if impersonation is enabled.
+func (s *state) derivedKubernetes(ctx context.Context, p internalk8s.Provider, cluster string, principal *auth.Principal) (*internalk8s.Kubernetes, error) {
+	impersonation := s.configuration.Extensions.Impersonation
+	if principal == nil && !impersonation.Enabled {
+		return p.GetDerivedKubernetes(ctx, cluster)
+	}
+	targets, err := p.GetTargets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(targets, cluster) {
+		return nil, fmt.Errorf("unknown cluster %q", cluster)
+	}
+	if principal != nil {
+		var identity *impersonate.Identity
+		if impersonation.Enabled {
+			identity = &impersonate.Identity{User: principal.User, Groups: principal.Groups}
+		}
+		return s.clients.Kubernetes(cluster, identity)
+	}
+	// the identity is only read from the tokens verified by the authorization middleware
+	authorization, ok := ctx.Value(internalk8s.OAuthAuthorizationHeader).(string)
+	if !s.configuration.StaticConfig.RequireOAuth || !ok || !strings.HasPrefix(authorization, "Bearer ") {
+		return nil, errors.New("oauth token required")
+	}
+	identity, err := impersonate.IdentityFromToken(impersonation, strings.TrimPrefix(authorization, "Bearer "))
+	if err != nil {
+		return nil, err
+	}
+	return s.clients.Kubernetes(cluster, identity)
+}
+
+// withRequestAuthorization propagates the Authorization header of the HTTP request (if any)
+// so that the derived Kubernetes client uses the provided bearer token.
+func withRequestAuthorization(ctx context.Context, header http.Header) context.Context {
+	if header == nil {
+		return ctx
+	}
+	// Get the standard Authorization header (OAuth compliant)
+	if authHeader := header.Get(string(internalk8s.OAuthAuthorizationHeader)); authHeader != "" {
+		return context.WithValue(ctx, internalk8s.OAuthAuthorizationHeader, authHeader)
+	}
+	// Fallback to custom header for backward compatibility
+	if customAuthHeader := header.Get(string(internalk8s.CustomAuthorizationHeader)); customAuthHeader != "" {
+		return context.WithValue(ctx, internalk8s.OAuthAuthorizationHeader, customAuthHeader)
+	}
+	return ctx
+}
+
+// requestInfo returns the information of the HTTP request of the request: the principal and the address of the
+// client are in the context of the handler, the header is the one of the request itself (streamable HTTP) or of
+// the request that established the session (SSE).
+func (s *Server) requestInfo(ctx context.Context, request mcp.Request) requestInfo {
+	info := requestInfo{principal: auth.PrincipalFromContext(ctx), remoteAddress: auth.RemoteAddressFromContext(ctx)}
+	if extra := request.GetExtra(); extra != nil && extra.Header != nil {
+		info.header = extra.Header
+	} else if session := request.GetSession(); session != nil {
+		if header, ok := s.sessionHeaders.Load(session); ok {
+			info.header = header.(http.Header)
+		}
+	}
+	return info
+}
+
+// newTextResult creates the tool call result, masking any sensitive data in the content or error.
+func (s *state) newTextResult(content string, err error) *mcp.CallToolResult {
+	if err != nil {
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{&mcp.TextContent{Text: s.redactor.Redact(err.Error())}},
+		}
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: s.redactor.Redact(content)}},
+	}
+}
+
+func toolCallLoggingMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		if ctr, ok := req.(*mcp.CallToolRequest); ok {
+			klog.V(5).Infof("mcp tool call: %s(%s)", ctr.Params.Name, ctr.Params.Arguments)
+			if extra := ctr.GetExtra(); extra != nil && extra.Header != nil {
+				buffer := bytes.NewBuffer(make([]byte, 0))
+				if err := extra.Header.WriteSubset(buffer, map[string]bool{"Authorization": true, "authorization": true}); err == nil {
+					klog.V(7).Infof("mcp tool call headers: %s", buffer)
+				}
+			}
+		}
+		return next(ctx, method, req)
+	}
+}
@@ -0,0 +1,206 @@
+// Package ratelimit provides the rate limits and concurrency quotas applied to each MCP client.
+//
+// Clients are limited on the rate of MCP requests, the number of concurrent tool calls and the
+// number of Kubernetes API calls per minute. The Kubernetes API calls are counted through the
+// client-go request metrics, attributed to the client whose tool call context issued the request.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/tools/metrics"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+)
+
+const (
+	// apiCallsWindow is the sliding window for the Kubernetes API calls budget.
+	apiCallsWindow = time.Minute
+	// idleClientTimeout is the time after which the state of idle clients is discarded.
+	idleClientTimeout = 10 * time.Minute
+	// concurrencyRetryAfter is the retry hint when the concurrent tool calls quota is exhausted.
+	concurrencyRetryAfter = time.Second
+)
+
+// Error is returned when a client exceeds one of its limits.
+type Error struct {
+	// Limit is the name of the exceeded limit.
+	Limit string
+	// RetryAfter is the time after which the client may retry.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rate limit exceeded (%s), retry after %ds", e.Limit, e.RetryAfterSeconds())
+}
+
+// RetryAfterSeconds returns the retry hint rounded up to whole seconds.
+func (e *Error) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// Limiter tracks the limits of each client.
+type Limiter struct {
+	cfg config.RateLimitConfig
+	now func() time.Time
+
+	mu          sync.Mutex
+	clients     map[string]*client
+	lastCleanup time.Time
+}
+
+type client struct {
+	requests *rate.Limiter
+
+	// guarded by Limiter.mu
+	toolCalls int
+	apiCalls  []time.Time
+	lastSeen  time.Time
+}
+
+var registerMetrics sync.Once
+
+// New creates the limiter for the configuration, or nil if rate limiting is disabled.
+func New(cfg config.RateLimitConfig) *Limiter {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.KubernetesAPICallsPerMinute > 0 {
+		registerMetrics.Do(func() {
+			metrics.Register(metrics.RegisterOpts{RequestResult: apiCallCounter{}})
+		})
+	}
+	return &Limiter{
+		cfg:     cfg,
+		now:     time.Now,
+		clients: make(map[string]*client),
+	}
+}
+
+// KeyBy returns how clients are identified (identity or session).
+func (l *Limiter) KeyBy() string {
+	return l.cfg.KeyBy
+}
+
+// client returns the state for the key, must be called with the lock held.
+func (l *Limiter) client(key string, now time.Time) *client {
+	if now.Sub(l.lastCleanup) > time.Minute {
+		l.lastCleanup = now
+		for k, c := range l.clients {
+			if c.toolCalls == 0 && now.Sub(c.lastSeen) > idleClientTimeout {
+				delete(l.clients, k)
+			}
+		}
+	}
+	c, ok := l.clients[key]
+	if !ok {
+		c = &client{}
+		if l.cfg.RequestsPerSecond > 0 {
+			burst := l.cfg.Burst
+			if burst <= 0 {
+				burst = int(math.Max(1, math.Ceil(l.cfg.RequestsPerSecond)))
+			}
+			c.requests = rate.NewLimiter(rate.Limit(l.cfg.RequestsPerSecond), burst)
+		}
+		l.clients[key] = c
+	}
+	c.lastSeen = now
+	return c
+}
+
+// Allow checks the request rate of the client.
+func (l *Limiter) Allow(key string) error {
+	now := l.now()
+	l.mu.Lock()
+	c := l.client(key, now)
+	l.mu.Unlock()
+	if c.requests == nil {
+		return nil
+	}
+	reservation := c.requests.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return &Error{Limit: "requests per second", RetryAfter: delay}
+	}
+	return nil
+}
+
+// StartToolCall checks the concurrent tool calls and Kubernetes API calls quotas of the client.
+//
+// If allowed, the returned context attributes the Kubernetes API calls to the client and the
+// returned function must be called once the tool call completes.
+func (l *Limiter) StartToolCall(ctx context.Context, key string) (context.Context, func(), error) {
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c := l.client(key, now)
+	if l.cfg.MaxConcurrentToolCalls > 0 && c.toolCalls >= l.cfg.MaxConcurrentToolCalls {
+		return ctx, nil, &Error{Limit: "concurrent tool calls", RetryAfter: concurrencyRetryAfter}
+	}
+	if l.cfg.KubernetesAPICallsPerMinute > 0 {
+		c.pruneAPICalls(now)
+		if len(c.apiCalls) >= l.cfg.KubernetesAPICallsPerMinute {
+			return ctx, nil, &Error{
+				Limit:      "Kubernetes API calls per minute",
+				RetryAfter: c.apiCalls[len(c.apiCalls)-l.cfg.KubernetesAPICallsPerMinute].Add(apiCallsWindow).Sub(now),
+			}
+		}
+		ctx = context.WithValue(ctx, apiCallsKey{}, &apiCallRecorder{limiter: l, client: c})
+	}
+	c.toolCalls++
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			l.mu.Lock()
+			c.toolCalls--
+			l.mu.Unlock()
+		})
+	}, nil
+}
+
+// pruneAPICalls discards the Kubernetes API calls outside the sliding window, must be called with the lock held.
+func (c *client) pruneAPICalls(now time.Time) {
+	expired := 0
+	for expired < len(c.apiCalls) && now.Sub(c.apiCalls[expired]) >= apiCallsWindow {
+		expired++
+	}
+	c.apiCalls = c.apiCalls[expired:]
+}
+
+type apiCallsKey struct{}
+
+type apiCallRecorder struct {
+	limiter *Limiter
+	client  *client
+}
+
+func (r *apiCallRecorder) record() {
+	now := r.limiter.now()
+	r.limiter.mu.Lock()
+	defer r.limiter.mu.Unlock()
+	r.client.pruneAPICalls(now)
+	r.client.apiCalls = append(r.client.apiCalls, now)
+}
+
+// apiCallCounter is the client-go request result metric recording the Kubernetes API calls
+// of the tool calls started by StartToolCall.
+type apiCallCounter struct{}
+
+func (apiCallCounter) Increment(ctx context.Context, _, _, _ string) {
+	if ctx == nil {
+		return
+	}
+	if recorder, ok := ctx.Value(apiCallsKey{}).(*apiCallRecorder); ok {
+		recorder.record()
+	}
+}
+
+// RecordAPICall records a Kubernetes API call for the client of the context (as done by the client-go metrics).
+func RecordAPICall(ctx context.Context) {
+	apiCallCounter{}.Increment(ctx, "", "", "")
+}
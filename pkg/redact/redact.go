@@ -0,0 +1,402 @@
+// Package redact provides the output-filtering layer that masks sensitive data
+// before tool call results and resource contents leave the server.
+//
+// Structured (YAML/JSON) output is parsed and walked so that only the values of sensitive
+// fields are replaced, unstructured output (tables, logs) is filtered with regular expressions.
+package redact
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+)
+
+// kubeconfigCredentialFields are the kubeconfig user (and auth-provider) fields holding credentials.
+var kubeconfigCredentialFields = map[string]bool{
+	"token":           true,
+	"password":        true,
+	"client-key-data": true,
+	"id-token":        true,
+	"refresh-token":   true,
+	"access-token":    true,
+	"client-secret":   true,
+}
+
+// Redactor masks sensitive data in text output.
+// A nil Redactor is valid and returns its input unchanged.
+type Redactor struct {
+	replacement           string
+	secretData            bool
+	kubeconfigCredentials bool
+	envPatterns           []*regexp.Regexp
+	jsonPaths             []path
+	patterns              []*regexp.Regexp
+}
+
+// New creates a Redactor for the provided configuration.
+// Returns nil if redaction is disabled.
+func New(cfg config.RedactionConfig) (*Redactor, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	r := &Redactor{
+		replacement:           cfg.Replacement,
+		secretData:            cfg.SecretData,
+		kubeconfigCredentials: cfg.KubeconfigCredentials,
+	}
+	if r.replacement == "" {
+		r.replacement = config.DefaultRedactionReplacement
+	}
+	for _, pattern := range cfg.EnvPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction env_patterns entry %q: %w", pattern, err)
+		}
+		r.envPatterns = append(r.envPatterns, re)
+	}
+	for _, jsonPath := range cfg.JSONPaths {
+		p, err := parsePath(jsonPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction json_paths entry %q: %w", jsonPath, err)
+		}
+		r.jsonPaths = append(r.jsonPaths, p)
+	}
+	for _, pattern := range cfg.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction patterns entry %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Redact returns the text with all sensitive data masked.
+func (r *Redactor) Redact(text string) string {
+	if r == nil || text == "" {
+		return text
+	}
+	text = r.redactStructured(text)
+	for _, re := range r.patterns {
+		text = re.ReplaceAllString(text, r.replacement)
+	}
+	return text
+}
+
+// redactStructured parses the text as YAML (JSON is a subset) and masks the sensitive fields of every document.
+// Leading comment lines (e.g. "# The following resources (YAML) have been created") are preserved, and so are
+// the order of the keys and the style of the documents (JSON stays JSON).
+// The text is returned unchanged if it can't be parsed or nothing was masked.
+func (r *Redactor) redactStructured(text string) string {
+	header, body := splitLeadingComments(text)
+	var documents []*yaml.Node
+	decoder := yaml.NewDecoder(strings.NewReader(body))
+	for {
+		document := &yaml.Node{}
+		if err := decoder.Decode(document); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return text
+		}
+		documents = append(documents, document)
+	}
+	changed := false
+	for _, document := range documents {
+		if len(document.Content) == 0 {
+			continue
+		}
+		root := document.Content[0]
+		if root.Kind != yaml.MappingNode && root.Kind != yaml.SequenceNode {
+			continue
+		}
+		w := walker{Redactor: r}
+		if root.Style&yaml.FlowStyle != 0 {
+			w.style = yaml.DoubleQuotedStyle
+		}
+		changed = w.redactValue(root) || changed
+		changed = w.redactPaths(root) || changed
+	}
+	if !changed {
+		return text
+	}
+	var redacted bytes.Buffer
+	encoder := yaml.NewEncoder(&redacted)
+	encoder.SetIndent(2)
+	for _, document := range documents {
+		if err := encoder.Encode(document); err != nil {
+			return text
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return text
+	}
+	return header + redacted.String()
+}
+
+// walker masks the sensitive fields of a parsed document.
+type walker struct {
+	*Redactor
+	// style is the style of the replacements, double-quoted in JSON documents
+	style yaml.Style
+}
+
+// replace replaces the node with the replacement, keeping its anchor for the aliases referring to it.
+func (w walker) replace(node *yaml.Node) {
+	node = resolve(node)
+	*node = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: w.replacement, Style: w.style, Anchor: node.Anchor}
+}
+
+// redactValue recursively walks the node and masks the well-known sensitive fields.
+func (w walker) redactValue(node *yaml.Node) bool {
+	changed := false
+	switch node.Kind {
+	case yaml.MappingNode:
+		kind := ""
+		if kindNode := mappingValue(node, "kind"); kindNode != nil && kindNode.Kind == yaml.ScalarNode {
+			kind = kindNode.Value
+		}
+		if w.secretData && kind == "Secret" {
+			changed = w.maskValues(mappingValue(node, "data")) || changed
+			changed = w.maskValues(mappingValue(node, "stringData")) || changed
+		}
+		if w.kubeconfigCredentials && kind == "Config" {
+			if users := mappingValue(node, "users"); users != nil && users.Kind == yaml.SequenceNode {
+				for _, user := range users.Content {
+					changed = w.redactKubeconfigUser(resolve(user)) || changed
+				}
+			}
+		}
+		if env := mappingValue(node, "env"); env != nil && env.Kind == yaml.SequenceNode {
+			changed = w.redactEnv(env) || changed
+		}
+		for i := 1; i < len(node.Content); i += 2 {
+			changed = w.redactValue(node.Content[i]) || changed
+		}
+	case yaml.SequenceNode:
+		for _, child := range node.Content {
+			changed = w.redactValue(child) || changed
+		}
+	}
+	return changed
+}
+
+// maskValues replaces every value of the provided mapping, keeping its keys.
+func (w walker) maskValues(node *yaml.Node) bool {
+	if node == nil || node.Kind != yaml.MappingNode || len(node.Content) == 0 {
+		return false
+	}
+	for i := 1; i < len(node.Content); i += 2 {
+		w.replace(node.Content[i])
+	}
+	return true
+}
+
+// redactKubeconfigUser masks the credential fields of a kubeconfig users[] entry.
+func (w walker) redactKubeconfigUser(node *yaml.Node) bool {
+	if node.Kind != yaml.MappingNode {
+		return false
+	}
+	changed := false
+	for i := 1; i < len(node.Content); i += 2 {
+		child := resolve(node.Content[i])
+		if child.Kind == yaml.ScalarNode && kubeconfigCredentialFields[node.Content[i-1].Value] {
+			w.replace(child)
+			changed = true
+			continue
+		}
+		changed = w.redactKubeconfigUser(child) || changed
+	}
+	return changed
+}
+
+// redactEnv masks the value of the container env vars whose name matches any of the env patterns.
+func (w walker) redactEnv(env *yaml.Node) bool {
+	changed := false
+	for _, entry := range env.Content {
+		entry = resolve(entry)
+		if entry.Kind != yaml.MappingNode {
+			continue
+		}
+		name := ""
+		if nameNode := mappingValue(entry, "name"); nameNode != nil {
+			name = nameNode.Value
+		}
+		value := mappingValue(entry, "value")
+		if value == nil || !w.matchesEnvPattern(name) {
+			continue
+		}
+		w.replace(value)
+		changed = true
+	}
+	return changed
+}
+
+func (r *Redactor) matchesEnvPattern(name string) bool {
+	for _, re := range r.envPatterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactPaths masks the configured JSONPaths at the root of the document and,
+// for lists, at the root of each of the list items.
+func (w walker) redactPaths(root *yaml.Node) bool {
+	if len(w.jsonPaths) == 0 {
+		return false
+	}
+	roots := []*yaml.Node{root}
+	switch root.Kind {
+	case yaml.SequenceNode:
+		roots = append(roots, root.Content...)
+	case yaml.MappingNode:
+		if items := mappingValue(root, "items"); items != nil && items.Kind == yaml.SequenceNode {
+			roots = append(roots, items.Content...)
+		}
+	}
+	changed := false
+	for _, root := range roots {
+		for _, p := range w.jsonPaths {
+			changed = p.mask(resolve(root), w) || changed
+		}
+	}
+	return changed
+}
+
+// mappingValue returns the (alias resolved) value of the key in the mapping node, nil if not found.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return resolve(node.Content[i+1])
+		}
+	}
+	return nil
+}
+
+// resolve returns the node an alias refers to, the node itself if it isn't an alias.
+func resolve(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+func splitLeadingComments(text string) (header, body string) {
+	body = text
+	for strings.HasPrefix(body, "#") {
+		end := strings.Index(body, "\n")
+		if end < 0 {
+			return text, ""
+		}
+		body = body[end+1:]
+	}
+	return text[:len(text)-len(body)], body
+}
+
+// segment is a single step of a path: a map key optionally followed by an index ([N] or [*]).
+type segment struct {
+	key      string
+	hasIndex bool
+	wildcard bool
+	index    int
+}
+
+// path is a simplified JSONPath supporting dotted keys and array indexes,
+// e.g. $.spec.containers[*].args or metadata.annotations.example\.com/token.
+type path []segment
+
+var indexRegexp = regexp.MustCompile(`^(.*)\[(\*|\d+)]$`)
+
+func parsePath(p string) (path, error) {
+	p = strings.TrimPrefix(strings.TrimPrefix(p, "$"), ".")
+	if p == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+	var segments path
+	for _, part := range splitPath(p) {
+		seg := segment{key: part}
+		if match := indexRegexp.FindStringSubmatch(part); match != nil {
+			seg.key = match[1]
+			seg.hasIndex = true
+			if match[2] == "*" {
+				seg.wildcard = true
+			} else {
+				seg.index, _ = strconv.Atoi(match[2])
+			}
+		}
+		if seg.key == "" && !seg.hasIndex {
+			return nil, fmt.Errorf("empty path segment")
+		}
+		segments = append(segments, seg)
+	}
+	return segments, nil
+}
+
+// splitPath splits the path by unescaped dots, "\." can be used for keys containing dots.
+func splitPath(p string) []string {
+	var parts []string
+	var current strings.Builder
+	for i := 0; i < len(p); i++ {
+		switch {
+		case p[i] == '\\' && i+1 < len(p) && p[i+1] == '.':
+			current.WriteByte('.')
+			i++
+		case p[i] == '.':
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(p[i])
+		}
+	}
+	return append(parts, current.String())
+}
+
+// mask replaces the value(s) at the path with the replacement.
+func (p path) mask(node *yaml.Node, w walker) bool {
+	if len(p) == 0 {
+		return false
+	}
+	seg, rest := p[0], p[1:]
+	if seg.key != "" {
+		if node.Kind != yaml.MappingNode {
+			return false
+		}
+		child := mappingValue(node, seg.key)
+		if child == nil {
+			return false
+		}
+		if !seg.hasIndex && len(rest) == 0 {
+			w.replace(child)
+			return true
+		}
+		node = child
+	}
+	if !seg.hasIndex {
+		return rest.mask(node, w)
+	}
+	if node.Kind != yaml.SequenceNode {
+		return false
+	}
+	changed := false
+	for i, item := range node.Content {
+		if !seg.wildcard && i != seg.index {
+			continue
+		}
+		if len(rest) == 0 {
+			w.replace(item)
+			changed = true
+			continue
+		}
+		changed = rest.mask(resolve(item), w) || changed
+	}
+	return changed
+}
@@ -0,0 +1,138 @@
+// Package sessions provides the state of the stateful streamable HTTP sessions, such as the events kept
+// to resume their streams after a dropped connection, and the stores keeping it: in memory, in a directory
+// or in an external store, which may be shared by the server instances serving the sessions.
+package sessions
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// EventStore keeps the events of the streams of each session in memory, so that a client reconnecting with
+// Last-Event-ID receives the events it missed.
+//
+// Each session is bounded: once it holds more than the maximum number of events or bytes, its oldest events
+// are dropped (whatever their stream) and resuming a stream before them fails. The last event is always kept.
+type EventStore struct {
+	maxEvents int
+	maxBytes  int
+
+	mu       sync.Mutex
+	sessions map[string]*sessionEvents
+}
+
+var _ mcp.EventStore = &EventStore{}
+
+type sessionEvents struct {
+	streams map[string]*stream
+	// order holds the stream of each event of the session, oldest first
+	order []string
+	bytes int
+}
+
+type stream struct {
+	// first is the index of the first event kept
+	first  int
+	events [][]byte
+}
+
+// NewEventStore creates an event store keeping up to maxEvents events and maxBytes bytes per session.
+func NewEventStore(maxEvents, maxBytes int) *EventStore {
+	return &EventStore{
+		maxEvents: maxEvents,
+		maxBytes:  maxBytes,
+		sessions:  make(map[string]*sessionEvents),
+	}
+}
+
+// Open initializes the stream of the session.
+func (s *EventStore) Open(_ context.Context, sessionID, streamID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stream(sessionID, streamID)
+	return nil
+}
+
+// stream returns the stream of the session, creating it if needed. It must be called with s.mu held.
+func (s *EventStore) stream(sessionID, streamID string) (*sessionEvents, *stream) {
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		session = &sessionEvents{streams: make(map[string]*stream)}
+		s.sessions[sessionID] = session
+	}
+	st, ok := session.streams[streamID]
+	if !ok {
+		st = &stream{}
+		session.streams[streamID] = st
+	}
+	return session, st
+}
+
+// Append stores the event of the stream, dropping the oldest events of the session beyond its bounds.
+func (s *EventStore) Append(_ context.Context, sessionID, streamID string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, st := s.stream(sessionID, streamID)
+	st.events = append(st.events, data)
+	session.order = append(session.order, streamID)
+	session.bytes += len(data)
+	for len(session.order) > 1 && (len(session.order) > s.maxEvents || session.bytes > s.maxBytes) {
+		oldest := session.streams[session.order[0]]
+		session.order = session.order[1:]
+		session.bytes -= len(oldest.events[0])
+		oldest.events[0] = nil
+		oldest.events = oldest.events[1:]
+		oldest.first++
+	}
+	return nil
+}
+
+// After returns the events of the stream after the index, or mcp.ErrEventsPurged if some were dropped.
+func (s *EventStore) After(_ context.Context, sessionID, streamID string, index int) iter.Seq2[[]byte, error] {
+	events := func() ([][]byte, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		session, ok := s.sessions[sessionID]
+		if !ok {
+			return nil, fmt.Errorf("unknown session %q", sessionID)
+		}
+		st, ok := session.streams[streamID]
+		if !ok {
+			return nil, fmt.Errorf("unknown stream %q of session %q", streamID, sessionID)
+		}
+		start := index + 1
+		if start < st.first {
+			return nil, fmt.Errorf("events of stream %q of session %q after %d: %w", streamID, sessionID, index, mcp.ErrEventsPurged)
+		}
+		if start-st.first > len(st.events) {
+			return nil, fmt.Errorf("unknown event %d of stream %q of session %q", index, streamID, sessionID)
+		}
+		// copied, the dropped events are cleared
+		return slices.Clone(st.events[start-st.first:]), nil
+	}
+	return func(yield func([]byte, error) bool) {
+		data, err := events()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for _, d := range data {
+			if !yield(d, nil) {
+				return
+			}
+		}
+	}
+}
+
+// SessionClosed drops the events of the session.
+func (s *EventStore) SessionClosed(_ context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionID)
+	return nil
+}
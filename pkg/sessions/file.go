@@ -0,0 +1,460 @@
+package sessions
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	sessionFile = "session.json"
+	eventsFile  = "events"
+	lockFile    = "lock"
+)
+
+const (
+	// staleLockAge is the age of a lock file after which it's considered left by a crashed instance and
+	// removed, the locks are only held while reading or writing the files of a session.
+	staleLockAge = 30 * time.Second
+	// minLockRetryInterval and maxLockRetryInterval bound the interval between the attempts to acquire a lock
+	// held by another instance, doubling after each attempt.
+	minLockRetryInterval = time.Millisecond
+	maxLockRetryInterval = 50 * time.Millisecond
+)
+
+// FileStore keeps each session in a directory: the session record and the events of its streams, appended
+// to an events file. The directory may be shared by several server instances (e.g. a ReadWriteMany volume),
+// the instance serving a stream appending its events. The files of a session are only accessed with its lock
+// held, a lock file created exclusively in the session directory, so that the instances never interleave
+// their reads and writes.
+//
+// The events are bounded per session as for the EventStore: once the events file holds more than the maximum
+// number of events or bytes, it's rewritten without the oldest events.
+//
+// The events hold the messages sent to the clients as is, including the tool results (only masked by the
+// output redaction, if enabled), so the directory must be protected as the cluster data: the directories are
+// created with mode 0700 and the files with mode 0600.
+type FileStore struct {
+	dir         string
+	maxEvents   int
+	maxBytes    int
+	idleTimeout time.Duration
+
+	mu    sync.Mutex
+	swept time.Time
+}
+
+var _ Store = &FileStore{}
+
+// NewFileStore creates a file store in the directory keeping up to maxEvents events and maxBytes bytes per
+// session, expiring the sessions idle for longer than idleTimeout (never if 0).
+func NewFileStore(dir string, maxEvents, maxBytes int, idleTimeout time.Duration) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create the sessions store directory: %w", err)
+	}
+	return &FileStore{
+		dir:         dir,
+		maxEvents:   maxEvents,
+		maxBytes:    maxBytes,
+		idleTimeout: idleTimeout,
+	}, nil
+}
+
+func (s *FileStore) sessionDir(sessionID string) string {
+	return filepath.Join(s.dir, sessionID)
+}
+
+// lock acquires the lock of the session shared by the server instances and returns the function releasing it.
+// The error wraps fs.ErrNotExist if the session directory doesn't exist.
+func (s *FileStore) lock(ctx context.Context, sessionID string) (func(), error) {
+	path := filepath.Join(s.sessionDir(sessionID), lockFile)
+	retry := minLockRetryInterval
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			_ = f.Close()
+			return func() { _ = os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("failed to lock the session %s: %w", sessionID, err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			_ = os.Remove(path)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to lock the session %s: %w", sessionID, ctx.Err())
+		case <-time.After(retry):
+		}
+		retry = min(2*retry, maxLockRetryInterval)
+	}
+}
+
+// lockCreate is lock creating the session directory if needed.
+func (s *FileStore) lockCreate(ctx context.Context, sessionID string) (func(), error) {
+	if err := os.MkdirAll(s.sessionDir(sessionID), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create the session directory: %w", err)
+	}
+	return s.lock(ctx, sessionID)
+}
+
+// Load returns the session, or ErrSessionNotFound if it doesn't exist or expired.
+func (s *FileStore) Load(ctx context.Context, sessionID string) (*Session, error) {
+	if err := checkIDs(sessionID); err != nil {
+		return nil, ErrSessionNotFound
+	}
+	session, err := readSession(filepath.Join(s.sessionDir(sessionID), sessionFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if expired(session, s.idleTimeout, time.Now()) {
+		_ = s.Delete(ctx, sessionID)
+		return nil, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+func readSession(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	session := &Session{}
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, fmt.Errorf("failed to decode the session %s: %w", path, err)
+	}
+	return session, nil
+}
+
+// Save creates or replaces the session, removing the expired sessions at most once per sweep interval.
+func (s *FileStore) Save(ctx context.Context, session *Session) error {
+	if err := checkIDs(session.ID); err != nil {
+		return err
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to encode the session %s: %w", session.ID, err)
+	}
+	unlock, err := s.lockCreate(ctx, session.ID)
+	if err != nil {
+		return err
+	}
+	err = writeFile(filepath.Join(s.sessionDir(session.ID), sessionFile), data)
+	unlock()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	sweep := time.Since(s.swept) > sweepInterval
+	if sweep {
+		s.swept = time.Now()
+	}
+	s.mu.Unlock()
+	if sweep {
+		s.sweep(ctx)
+	}
+	return nil
+}
+
+// writeFile replaces the file atomically, so that the other server instances never read a partial file.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// sweep removes the expired sessions, and the events of the sessions never saved (their initialization
+// failed) once idle for longer than the idle timeout.
+func (s *FileStore) sweep(ctx context.Context) {
+	if s.idleTimeout <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		if !entry.IsDir() || checkIDs(entry.Name()) != nil {
+			continue
+		}
+		dir := s.sessionDir(entry.Name())
+		session, err := readSession(filepath.Join(dir, sessionFile))
+		if errors.Is(err, fs.ErrNotExist) {
+			info, err := entry.Info()
+			if err == nil && now.Sub(info.ModTime()) > s.idleTimeout {
+				_ = os.RemoveAll(dir)
+			}
+			continue
+		}
+		if err == nil && expired(session, s.idleTimeout, now) {
+			_ = s.Delete(ctx, entry.Name())
+		}
+	}
+}
+
+// Delete removes the session and its events.
+func (s *FileStore) Delete(ctx context.Context, sessionID string) error {
+	if err := checkIDs(sessionID); err != nil {
+		return err
+	}
+	unlock, err := s.lock(ctx, sessionID)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// the lock file is removed with the directory
+	defer unlock()
+	if err := os.RemoveAll(s.sessionDir(sessionID)); err != nil {
+		return fmt.Errorf("failed to delete the session %s: %w", sessionID, err)
+	}
+	return nil
+}
+
+// The events file holds a line per stream, declaring the index of its first event kept:
+//
+//	stream <stream ID> <first index>
+//
+// followed by a line per event, in order, with the data encoded in base64:
+//
+//	event <stream ID> <index> <data>
+//
+// The fields are separated by tabs.
+const (
+	streamLine = "stream"
+	eventLine  = "event"
+)
+
+type fileEvent struct {
+	stream string
+	index  int
+	data   []byte
+}
+
+// fileEvents are the parsed events file of a session.
+type fileEvents struct {
+	// first and next are the index of the first event kept and of the next event, by stream
+	first  map[string]int
+	next   map[string]int
+	events []fileEvent
+	bytes  int
+}
+
+// readEvents parses the events file, an empty one if it doesn't exist.
+func readEvents(path string) (*fileEvents, error) {
+	events := &fileEvents{first: make(map[string]int), next: make(map[string]int)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return events, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		switch {
+		case len(fields) == 3 && fields[0] == streamLine:
+			first, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("invalid events file %s: %w", path, err)
+			}
+			events.first[fields[1]] = first
+			events.next[fields[1]] = first
+		case len(fields) == 4 && fields[0] == eventLine:
+			index, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("invalid events file %s: %w", path, err)
+			}
+			data, err := base64.StdEncoding.DecodeString(fields[3])
+			if err != nil {
+				return nil, fmt.Errorf("invalid events file %s: %w", path, err)
+			}
+			events.events = append(events.events, fileEvent{stream: fields[1], index: index, data: data})
+			events.next[fields[1]] = index + 1
+			events.bytes += len(data)
+		default:
+			return nil, fmt.Errorf("invalid events file %s: unexpected line %q", path, scanner.Text())
+		}
+	}
+	return events, scanner.Err()
+}
+
+func formatStream(streamID string, first int) string {
+	return fmt.Sprintf("%s\t%s\t%d\n", streamLine, streamID, first)
+}
+
+func formatEvent(event fileEvent) string {
+	return fmt.Sprintf("%s\t%s\t%d\t%s\n", eventLine, event.stream, event.index, base64.StdEncoding.EncodeToString(event.data))
+}
+
+// appendLines appends the lines to the events file of the session.
+func (s *FileStore) appendLines(sessionID string, lines string) error {
+	f, err := os.OpenFile(filepath.Join(s.sessionDir(sessionID), eventsFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(lines); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Open declares the stream of the session in its events file.
+func (s *FileStore) Open(ctx context.Context, sessionID, streamID string) error {
+	if err := checkIDs(sessionID, streamID); err != nil {
+		return err
+	}
+	unlock, err := s.lockCreate(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	events, err := readEvents(filepath.Join(s.sessionDir(sessionID), eventsFile))
+	if err != nil {
+		return err
+	}
+	if _, ok := events.first[streamID]; ok {
+		return nil
+	}
+	return s.appendLines(sessionID, formatStream(streamID, 0))
+}
+
+// Append stores the event of the stream, rewriting the events file without the oldest events of the session
+// beyond its bounds.
+func (s *FileStore) Append(ctx context.Context, sessionID, streamID string, data []byte) error {
+	if err := checkIDs(sessionID, streamID); err != nil {
+		return err
+	}
+	unlock, err := s.lockCreate(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	path := filepath.Join(s.sessionDir(sessionID), eventsFile)
+	events, err := readEvents(path)
+	if err != nil {
+		return err
+	}
+	event := fileEvent{stream: streamID, index: events.next[streamID], data: data}
+	if len(events.events) < s.maxEvents && events.bytes+len(data) <= s.maxBytes {
+		lines := formatEvent(event)
+		if _, ok := events.first[streamID]; !ok {
+			lines = formatStream(streamID, 0) + lines
+		}
+		return s.appendLines(sessionID, lines)
+	}
+
+	kept := append(events.events, event)
+	size := events.bytes + len(data)
+	for len(kept) > 1 && (len(kept) > s.maxEvents || size > s.maxBytes) {
+		size -= len(kept[0].data)
+		kept = kept[1:]
+	}
+	events.next[streamID] = event.index + 1
+	first := make(map[string]int, len(events.next))
+	for stream, next := range events.next {
+		first[stream] = next
+	}
+	for i := len(kept) - 1; i >= 0; i-- {
+		first[kept[i].stream] = kept[i].index
+	}
+	var out strings.Builder
+	for stream, index := range first {
+		out.WriteString(formatStream(stream, index))
+	}
+	for _, e := range kept {
+		out.WriteString(formatEvent(e))
+	}
+	return writeFile(path, []byte(out.String()))
+}
+
+// After returns the events of the stream after the index, or mcp.ErrEventsPurged if some were dropped.
+func (s *FileStore) After(ctx context.Context, sessionID, streamID string, index int) iter.Seq2[[]byte, error] {
+	events := func() ([][]byte, error) {
+		if err := checkIDs(sessionID, streamID); err != nil {
+			return nil, err
+		}
+		unlock, err := s.lock(ctx, sessionID)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("unknown session %q", sessionID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+		path := filepath.Join(s.sessionDir(sessionID), eventsFile)
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("unknown session %q", sessionID)
+		}
+		events, err := readEvents(path)
+		if err != nil {
+			return nil, err
+		}
+		first, ok := events.first[streamID]
+		if !ok {
+			return nil, fmt.Errorf("unknown stream %q of session %q", streamID, sessionID)
+		}
+		start := index + 1
+		if start < first {
+			return nil, fmt.Errorf("events of stream %q of session %q after %d: %w", streamID, sessionID, index, mcp.ErrEventsPurged)
+		}
+		if start > events.next[streamID] {
+			return nil, fmt.Errorf("unknown event %d of stream %q of session %q", index, streamID, sessionID)
+		}
+		var data [][]byte
+		for _, event := range events.events {
+			if event.stream == streamID && event.index >= start {
+				data = append(data, event.data)
+			}
+		}
+		return data, nil
+	}
+	return func(yield func([]byte, error) bool) {
+		data, err := events()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for _, d := range data {
+			if !yield(d, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"maps"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+)
+
+// ErrSessionNotFound is returned by the stores for the sessions that don't exist or expired.
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is the record of a stateful streamable HTTP session, from which any server instance sharing the
+// store restores the session.
+type Session struct {
+	// ID is the Mcp-Session-Id of the session.
+	ID string `json:"id"`
+	// Endpoint is the path of the MCP endpoint serving the session, the sessions are only restored by it.
+	Endpoint string `json:"endpoint"`
+	// Owner identifies the client that created the session, the session is only served to the requests
+	// of the same identity. Empty for the unauthenticated clients.
+	Owner string `json:"owner,omitempty"`
+	// InitializeParams are the parameters of the initialize request, negotiating the protocol version
+	// and the client capabilities.
+	InitializeParams *mcp.InitializeParams `json:"initializeParams"`
+	// LogLevel is the level of the log messages set by the client, none are sent if empty.
+	LogLevel mcp.LoggingLevel `json:"logLevel,omitempty"`
+	// LastActive is the time of the last request of the session, refreshed periodically. The sessions
+	// without requests for longer than the idle timeout expire.
+	LastActive time.Time `json:"lastActive"`
+}
+
+// Store keeps the sessions and the events of their streams. A store shared by several server instances
+// (e.g. replicas behind a load balancer) lets any of them serve the requests of a session.
+//
+// The event methods follow mcp.EventStore: the events are appended to the streams of a session in order,
+// and After returns the events of a stream after an index, wrapping mcp.ErrEventsPurged if some were dropped.
+// The stores bound the events kept per session and expire the sessions idle for longer than the idle timeout.
+type Store interface {
+	// Load returns the session, or ErrSessionNotFound.
+	Load(ctx context.Context, sessionID string) (*Session, error)
+	// Save creates or replaces the session.
+	Save(ctx context.Context, session *Session) error
+	// Delete removes the session and its events.
+	Delete(ctx context.Context, sessionID string) error
+	// Open initializes the stream of the session, it's called again when a stream is resumed.
+	Open(ctx context.Context, sessionID, streamID string) error
+	// Append stores the next event of the stream.
+	Append(ctx context.Context, sessionID, streamID string, data []byte) error
+	// After returns the events of the stream after the index.
+	After(ctx context.Context, sessionID, streamID string, index int) iter.Seq2[[]byte, error]
+}
+
+// StoreFactory creates the store of the sessions configuration.
+type StoreFactory func(cfg config.SessionsConfig) (Store, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]StoreFactory{
+		config.SessionsStoreMemory: func(cfg config.SessionsConfig) (Store, error) {
+			idleTimeout, err := cfg.IdleTimeoutDuration()
+			if err != nil {
+				return nil, err
+			}
+			return NewMemoryStore(cfg.MaxEvents, cfg.MaxBytes, idleTimeout), nil
+		},
+		config.SessionsStoreFile: func(cfg config.SessionsConfig) (Store, error) {
+			idleTimeout, err := cfg.IdleTimeoutDuration()
+			if err != nil {
+				return nil, err
+			}
+			return NewFileStore(cfg.StoreDir, cfg.MaxEvents, cfg.MaxBytes, idleTimeout)
+		},
+	}
+)
+
+// RegisterStore registers an external session store (e.g. backed by Redis or a database) selected by the
+// store name of the sessions configuration. It's meant to be called from an init function.
+func RegisterStore(name string, factory StoreFactory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[name] = factory
+}
+
+// NewStore creates the store selected by the sessions configuration, the memory store by default.
+func NewStore(cfg config.SessionsConfig) (Store, error) {
+	name := cfg.Store
+	if name == "" {
+		name = config.SessionsStoreMemory
+	}
+	factoriesMu.RLock()
+	factory, ok := factories[name]
+	names := slices.Sorted(maps.Keys(factories))
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown sessions store %q, expected one of %v", name, names)
+	}
+	return factory(cfg)
+}
+
+// Events adapts the store to the event store of the streamable HTTP transports. The sessions closed by a server
+// instance (once idle or on shutdown) are kept in the store for the other instances, they're only removed by
+// Delete or once expired.
+func Events(store Store) mcp.EventStore {
+	return storeEvents{Store: store}
+}
+
+type storeEvents struct {
+	Store
+}
+
+func (storeEvents) SessionClosed(context.Context, string) error {
+	return nil
+}
+
+// sweepInterval is the minimum interval between the removals of the expired sessions.
+const sweepInterval = time.Minute
+
+// expired returns whether the session is idle for longer than the idle timeout, sessions never expire if 0.
+func expired(session *Session, idleTimeout time.Duration, now time.Time) bool {
+	return idleTimeout > 0 && now.Sub(session.LastActive) > idleTimeout
+}
+
+// MemoryStore keeps the sessions and their events in memory, local to the server instance.
+type MemoryStore struct {
+	*EventStore
+	idleTimeout time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]Session
+	swept    time.Time
+}
+
+var _ Store = &MemoryStore{}
+
+// NewMemoryStore creates a memory store keeping up to maxEvents events and maxBytes bytes per session,
+// expiring the sessions idle for longer than idleTimeout (never if 0).
+func NewMemoryStore(maxEvents, maxBytes int, idleTimeout time.Duration) *MemoryStore {
+	return &MemoryStore{
+		EventStore:  NewEventStore(maxEvents, maxBytes),
+		idleTimeout: idleTimeout,
+		sessions:    make(map[string]Session),
+	}
+}
+
+// Load returns the session, or ErrSessionNotFound if it doesn't exist or expired.
+func (s *MemoryStore) Load(ctx context.Context, sessionID string) (*Session, error) {
+	s.mu.Lock()
+	session, ok := s.sessions[sessionID]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	if expired(&session, s.idleTimeout, time.Now()) {
+		_ = s.Delete(ctx, sessionID)
+		return nil, ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+// Save creates or replaces the session, removing the expired sessions at most once per sweep interval.
+func (s *MemoryStore) Save(ctx context.Context, session *Session) error {
+	now := time.Now()
+	var expiredIDs []string
+	s.mu.Lock()
+	s.sessions[session.ID] = *session
+	if now.Sub(s.swept) > sweepInterval {
+		s.swept = now
+		for id, session := range s.sessions {
+			if expired(&session, s.idleTimeout, now) {
+				expiredIDs = append(expiredIDs, id)
+			}
+		}
+	}
+	s.mu.Unlock()
+	for _, id := range expiredIDs {
+		_ = s.Delete(ctx, id)
+	}
+	return nil
+}
+
+// Delete removes the session and its events.
+func (s *MemoryStore) Delete(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	delete(s.sessions, sessionID)
+	s.mu.Unlock()
+	return s.SessionClosed(ctx, sessionID)
+}
+
+// validID matches the session and stream IDs accepted by the stores, which are provided by the clients
+// (the Mcp-Session-Id and Last-Event-ID headers) and may be used as keys or file names. The stream ID of
+// the standalone stream is empty.
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]*$`)
+
+// checkIDs returns an error if the session ID is empty or an ID contains unexpected characters.
+func checkIDs(sessionID string, streamIDs ...string) error {
+	if sessionID == "" || !validID.MatchString(sessionID) {
+		return fmt.Errorf("invalid session ID %q", sessionID)
+	}
+	for _, streamID := range streamIDs {
+		if !validID.MatchString(streamID) {
+			return fmt.Errorf("invalid stream ID %q", streamID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package toolsets
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+
+	k8sapi "github.com/containers/kubernetes-mcp-server/pkg/api"
+	internalk8s "github.com/containers/kubernetes-mcp-server/pkg/kubernetes"
+
+	localapi "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/api"
+)
+
+// CoreConfig is the configuration of the core toolset ([toolset_configs.core] section).
+type CoreConfig struct {
+	// PodsLogTail is the number of log lines returned by pods_log when the tail argument isn't provided.
+	PodsLogTail int64 `toml:"pods_log_tail"`
+	// PodsLogMaxTail limits the number of log lines returned by pods_log (0 for no limit).
+	PodsLogMaxTail int64 `toml:"pods_log_max_tail"`
+}
+
+// Validate checks the core toolset configuration for errors.
+func (c *CoreConfig) Validate() error {
+	if c.PodsLogTail <= 0 {
+		return errors.New("pods_log_tail must be greater than 0")
+	}
+	if c.PodsLogMaxTail < 0 {
+		return errors.New("pods_log_max_tail must not be negative")
+	}
+	if c.PodsLogMaxTail > 0 && c.PodsLogTail > c.PodsLogMaxTail {
+		return fmt.Errorf("pods_log_tail (%d) must not exceed pods_log_max_tail (%d)", c.PodsLogTail, c.PodsLogMaxTail)
+	}
+	return nil
+}
+
+type coreToolset struct {
+	k8sapi.Toolset
+}
+
+var (
+	_ localapi.ConfigurableToolset = &coreToolset{}
+	_ localapi.PermissionsProvider = &coreToolset{}
+)
+
+func (t *coreToolset) DefaultConfig() localapi.ToolsetConfig {
+	return &CoreConfig{PodsLogTail: internalk8s.DefaultTailLines}
+}
+
+func (t *coreToolset) GetConfiguredTools(o internalk8s.Openshift, cfg localapi.ToolsetConfig) []k8sapi.ServerTool {
+	coreConfig := cfg.(*CoreConfig)
+	tools := t.GetTools(o)
+	for i, tool := range tools {
+		if tool.Tool.Name != "pods_log" {
+			continue
+		}
+		if tail, ok := tool.Tool.InputSchema.Properties["tail"]; ok {
+			schema := *tool.Tool.InputSchema
+			schema.Properties = maps.Clone(schema.Properties)
+			tailSchema := *tail
+			tailSchema.Default = k8sapi.ToRawMessage(coreConfig.PodsLogTail)
+			tailSchema.Description = fmt.Sprintf("Number of lines to retrieve from the end of the logs (Optional, default: %d)", coreConfig.PodsLogTail)
+			if coreConfig.PodsLogMaxTail > 0 {
+				maximum := float64(coreConfig.PodsLogMaxTail)
+				tailSchema.Maximum = &maximum
+				tailSchema.Description += fmt.Sprintf(", at most %d lines are returned", coreConfig.PodsLogMaxTail)
+			}
+			schema.Properties["tail"] = &tailSchema
+			tool.Tool.InputSchema = &schema
+		}
+		handler := tool.Handler
+		tool.Handler = func(params k8sapi.ToolHandlerParams) (*k8sapi.ToolCallResult, error) {
+			return handler(WithArguments(params, func(args map[string]any) {
+				args["tail"] = coreConfig.podsLogTail(args["tail"])
+			}))
+		}
+		tools[i] = tool
+	}
+	return tools
+}
+
+// RequiredPermissions returns the permissions of the core tools operating on known resources,
+// the generic resources_* tools require permissions on the resources they are called with.
+func (t *coreToolset) RequiredPermissions() []localapi.Permission {
+	return []localapi.Permission{
+		{Verb: "list", Resource: "events", Tools: []string{"events_list"}},
+		{Verb: "list", Resource: "namespaces", Tools: []string{"namespaces_list"}},
+		{Verb: "get", Resource: "nodes", Tools: []string{"nodes_log", "nodes_stats_summary"}},
+		{Verb: "get", Resource: "nodes", Subresource: "proxy", Tools: []string{"nodes_log", "nodes_stats_summary"}},
+		{Verb: "list", Group: "metrics.k8s.io", Resource: "nodes", Tools: []string{"nodes_top"}},
+		{Verb: "list", Resource: "pods", Tools: []string{"pods_list", "pods_list_in_namespace"}},
+		{Verb: "get", Resource: "pods", Tools: []string{"pods_get", "pods_exec"}},
+		{Verb: "delete", Resource: "pods", Tools: []string{"pods_delete"}},
+		{Verb: "create", Resource: "pods", Subresource: "exec", Tools: []string{"pods_exec"}},
+		{Verb: "get", Resource: "pods", Subresource: "log", Tools: []string{"pods_log"}},
+		{Verb: "create", Resource: "pods", Tools: []string{"pods_run"}},
+		{Verb: "create", Resource: "services", Tools: []string{"pods_run"}},
+		{Verb: "list", Group: "metrics.k8s.io", Resource: "pods", Tools: []string{"pods_top"}},
+	}
+}
+
+// podsLogTail returns the number of log lines to retrieve for the tail argument.
+func (c *CoreConfig) podsLogTail(arg any) any {
+	var tail int64
+	switch v := arg.(type) {
+	case float64:
+		tail = int64(v)
+	case int:
+		tail = int64(v)
+	case int64:
+		tail = v
+	case nil:
+	default:
+		// let the tool report the invalid argument
+		return arg
+	}
+	if tail <= 0 {
+		tail = c.PodsLogTail
+	}
+	if c.PodsLogMaxTail > 0 && tail > c.PodsLogMaxTail {
+		tail = c.PodsLogMaxTail
+	}
+	return tail
+}
@@ -0,0 +1,81 @@
+package toolsets
+
+import (
+	"fmt"
+	"strings"
+
+	k8sapi "github.com/containers/kubernetes-mcp-server/pkg/api"
+	internalk8s "github.com/containers/kubernetes-mcp-server/pkg/kubernetes"
+
+	localapi "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/api"
+)
+
+// HelmConfig is the configuration of the helm toolset ([toolset_configs.helm] section).
+type HelmConfig struct {
+	// DefaultRepository is prepended to the chart references of helm_install without a repository,
+	// either an OCI registry (e.g. oci://ghcr.io/nginxinc/charts) or the name of a configured repository.
+	DefaultRepository string `toml:"default_repository"`
+}
+
+// Validate checks the helm toolset configuration for errors.
+func (c *HelmConfig) Validate() error {
+	if strings.HasSuffix(c.DefaultRepository, "/") {
+		return fmt.Errorf("default_repository %q must not end with a slash", c.DefaultRepository)
+	}
+	return nil
+}
+
+// chart returns the chart reference qualified with the default repository if it has none.
+func (c *HelmConfig) chart(chart string) string {
+	if c.DefaultRepository == "" || chart == "" || strings.Contains(chart, "/") || strings.HasSuffix(chart, ".tgz") {
+		return chart
+	}
+	return c.DefaultRepository + "/" + chart
+}
+
+type helmToolset struct {
+	k8sapi.Toolset
+}
+
+var (
+	_ localapi.ConfigurableToolset = &helmToolset{}
+	_ localapi.PermissionsProvider = &helmToolset{}
+)
+
+func (t *helmToolset) DefaultConfig() localapi.ToolsetConfig {
+	return &HelmConfig{}
+}
+
+func (t *helmToolset) GetConfiguredTools(o internalk8s.Openshift, cfg localapi.ToolsetConfig) []k8sapi.ServerTool {
+	helmConfig := cfg.(*HelmConfig)
+	tools := t.GetTools(o)
+	if helmConfig.DefaultRepository == "" {
+		return tools
+	}
+	for i, tool := range tools {
+		if tool.Tool.Name != "helm_install" {
+			continue
+		}
+		tool.Tool.Description += fmt.Sprintf(". Charts without a repository are installed from %s", helmConfig.DefaultRepository)
+		handler := tool.Handler
+		tool.Handler = func(params k8sapi.ToolHandlerParams) (*k8sapi.ToolCallResult, error) {
+			return handler(WithArguments(params, func(args map[string]any) {
+				if chart, ok := args["chart"].(string); ok {
+					args["chart"] = helmConfig.chart(chart)
+				}
+			}))
+		}
+		tools[i] = tool
+	}
+	return tools
+}
+
+// RequiredPermissions returns the permissions on the Helm release storage (Secrets), installing a chart
+// also requires the permissions on the resources of the chart.
+func (t *helmToolset) RequiredPermissions() []localapi.Permission {
+	return []localapi.Permission{
+		{Verb: "list", Resource: "secrets", Tools: []string{"helm_list", "helm_install", "helm_uninstall"}},
+		{Verb: "create", Resource: "secrets", Tools: []string{"helm_install"}},
+		{Verb: "delete", Resource: "secrets", Tools: []string{"helm_uninstall"}},
+	}
+}
@@ -0,0 +1,47 @@
+// Package toolsets provides the registered toolsets, adding the configuration sections of the
+// kubernetes-mcp-server built-in toolsets.
+//
+// Custom toolsets receive their configuration by implementing api.ConfigurableToolset, the built-in
+// toolsets can't be changed so they are wrapped by adapters applying the configuration to their tools.
+package toolsets
+
+import (
+	k8sapi "github.com/containers/kubernetes-mcp-server/pkg/api"
+	"github.com/containers/kubernetes-mcp-server/pkg/toolsets"
+)
+
+// adapters wrap the built-in toolsets with their configuration, by toolset name.
+var adapters = map[string]func(k8sapi.Toolset) k8sapi.Toolset{
+	"core": func(toolset k8sapi.Toolset) k8sapi.Toolset { return &coreToolset{Toolset: toolset} },
+	"helm": func(toolset k8sapi.Toolset) k8sapi.Toolset { return &helmToolset{Toolset: toolset} },
+}
+
+// ToolsetFromString returns the registered toolset with the provided name, or nil if there is none.
+func ToolsetFromString(name string) k8sapi.Toolset {
+	toolset := toolsets.ToolsetFromString(name)
+	if toolset == nil {
+		return nil
+	}
+	if adapter, ok := adapters[toolset.GetName()]; ok {
+		return adapter(toolset)
+	}
+	return toolset
+}
+
+// arguments replaces the tool call arguments.
+type arguments map[string]any
+
+func (a arguments) GetArguments() map[string]any {
+	return a
+}
+
+// WithArguments returns the tool handler params with a copy of the tool call arguments, modified by the provided function.
+func WithArguments(params k8sapi.ToolHandlerParams, modify func(args map[string]any)) k8sapi.ToolHandlerParams {
+	args := make(arguments, len(params.GetArguments()))
+	for k, v := range params.GetArguments() {
+		args[k] = v
+	}
+	modify(args)
+	params.ToolCallRequest = args
+	return params
+}
@@ -0,0 +1,237 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the static API key and mTLS authentication of the HTTP transport.
+package unit
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	k8sapi "github.com/containers/kubernetes-mcp-server/pkg/api"
+	staticconfig "github.com/containers/kubernetes-mcp-server/pkg/config"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/ptr"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/auth"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	localhttp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/http"
+)
+
+func apiKeyHash(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
+
+func newTestAuthenticator(t *testing.T, cfg config.AuthenticationConfig) *auth.Authenticator {
+	authenticator, err := auth.NewAuthenticator(cfg)
+	require.NoError(t, err, "Authenticator should be created")
+	return authenticator
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	authenticator := newTestAuthenticator(t, config.AuthenticationConfig{
+		APIKeys: []config.APIKeyConfig{{Name: "ci", Hash: apiKeyHash("s3cret"), Groups: []string{"automation"}, Scopes: []string{"read"}}},
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	r.Header.Set("Authorization", "Bearer s3cret")
+	principal, err := authenticator.Authenticate(r)
+	require.NoError(t, err)
+	require.NotNil(t, principal, "Known API keys should be authenticated")
+	assert.Equal(t, auth.MethodAPIKey, principal.Method)
+	assert.Equal(t, "ci", principal.User, "User should default to the key name")
+	assert.Equal(t, []string{"automation"}, principal.Groups)
+
+	r.Header.Set("Authorization", "Bearer other")
+	principal, err = authenticator.Authenticate(r)
+	require.NoError(t, err)
+	assert.Nil(t, principal, "Unknown API keys should not be authenticated")
+}
+
+func TestAuthenticateClientCertificate(t *testing.T) {
+	cfg := config.Default().Authentication
+	cfg.MTLS.Enabled = true
+	cfg.MTLS.ClientCAFile = "ca.crt"
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "ci-bot", Organization: []string{"platform"}}}
+	r := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+
+	principal, err := newTestAuthenticator(t, cfg).Authenticate(r)
+	require.NoError(t, err)
+	require.NotNil(t, principal, "Verified client certificates should be authenticated")
+	assert.Equal(t, auth.MethodMTLS, principal.Method)
+	assert.Equal(t, "ci-bot", principal.User, "User should be mapped from the common name")
+	assert.Equal(t, []string{"platform"}, principal.Groups, "Groups should be mapped from the organization")
+
+	cfg.MTLS.Subjects = []config.MTLSSubjectConfig{{Subject: "^CN=ci-bot,O=platform$", User: "system:serviceaccount:ci:bot", Scopes: []string{"read"}}}
+	principal, err = newTestAuthenticator(t, cfg).Authenticate(r)
+	require.NoError(t, err)
+	assert.Equal(t, "system:serviceaccount:ci:bot", principal.User, "Subject mappings should override the user")
+	assert.Equal(t, []string{"read"}, principal.Scopes, "Subject mappings should override the scopes")
+
+	cfg.MTLS.Subjects = []config.MTLSSubjectConfig{{Subject: "^CN=other$"}}
+	_, err = newTestAuthenticator(t, cfg).Authenticate(r)
+	assert.Error(t, err, "Certificates not matching any subject mapping should be rejected")
+}
+
+func TestPrincipalScopes(t *testing.T) {
+	readOnly := k8sapi.ServerTool{Tool: k8sapi.Tool{Name: "pods_list", Annotations: k8sapi.ToolAnnotations{ReadOnlyHint: ptr.To(true)}}}
+	destructive := k8sapi.ServerTool{Tool: k8sapi.Tool{Name: "pods_delete", Annotations: k8sapi.ToolAnnotations{DestructiveHint: ptr.To(true)}}}
+	write := k8sapi.ServerTool{Tool: k8sapi.Tool{Name: "pods_run"}}
+
+	principal := &auth.Principal{Name: "ci", Method: auth.MethodAPIKey, Scopes: []string{"read", "write"}}
+	assert.NoError(t, principal.CanCall(readOnly), "read scope should allow read-only tools")
+	assert.NoError(t, principal.CanCall(write), "write scope should allow non destructive tools")
+	assert.Error(t, principal.CanCall(destructive), "destructive tools require the destructive scope")
+
+	unrestricted := &auth.Principal{Name: "admin", Method: auth.MethodAPIKey}
+	assert.NoError(t, unrestricted.CanCall(destructive), "Principals without scopes should call any tool")
+}
+
+func TestAuthenticationMiddleware(t *testing.T) {
+	authenticator := newTestAuthenticator(t, config.AuthenticationConfig{
+		APIKeys: []config.APIKeyConfig{{Name: "ci", Hash: apiKeyHash("s3cret")}},
+	})
+	var received http.Header
+	var principal *auth.Principal
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, principal = r.Header.Clone(), auth.PrincipalFromContext(r.Context())
+	})
+	oauth := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) })
+	staticConfig := staticconfig.Default()
+	handler := localhttp.AuthenticationMiddleware(authenticator, staticConfig, oauth)(next)
+
+	t.Run("API key is authenticated and removed", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		r.Header.Set("Authorization", "Bearer s3cret")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		require.NotNil(t, received)
+		assert.Empty(t, received.Get("Authorization"), "API key should not be forwarded")
+		require.NotNil(t, principal, "The principal should be set in the request context")
+		assert.Equal(t, "ci", principal.Name)
+	})
+	t.Run("Missing credentials are rejected", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/mcp", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "API key or client certificate required")
+	})
+	t.Run("Invalid API keys are rejected", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		r.Header.Set("Authorization", "Bearer wrong")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid API key", "The response should match the failure")
+	})
+	t.Run("Unauthenticated requests use OAuth if required", func(t *testing.T) {
+		staticConfig.RequireOAuth = true
+		defer func() { staticConfig.RequireOAuth = false }()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/mcp", nil))
+		assert.Equal(t, http.StatusTeapot, w.Code)
+	})
+	t.Run("Health endpoint is unprotected", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		assert.Equal(t, http.StatusTeapot, w.Code, "Health checks should skip the authentication")
+	})
+	t.Run("Probe endpoints skip the authentication and OAuth", func(t *testing.T) {
+		staticConfig.RequireOAuth = true
+		defer func() { staticConfig.RequireOAuth = false }()
+		received = nil
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz?verbose", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotNil(t, received, "Probes should be served without credentials")
+	})
+}
+
+func TestAuthenticationConfigValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(cfg *config.Config)
+	}{
+		{"invalid hash", func(cfg *config.Config) {
+			cfg.Authentication.APIKeys = []config.APIKeyConfig{{Name: "ci", Hash: "s3cret"}}
+		}},
+		{"invalid scope", func(cfg *config.Config) {
+			cfg.Authentication.APIKeys = []config.APIKeyConfig{{Name: "ci", Hash: apiKeyHash("s3cret"), Scopes: []string{"admin"}}}
+		}},
+		{"mtls without tls", func(cfg *config.Config) {
+			cfg.Authentication.MTLS.Enabled = true
+			cfg.Authentication.MTLS.ClientCAFile = "ca.crt"
+		}},
+		{"mtls without client ca", func(cfg *config.Config) {
+			cfg.Authentication.MTLS.Enabled = true
+			cfg.TLS = config.TLSConfig{CertFile: "tls.crt", KeyFile: "tls.key"}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			tt.mutate(cfg)
+			assert.Error(t, cfg.Validate())
+		})
+	}
+}
+
+// authorizedTransport sets the Authorization header of the requests.
+type authorizedTransport struct {
+	base          http.RoundTripper
+	authorization string
+}
+
+func (a *authorizedTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", a.authorization)
+	return a.base.RoundTrip(r)
+}
+
+func TestServePrincipalScopes(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "mcp.sock")
+	extensions := config.Default()
+	extensions.Listen = config.ListenConfig{Address: "unix://" + socket, SocketMode: "0600"}
+	extensions.Authentication.APIKeys = []config.APIKeyConfig{
+		{Name: "reader", Hash: apiKeyHash("reader-key"), Scopes: []string{config.ScopeRead}},
+		{Name: "writer", Hash: apiKeyHash("writer-key"), Scopes: []string{config.ScopeWrite}},
+	}
+	stop := serveUnix(t, extensions)
+	defer func() { _ = stop() }()
+
+	transports := map[string]func(*http.Client) mcp.Transport{
+		"streamable": func(c *http.Client) mcp.Transport {
+			return &mcp.StreamableClientTransport{Endpoint: "http://localhost/mcp", HTTPClient: c}
+		},
+		"sse": func(c *http.Client) mcp.Transport {
+			return &mcp.SSEClientTransport{Endpoint: "http://localhost/sse", HTTPClient: c}
+		},
+	}
+	for name, transport := range transports {
+		t.Run(name, func(t *testing.T) {
+			call := func(key string) *mcp.CallToolResult {
+				httpClient := &http.Client{Transport: &authorizedTransport{base: unixHTTPClient(socket).Transport, authorization: "Bearer " + key}}
+				client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "1.0.0"}, nil)
+				session, err := client.Connect(context.Background(), transport(httpClient), nil)
+				require.NoError(t, err)
+				defer func() { _ = session.Close() }()
+				result, err := session.CallTool(context.Background(), &mcp.CallToolParams{Name: "test_greet"})
+				require.NoError(t, err)
+				return result
+			}
+			assert.False(t, call("reader-key").IsError, "The principal scopes should allow the tool")
+			result := call("writer-key")
+			assert.True(t, result.IsError, "The principal scopes should reach the tool handlers")
+			assert.Contains(t, toolResultTexts(result), `tool test_greet requires the "read" scope, which is not granted to api-key writer`)
+		})
+	}
+}
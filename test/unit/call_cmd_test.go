@@ -0,0 +1,73 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the call subcommand.
+package unit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	k8sapi "github.com/containers/kubernetes-mcp-server/pkg/api"
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/ptr"
+)
+
+// echoTools are the tools of the test-echo toolset, whose tool echoes its typed arguments.
+func echoTools() []k8sapi.ServerTool {
+	return []k8sapi.ServerTool{{
+		Tool: k8sapi.Tool{
+			Name:        "test_echo",
+			Description: "Echoes the arguments",
+			InputSchema: &jsonschema.Schema{Type: "object", Properties: map[string]*jsonschema.Schema{
+				"text":  {Type: "string"},
+				"count": {Type: "integer"},
+				"loud":  {Type: "boolean"},
+				"tags":  {Type: "array", Items: &jsonschema.Schema{Type: "string"}},
+			}},
+			Annotations: k8sapi.ToolAnnotations{ReadOnlyHint: ptr.To(true)},
+		},
+		Handler: func(params k8sapi.ToolHandlerParams) (*k8sapi.ToolCallResult, error) {
+			arguments, err := json.Marshal(params.GetArguments())
+			return k8sapi.NewToolCallResult(string(arguments), err), nil
+		},
+		ClusterAware: ptr.To(false),
+	}}
+}
+
+func TestCallCommand(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(impersonationKubeconfig), 0600))
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(configPath, []byte("[toolset_configs.test-greeting]\ngreeting = \"hi\""), 0600))
+	flags := []string{"--kubeconfig", kubeconfigPath, "--config", configPath, "--toolsets", "core,test-greeting,test-echo"}
+
+	out, err := runCommand(t, append([]string{"call", "test_greet"}, flags...)...)
+	require.NoError(t, err)
+	assert.Equal(t, "hi", strings.TrimSpace(out), "The tool should be built with the configuration")
+
+	out, err = runCommand(t, append([]string{"call", "test_echo", "--arg", "text=a=b", "--arg", "count=3",
+		"--arg", "loud=true", "--arg", `tags=["x"]`, "--arg", "other=4"}, flags...)...)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"text":"a=b","count":3,"loud":true,"tags":["x"],"other":"4"}`, out,
+		"The arguments should be converted to the types of the input schema")
+
+	out, err = runCommand(t, append([]string{"call", "test_echo", "--json", `{"count":1}`}, flags...)...)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"count":1}`, out)
+
+	_, err = runCommand(t, append([]string{"call", "test_echo", "--arg", "count=many"}, flags...)...)
+	assert.ErrorContains(t, err, "invalid --arg count")
+	_, err = runCommand(t, append([]string{"call", "test_echo", "--arg", "count"}, flags...)...)
+	assert.ErrorContains(t, err, "key=value is expected")
+	_, err = runCommand(t, append([]string{"call", "test_echo", "--arg", "count=1", "--json", "{}"}, flags...)...)
+	assert.Error(t, err, "--arg and --json should be mutually exclusive")
+
+	_, err = runCommand(t, append([]string{"call", "pods_delete", "--read-only", "--arg", "name=pod"}, flags...)...)
+	assert.ErrorContains(t, err, `unknown tool "pods_delete"`, "The same gating as a real session should apply")
+	_, err = runCommand(t, append([]string{"call", "pods_list_in_namespace", "--arg", "namespace=prod"}, flags...)...)
+	assert.ErrorContains(t, err, "tool pods_list_in_namespace failed", "Tool errors should fail the command")
+}
@@ -111,6 +111,11 @@ func TestCLIFlags(t *testing.T) {
 		"read-only",
 		"disable-destructive",
 		"disable-multi-cluster",
+		"tls-cert-file",
+		"tls-key-file",
+		"tls-min-version",
+		"config-dir",
+		"profile",
 	}
 
 	for _, flagName := range expectedFlags {
@@ -197,8 +202,12 @@ func TestCLICommandStructure(t *testing.T) {
 	assert.NotEmpty(t, rootCmd.Long, "Command should have a Long description")
 	assert.NotNil(t, rootCmd.RunE, "Command should have a RunE function")
 
-	// Verify no subcommands (should be a single command like k8sms)
-	assert.Empty(t, rootCmd.Commands(), "Root command should not have subcommands")
+	// Verify the subcommands, the root command serves the MCP server like k8sms
+	subcommands := make([]string, 0)
+	for _, subcommand := range rootCmd.Commands() {
+		subcommands = append(subcommands, subcommand.Name())
+	}
+	assert.Equal(t, []string{"call", "config", "doctor", "inspect", "tools"}, subcommands, "Root command should only have the inspection subcommands")
 }
 
 func TestHelpOutput(t *testing.T) {
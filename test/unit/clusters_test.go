@@ -0,0 +1,347 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the cluster registry provider and its sources.
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	staticconfig "github.com/containers/kubernetes-mcp-server/pkg/config"
+	internalk8s "github.com/containers/kubernetes-mcp-server/pkg/kubernetes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/clusters"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/impersonate"
+	localmcp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/mcp"
+)
+
+// clusterKubeconfig returns a kubeconfig with a single context for the cluster server.
+func clusterKubeconfig(server string) string {
+	return `apiVersion: v1
+kind: Config
+clusters:
+- name: cluster
+  cluster:
+    server: ` + server + `
+contexts:
+- name: ctx
+  context:
+    cluster: cluster
+    user: admin
+current-context: ctx
+users:
+- name: admin
+  user:
+    token: server-token
+`
+}
+
+// writeClusterKubeconfigs writes the kubeconfig files of the clusters (file name to server) to a new directory.
+func writeClusterKubeconfigs(t *testing.T, servers map[string]string) string {
+	dir := t.TempDir()
+	for name, server := range servers {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(clusterKubeconfig(server)), 0600))
+	}
+	return dir
+}
+
+// newClusterRegistry creates the cluster registry provider of the config file.
+func newClusterRegistry(t *testing.T, toml string) *clusters.Registry {
+	staticConfig, err := staticconfig.ReadToml([]byte(toml))
+	require.NoError(t, err)
+	provider, err := internalk8s.NewProvider(staticConfig)
+	require.NoError(t, err)
+	t.Cleanup(provider.Close)
+	registry, ok := provider.(*clusters.Registry)
+	require.True(t, ok, "The registry strategy should create the cluster registry")
+	return registry
+}
+
+func TestClusterRegistryConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		registry string
+		wantErr  string
+	}{
+		{name: "valid", registry: `
+[[cluster_provider_configs.registry.clusters]]
+name = "prod-eu-1"
+server = "https://prod-eu-1.example.com:6443"
+token_file = "/var/run/secrets/prod-eu-1/token"
+labels = { env = "prod", region = "eu" }
+[[cluster_provider_configs.registry.kubeconfig_dirs]]
+path = "/etc/mcp/clusters"
+[[cluster_provider_configs.registry.secrets]]
+namespace = "argocd"
+label_selector = "argocd.argoproj.io/secret-type=cluster"`},
+		{name: "no sources", registry: "[cluster_provider_configs.registry]\ndefault_cluster = \"a\"", wantErr: "clusters, kubeconfig_dirs or secrets are required"},
+		{name: "unknown setting", registry: "[cluster_provider_configs.registry]\nrefresh = \"1m\"", wantErr: "unknown settings refresh"},
+		{name: "invalid refresh interval", registry: "[cluster_provider_configs.registry]\nrefresh_interval = \"often\"\n[[cluster_provider_configs.registry.kubeconfig_dirs]]\npath = \"/clusters\"", wantErr: "invalid refresh_interval"},
+		{name: "missing name", registry: "[[cluster_provider_configs.registry.clusters]]\nserver = \"https://a\"", wantErr: "name is required"},
+		{name: "invalid name", registry: "[[cluster_provider_configs.registry.clusters]]\nname = \"prod eu\"\nserver = \"https://a\"", wantErr: "invalid cluster name"},
+		{name: "duplicate name", registry: "[[cluster_provider_configs.registry.clusters]]\nname = \"a\"\nserver = \"https://a\"\n[[cluster_provider_configs.registry.clusters]]\nname = \"a\"\nserver = \"https://b\"", wantErr: "name is already used"},
+		{name: "missing server", registry: "[[cluster_provider_configs.registry.clusters]]\nname = \"a\"", wantErr: "kubeconfig or server is required"},
+		{name: "kubeconfig and server", registry: "[[cluster_provider_configs.registry.clusters]]\nname = \"a\"\nserver = \"https://a\"\nkubeconfig = \"/a\"", wantErr: "mutually exclusive"},
+		{name: "token file with kubeconfig", registry: "[[cluster_provider_configs.registry.clusters]]\nname = \"a\"\nkubeconfig = \"/a\"\ntoken_file = \"/token\"", wantErr: "require server"},
+		{name: "invalid label", registry: "[[cluster_provider_configs.registry.clusters]]\nname = \"a\"\nserver = \"https://a\"\nlabels = { \"-env\" = \"prod\" }", wantErr: "invalid label \"-env\""},
+		{name: "missing dir path", registry: "[[cluster_provider_configs.registry.kubeconfig_dirs]]\nlabels = { env = \"dev\" }", wantErr: "path is required"},
+		{name: "invalid label selector", registry: "[[cluster_provider_configs.registry.secrets]]\nlabel_selector = \"a in (\"", wantErr: "invalid secrets label_selector"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := staticconfig.ReadToml([]byte("cluster_provider_strategy = \"registry\"\n" + tt.registry))
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("relative paths", func(t *testing.T) {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, "config.toml")
+		require.NoError(t, os.WriteFile(configPath, []byte(`
+[[cluster_provider_configs.registry.kubeconfig_dirs]]
+path = "clusters"
+`), 0600))
+		staticConfig, err := staticconfig.Read(configPath)
+		require.NoError(t, err)
+		providerConfig, ok := staticConfig.GetProviderConfig(config.ClusterProviderRegistry)
+		require.True(t, ok)
+		assert.Equal(t, filepath.Join(dir, "clusters"), providerConfig.(*config.ClusterRegistryConfig).KubeconfigDirs[0].Path,
+			"The paths should be relative to the config file")
+	})
+}
+
+func TestClusterRegistry(t *testing.T) {
+	dir := writeClusterKubeconfigs(t, map[string]string{
+		"dev-1.kubeconfig": "https://dev-1.example.com:6443",
+		"dev-2.yaml":       "https://dev-2.example.com:6443",
+		"prod-eu-1":        "https://shadowed.example.com:6443",
+		".hidden":          "https://hidden.example.com:6443",
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.kubeconfig"), []byte("not a kubeconfig"), 0600))
+	registry := newClusterRegistry(t, `
+cluster_provider_strategy = "registry"
+[cluster_provider_configs.registry]
+default_cluster = "prod-eu-1"
+[[cluster_provider_configs.registry.clusters]]
+name = "prod-eu-1"
+server = "https://prod-eu-1.example.com:6443"
+token_file = "/var/run/secrets/prod-eu-1/token"
+labels = { env = "prod", region = "eu" }
+[[cluster_provider_configs.registry.kubeconfig_dirs]]
+path = "`+dir+`"
+labels = { env = "dev" }
+`)
+
+	targets, err := registry.GetTargets(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dev-1", "dev-2", "prod-eu-1"}, targets, "The invalid and hidden kubeconfig files should be skipped")
+	assert.Equal(t, "prod-eu-1", registry.GetDefaultTarget())
+	assert.Equal(t, clusters.TargetParameterName, registry.GetTargetParameterName())
+
+	labels := map[string]map[string]string{}
+	for _, cluster := range registry.Clusters() {
+		labels[cluster.Name] = cluster.Labels
+	}
+	assert.Equal(t, map[string]map[string]string{
+		"dev-1":     {"env": "dev"},
+		"dev-2":     {"env": "dev"},
+		"prod-eu-1": {"env": "prod", "region": "eu"},
+	}, labels)
+
+	kubeconfig, err := registry.Kubeconfig("prod-eu-1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://prod-eu-1.example.com:6443", kubeconfig.Clusters[kubeconfig.Contexts[kubeconfig.CurrentContext].Cluster].Server,
+		"The clusters of the config should take precedence over the kubeconfig files")
+	kubeconfig, err = registry.Kubeconfig("dev-2")
+	require.NoError(t, err)
+	assert.Equal(t, "https://dev-2.example.com:6443", kubeconfig.Clusters[kubeconfig.Contexts[kubeconfig.CurrentContext].Cluster].Server)
+
+	k, err := registry.GetDerivedKubernetes(context.Background(), "dev-1")
+	require.NoError(t, err)
+	view, err := k.ConfigurationView(true)
+	require.NoError(t, err)
+	assert.Contains(t, mustJSON(t, view), "https://dev-1.example.com:6443", "The client should target the cluster")
+	_, err = registry.GetDerivedKubernetes(context.Background(), "missing")
+	assert.ErrorContains(t, err, `unknown cluster "missing"`)
+
+	clients := impersonate.NewClients(staticconfig.Default())
+	defer clients.Reset()
+	clients.SetKubeconfigSource(registry)
+	k, err = clients.Kubernetes("dev-2", &impersonate.Identity{User: "alice"})
+	require.NoError(t, err, "The impersonating clients should use the kubeconfig of the registry cluster")
+	view, err = k.ConfigurationView(true)
+	require.NoError(t, err)
+	assert.Contains(t, mustJSON(t, view), "https://dev-2.example.com:6443")
+	assert.Contains(t, mustJSON(t, view), `"as":"alice"`)
+
+	t.Run("default cluster not found", func(t *testing.T) {
+		staticConfig, err := staticconfig.ReadToml([]byte(`
+cluster_provider_strategy = "registry"
+[cluster_provider_configs.registry]
+default_cluster = "missing"
+[[cluster_provider_configs.registry.kubeconfig_dirs]]
+path = "` + dir + `"
+`))
+		require.NoError(t, err)
+		_, err = internalk8s.NewProvider(staticConfig)
+		assert.ErrorContains(t, err, `the default cluster "missing" is not in the cluster registry`)
+	})
+
+	t.Run("missing section", func(t *testing.T) {
+		_, err := internalk8s.NewProvider(&staticconfig.StaticConfig{ClusterProviderStrategy: config.ClusterProviderRegistry})
+		assert.ErrorContains(t, err, "requires the [cluster_provider_configs.registry] section")
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		staticConfig, err := staticconfig.ReadToml([]byte(`
+cluster_provider_strategy = "registry"
+[[cluster_provider_configs.registry.kubeconfig_dirs]]
+path = "` + filepath.Join(dir, "missing") + `"
+`))
+		require.NoError(t, err)
+		_, err = internalk8s.NewProvider(staticConfig)
+		assert.ErrorContains(t, err, "failed to read the kubeconfig directory")
+	})
+}
+
+func mustJSON(t *testing.T, v any) string {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestClusterRegistrySecrets(t *testing.T) {
+	argoConfig, err := json.Marshal(map[string]any{
+		"bearerToken":     "argo-token",
+		"tlsClientConfig": map[string]any{"caData": []byte("ca"), "serverName": "prod-us-1"},
+	})
+	require.NoError(t, err)
+	client := fake.NewClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-prod-us-1", Namespace: "argocd",
+				Labels: map[string]string{"argocd.argoproj.io/secret-type": "cluster", "env": "prod"}},
+			Data: map[string][]byte{"name": []byte("prod-us-1"), "server": []byte("https://prod-us-1.example.com:6443"), "config": argoConfig},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "edge-7-kubeconfig", Namespace: "fleet",
+				Labels: map[string]string{"cluster.x-k8s.io/cluster-name": "edge-7"}},
+			Data: map[string][]byte{"value": []byte(clusterKubeconfig("https://edge-7.example.com:6443"))},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "staging", Namespace: "fleet"},
+			Data:       map[string][]byte{"kubeconfig": []byte(clusterKubeconfig("https://staging.example.com:6443"))},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "fleet"},
+			Data:       map[string][]byte{"password": []byte("secret")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "argo-without-credentials", Namespace: "argocd"},
+			Data:       map[string][]byte{"server": []byte("https://a.example.com"), "config": []byte(`{"tlsClientConfig":{}}`)},
+		},
+	)
+
+	source := clusters.SecretsSource(client, config.ClusterSecretsConfig{Labels: map[string]string{"managed-by": "fleet"}})
+	found, err := source.Clusters(context.Background())
+	require.NoError(t, err)
+	byName := map[string]*clusters.Cluster{}
+	for _, cluster := range found {
+		byName[cluster.Name] = cluster
+	}
+	require.Len(t, byName, 3, "The Secrets without supported cluster credentials should be skipped")
+
+	prod := byName["prod-us-1"]
+	require.NotNil(t, prod, "The Argo CD cluster should be named after its name key")
+	assert.Equal(t, "Secret argocd/cluster-prod-us-1", prod.Source)
+	assert.Equal(t, map[string]string{"argocd.argoproj.io/secret-type": "cluster", "env": "prod", "managed-by": "fleet"}, prod.Labels)
+	cluster := prod.Kubeconfig.Clusters[prod.Kubeconfig.Contexts[prod.Kubeconfig.CurrentContext].Cluster]
+	assert.Equal(t, "https://prod-us-1.example.com:6443", cluster.Server)
+	assert.Equal(t, []byte("ca"), cluster.CertificateAuthorityData)
+	assert.Equal(t, "prod-us-1", cluster.TLSServerName)
+	assert.Equal(t, "argo-token", prod.Kubeconfig.AuthInfos[prod.Kubeconfig.Contexts[prod.Kubeconfig.CurrentContext].AuthInfo].Token)
+
+	edge := byName["edge-7"]
+	require.NotNil(t, edge, "The Cluster API cluster should be named after its cluster name label")
+	assert.Equal(t, "https://edge-7.example.com:6443", edge.Kubeconfig.Clusters["cluster"].Server)
+	assert.NotNil(t, byName["staging"], "The kubeconfig Secrets should be named after the Secret")
+
+	source = clusters.SecretsSource(client, config.ClusterSecretsConfig{Namespace: "argocd", LabelSelector: "argocd.argoproj.io/secret-type=cluster"})
+	found, err = source.Clusters(context.Background())
+	require.NoError(t, err)
+	require.Len(t, found, 1, "The Secrets should be selected by namespace and label selector")
+	assert.Equal(t, "prod-us-1", found[0].Name)
+}
+
+func TestClusterRegistryRefresh(t *testing.T) {
+	dir := writeClusterKubeconfigs(t, map[string]string{"dev-1": "https://dev-1.example.com:6443"})
+	registry := newClusterRegistry(t, `
+cluster_provider_strategy = "registry"
+[cluster_provider_configs.registry]
+refresh_interval = "20ms"
+[[cluster_provider_configs.registry.kubeconfig_dirs]]
+path = "`+dir+`"
+`)
+	changed := make(chan struct{}, 10)
+	registry.WatchTargets(func() error {
+		changed <- struct{}{}
+		return nil
+	})
+
+	select {
+	case <-changed:
+		t.Fatal("The registry should not be reloaded if the clusters didn't change")
+	case <-time.After(100 * time.Millisecond):
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "dev-2"), []byte(clusterKubeconfig("https://dev-2.example.com:6443")), 0600))
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The registry should be reloaded once a cluster is added")
+	}
+}
+
+func TestServerClusterRegistry(t *testing.T) {
+	dir := writeClusterKubeconfigs(t, map[string]string{
+		"dev-1": "https://dev-1.example.com:6443",
+		"dev-2": "https://dev-2.example.com:6443",
+	})
+	staticConfig, err := staticconfig.ReadToml([]byte(`
+toolsets = ["core"]
+cluster_provider_strategy = "registry"
+[[cluster_provider_configs.registry.kubeconfig_dirs]]
+path = "` + dir + `"
+`))
+	require.NoError(t, err)
+	server, err := localmcp.NewServer(localmcp.Configuration{StaticConfig: staticConfig})
+	require.NoError(t, err)
+	defer server.Close()
+
+	session, err := connectInMemory(t, server)
+	require.NoError(t, err)
+	defer func() { _ = session.Close() }()
+	tools, err := session.ListTools(context.Background(), nil)
+	require.NoError(t, err)
+	for _, tool := range tools.Tools {
+		if tool.Name != "pods_list" {
+			continue
+		}
+		schema := mustJSON(t, tool.InputSchema)
+		assert.Contains(t, schema, `"cluster"`, "The tools should have the cluster parameter")
+		assert.Contains(t, schema, `"enum":["dev-1","dev-2"]`, "The cluster parameter should list the clusters of the registry")
+		return
+	}
+	t.Fatal("pods_list should be served")
+}
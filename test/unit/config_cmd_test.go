@@ -0,0 +1,86 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the config validate and config print subcommands.
+package unit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/cmd"
+)
+
+// runCommand runs the command with the arguments, returning its output.
+func runCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	return runCommandWithInput(t, "", args...)
+}
+
+// runCommandWithInput runs the command with the arguments reading the provided standard input, returning its output.
+func runCommandWithInput(t *testing.T, input string, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	rootCmd := cmd.NewExtendableMCPServer(genericiooptions.IOStreams{In: strings.NewReader(input), Out: &stdout, ErrOut: &stderr})
+	rootCmd.SetArgs(args)
+	rootCmd.SetOut(&stdout)
+	rootCmd.SetErr(&stderr)
+	err := rootCmd.Execute()
+	return stdout.String(), err
+}
+
+func TestConfigValidateCommand(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.toml")
+	require.NoError(t, os.WriteFile(valid, []byte("toolsets = [\"core\"]\n"), 0600))
+	out, err := runCommand(t, "config", "validate", "--config", valid)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Configuration is valid")
+
+	invalid := filepath.Join(dir, "invalid.toml")
+	require.NoError(t, os.WriteFile(invalid, []byte(`
+list_output = "xml"
+toolsets = ["core", "nope"]
+
+[toolset_configs.core]
+pods_log_tail = 0
+
+[profiles.ro]
+path = "/mcp"
+`), 0600))
+	_, err = runCommand(t, "config", "validate", "--config", invalid)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid output name: xml", "All the errors should be reported")
+	assert.Contains(t, err.Error(), "invalid toolset name: nope", "All the errors should be reported")
+	assert.Contains(t, err.Error(), "invalid toolset_configs.core configuration", "All the errors should be reported")
+	assert.Contains(t, err.Error(), "invalid profiles.ro configuration", "All the errors should be reported")
+}
+
+func TestConfigPrintCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+toolsets = ["core", "config"]
+sts_client_secret = "s3cr3t"
+
+[profiles.ro]
+read_only = true
+namespaces = ["dev"]
+`), 0600))
+	t.Setenv("EXTENDABLE_K8S_MCP_LIST_OUTPUT", "yaml")
+
+	out, err := runCommand(t, "config", "print", "--config", path, "--profile", "ro", "--port", "8080")
+	require.NoError(t, err)
+	assert.Contains(t, out, `toolsets = ["core", "config"] # file `+path)
+	assert.Contains(t, out, `read_only = true # profile ro`)
+	assert.Contains(t, out, `namespaces = ["dev"] # profile ro`)
+	assert.Contains(t, out, `list_output = "yaml" # env EXTENDABLE_K8S_MCP_LIST_OUTPUT`)
+	assert.Contains(t, out, `port = "8080" # flag --port`)
+	assert.Contains(t, out, `disable_destructive = false # default`)
+	assert.Contains(t, out, `sts_client_secret = "<redacted>"`, "Secrets should be redacted")
+	assert.NotContains(t, out, "s3cr3t", "Secrets should be redacted")
+}
@@ -0,0 +1,120 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the doctor diagnostics subcommand.
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// fakeAPIServer serves the version and the SelfSubjectAccessReviews over TLS (client-go only sends the
+// kubeconfig credentials over TLS), denying the reviews matching denied. It returns the path of its kubeconfig.
+func fakeAPIServer(t *testing.T, token string, denied func(*authorizationv1.ResourceAttributes) bool) string {
+	t.Helper()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/version":
+			_, _ = w.Write([]byte(`{"major":"1","minor":"34","gitVersion":"v1.34.0"}`))
+		case r.Header.Get("Authorization") != "Bearer "+token:
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Unauthorized","code":401}`))
+		case r.URL.Path == "/apis/authorization.k8s.io/v1/selfsubjectaccessreviews":
+			// client-go sends the reviews as protobuf
+			body, _ := io.ReadAll(r.Body)
+			review := &authorizationv1.SelfSubjectAccessReview{}
+			if _, _, err := scheme.Codecs.UniversalDeserializer().Decode(body, nil, review); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			review.Status.Allowed = !denied(review.Spec.ResourceAttributes)
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(review)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: %s
+    insecure-skip-tls-verify: true
+  name: fake
+contexts:
+- context:
+    cluster: fake
+    user: fake
+  name: fake
+current-context: fake
+users:
+- name: fake
+  user:
+    token: valid
+`, server.URL)
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(kubeconfig), 0600))
+	return kubeconfigPath
+}
+
+func TestDoctorCommand(t *testing.T) {
+	var reviews []string
+	kubeconfigPath := fakeAPIServer(t, "valid", func(attributes *authorizationv1.ResourceAttributes) bool {
+		reviews = append(reviews, attributes.Verb+" "+attributes.Resource+"/"+attributes.Subresource+" "+attributes.Namespace)
+		return attributes.Subresource == "proxy"
+	})
+
+	out, err := runCommand(t, "doctor", "--kubeconfig", kubeconfigPath, "--toolsets", "core,config")
+	require.NoError(t, err, "Denied permissions should only be warnings: %s", out)
+	assert.Contains(t, out, "[OK]   configuration is valid")
+	assert.Contains(t, out, `1 contexts, default "fake"`)
+	assert.Contains(t, out, "Cluster context fake\n  [OK]   connectivity: Kubernetes v1.34.0")
+	assert.Contains(t, out, "[OK]   authentication: the credentials were accepted")
+	assert.Contains(t, out, "[WARN] RBAC core: get nodes/proxy is denied, required by nodes_log, nodes_stats_summary")
+	assert.Contains(t, out, "0 failed, 1 warnings")
+	assert.Contains(t, reviews, "delete pods/ ", "The permissions of the enabled tools should be checked")
+
+	reviews = nil
+	out, err = runCommand(t, "doctor", "--kubeconfig", kubeconfigPath, "--toolsets", "core", "--read-only", "--config", writeConfig(t, `namespaces = ["dev"]`))
+	require.NoError(t, err)
+	assert.NotContains(t, reviews, "delete pods/ dev", "The permissions of the disabled tools should not be checked")
+	assert.Contains(t, reviews, "get pods/log dev", "The permissions should be checked in the configured namespaces")
+	assert.Contains(t, out, "[WARN] RBAC core: get nodes/proxy is denied in namespace dev")
+}
+
+func TestDoctorCommandFailures(t *testing.T) {
+	kubeconfigPath := fakeAPIServer(t, "other", func(*authorizationv1.ResourceAttributes) bool { return false })
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = listener.Close() }()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	out, err := runCommand(t, "doctor", "--kubeconfig", kubeconfigPath, "--toolsets", "core", "--port", port, "--list-output", "xml")
+	assert.ErrorContains(t, err, "3 checks failed")
+	assert.Contains(t, out, "[FAIL] invalid output name: xml")
+	assert.Contains(t, out, "[FAIL] authentication: the credentials were rejected")
+	assert.Contains(t, out, "[FAIL] port "+port+" is not available")
+	assert.False(t, strings.Contains(out, "RBAC"), "Permissions should not be checked if the authentication fails")
+}
+
+// writeConfig writes the config file, returning its path.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
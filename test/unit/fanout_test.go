@@ -0,0 +1,218 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the read-only tool calls fanned out to the clusters matching a cluster selector.
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	k8sapi "github.com/containers/kubernetes-mcp-server/pkg/api"
+	staticconfig "github.com/containers/kubernetes-mcp-server/pkg/config"
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientcmdapiv1 "k8s.io/client-go/tools/clientcmd/api/v1"
+	"k8s.io/utils/ptr"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	localmcp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/mcp"
+)
+
+// clusterCalls tracks the concurrent calls of test_cluster_server.
+var clusterCalls struct {
+	active, peak atomic.Int32
+}
+
+// clusterTools are the tools of the test-clusters toolset, whose cluster aware tools report the API server of
+// their cluster.
+func clusterTools() []k8sapi.ServerTool {
+	return []k8sapi.ServerTool{{
+		Tool: k8sapi.Tool{
+			Name:        "test_cluster_server",
+			Description: "Returns the API server of the cluster, fails for the broken servers and hangs for the slow ones",
+			InputSchema: &jsonschema.Schema{Type: "object"},
+			Annotations: k8sapi.ToolAnnotations{ReadOnlyHint: ptr.To(true)},
+		},
+		Handler: func(params k8sapi.ToolHandlerParams) (*k8sapi.ToolCallResult, error) {
+			active := clusterCalls.active.Add(1)
+			defer clusterCalls.active.Add(-1)
+			for peak := clusterCalls.peak.Load(); active > peak && !clusterCalls.peak.CompareAndSwap(peak, active); {
+				peak = clusterCalls.peak.Load()
+			}
+			view, err := params.ConfigurationView(true)
+			if err != nil {
+				return nil, err
+			}
+			server := view.(*clientcmdapiv1.Config).Clusters[0].Cluster.Server
+			switch {
+			case strings.Contains(server, "broken"):
+				return k8sapi.NewToolCallResult("", errors.New("connection refused")), nil
+			case strings.Contains(server, "slow"):
+				<-params.Done()
+				time.Sleep(50 * time.Millisecond)
+				return k8sapi.NewToolCallResult("", params.Err()), nil
+			}
+			time.Sleep(10 * time.Millisecond)
+			return k8sapi.NewToolCallResult(server, nil), nil
+		},
+	}, {
+		Tool: k8sapi.Tool{
+			Name:        "test_cluster_write",
+			Description: "Writes to the cluster",
+			InputSchema: &jsonschema.Schema{Type: "object"},
+		},
+		Handler: func(k8sapi.ToolHandlerParams) (*k8sapi.ToolCallResult, error) {
+			return k8sapi.NewToolCallResult("written", nil), nil
+		},
+	}}
+}
+
+const fanOutRegistry = `
+toolsets = ["test-clusters"]
+cluster_provider_strategy = "registry"
+[[cluster_provider_configs.registry.clusters]]
+name = "prod-eu-1"
+server = "https://prod-eu-1.example.com:6443"
+labels = { env = "prod", region = "eu" }
+[[cluster_provider_configs.registry.clusters]]
+name = "prod-eu-2"
+server = "https://slow.example.com:6443"
+labels = { env = "prod", region = "eu" }
+[[cluster_provider_configs.registry.clusters]]
+name = "prod-us-1"
+server = "https://broken.example.com:6443"
+labels = { env = "prod", region = "us" }
+[[cluster_provider_configs.registry.clusters]]
+name = "dev-1"
+server = "https://dev-1.example.com:6443"
+labels = { env = "dev" }
+`
+
+// connectFanOut connects a client to a server of the fan out cluster registry with the extension configuration.
+func connectFanOut(t *testing.T, extensions string) *mcp.ClientSession {
+	staticConfig, err := staticconfig.ReadToml([]byte(fanOutRegistry))
+	require.NoError(t, err)
+	extensionsConfig, err := config.ReadToml([]byte(extensions))
+	require.NoError(t, err)
+	require.NoError(t, extensionsConfig.Validate())
+	server, err := localmcp.NewServer(localmcp.Configuration{StaticConfig: staticConfig, Extensions: extensionsConfig})
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+	session, err := connectInMemory(t, server)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = session.Close() })
+	return session
+}
+
+func callFanOut(t *testing.T, session *mcp.ClientSession, arguments map[string]any) (string, bool) {
+	result, err := session.CallTool(context.Background(), &mcp.CallToolParams{Name: "test_cluster_server", Arguments: arguments})
+	require.NoError(t, err)
+	require.Len(t, result.Content, 1)
+	return result.Content[0].(*mcp.TextContent).Text, result.IsError
+}
+
+func TestFanOutConfigValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		fanOut  string
+		wantErr string
+	}{
+		{name: "defaults"},
+		{name: "valid", fanOut: "max_concurrency = 4\nmax_clusters = 20\ncluster_timeout = \"0s\""},
+		{name: "invalid max_concurrency", fanOut: "max_concurrency = 0", wantErr: "fan_out max_concurrency must be positive"},
+		{name: "invalid max_clusters", fanOut: "max_clusters = -1", wantErr: "fan_out max_clusters must not be negative"},
+		{name: "invalid cluster_timeout", fanOut: "cluster_timeout = \"soon\"", wantErr: "invalid fan_out cluster_timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.ReadToml([]byte("[fan_out]\n" + tt.fanOut))
+			require.NoError(t, err)
+			err = cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClusterSelectorParameter(t *testing.T) {
+	session := connectFanOut(t, "")
+	tools, err := session.ListTools(context.Background(), nil)
+	require.NoError(t, err)
+	schemas := map[string]string{}
+	for _, tool := range tools.Tools {
+		schema, err := json.Marshal(tool.InputSchema)
+		require.NoError(t, err)
+		schemas[tool.Name] = string(schema)
+	}
+	assert.Contains(t, schemas["test_cluster_server"], `"cluster_selector"`, "The read-only tools should take a cluster selector")
+	assert.Contains(t, schemas["test_cluster_server"], "The cluster labels are: env=dev|prod, region=eu|us",
+		"The cluster selector should describe the labels of the clusters")
+	assert.NotContains(t, schemas["test_cluster_write"], `"cluster_selector"`, "The other tools should not take a cluster selector")
+
+	result, err := session.CallTool(context.Background(), &mcp.CallToolParams{
+		Name: "test_cluster_write", Arguments: map[string]any{"cluster_selector": "env=prod"},
+	})
+	require.NoError(t, err)
+	assert.True(t, result.IsError, "The other tools should reject a cluster selector")
+}
+
+func TestClusterSelectorFanOut(t *testing.T) {
+	session := connectFanOut(t, "[fan_out]\ncluster_timeout = \"300ms\"")
+
+	text, isError := callFanOut(t, session, map[string]any{"cluster_selector": "env=prod"})
+	assert.False(t, isError, "The call should succeed if a cluster succeeded")
+	assert.Contains(t, text, `cluster_selector "env=prod" matched 3 clusters: 1 succeeded, 2 failed`)
+	assert.Contains(t, text, "--- cluster: prod-eu-1 ---\nhttps://prod-eu-1.example.com:6443\n")
+	assert.Contains(t, text, "--- cluster: prod-eu-2 (failed) ---\nno answer within 300ms\n", "The slow clusters should time out")
+	assert.Zero(t, clusterCalls.active.Load(), "The calls of the clusters timing out should be waited for")
+	assert.Contains(t, text, "--- cluster: prod-us-1 (failed) ---\nconnection refused\n")
+	assert.NotContains(t, text, "dev-1")
+
+	text, isError = callFanOut(t, session, map[string]any{"cluster_selector": "env=prod,region=us"})
+	assert.True(t, isError, "The call should fail if all the clusters failed")
+	assert.Contains(t, text, "--- cluster: prod-us-1 (failed) ---")
+
+	text, isError = callFanOut(t, session, map[string]any{"cluster": "dev-1"})
+	assert.False(t, isError)
+	assert.Equal(t, "https://dev-1.example.com:6443", text, "The calls without a selector should target a single cluster")
+
+	tests := []struct {
+		name      string
+		arguments map[string]any
+		wantErr   string
+	}{
+		{name: "with cluster", arguments: map[string]any{"cluster": "dev-1", "cluster_selector": "env=dev"}, wantErr: "cluster and cluster_selector are mutually exclusive"},
+		{name: "invalid selector", arguments: map[string]any{"cluster_selector": "env in (prod"}, wantErr: "invalid cluster_selector"},
+		{name: "no match", arguments: map[string]any{"cluster_selector": "env=staging"}, wantErr: `no cluster matches the cluster_selector "env=staging"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, isError := callFanOut(t, session, tt.arguments)
+			assert.True(t, isError)
+			assert.Contains(t, text, tt.wantErr)
+		})
+	}
+}
+
+func TestClusterSelectorFanOutLimits(t *testing.T) {
+	session := connectFanOut(t, "[fan_out]\nmax_concurrency = 1\nmax_clusters = 2")
+
+	clusterCalls.peak.Store(0)
+	text, isError := callFanOut(t, session, map[string]any{"cluster_selector": "region!=eu,!missing"})
+	require.False(t, isError, text)
+	assert.Contains(t, text, "matched 2 clusters: 1 succeeded, 1 failed")
+	assert.Equal(t, int32(1), clusterCalls.peak.Load(), "The clusters should be called one at a time")
+
+	text, isError = callFanOut(t, session, map[string]any{"cluster_selector": "env=prod"})
+	assert.True(t, isError)
+	assert.Contains(t, text, `the cluster_selector "env=prod" matches 3 clusters, more than the 2 allowed`)
+}
@@ -0,0 +1,178 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the liveness and readiness endpoints.
+package unit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	staticconfig "github.com/containers/kubernetes-mcp-server/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	localmcp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/mcp"
+)
+
+// probe returns the status code and the body of the health endpoint, requested with the authorization if set.
+func probe(t *testing.T, client *http.Client, path string, authorization ...string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, "http://localhost"+path, nil)
+	require.NoError(t, err)
+	for _, value := range authorization {
+		req.Header.Set("Authorization", value)
+	}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestServeHealthEndpoints(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(*staticconfig.StaticConfig, *config.Config)
+	}{
+		{name: "api keys", configure: func(_ *staticconfig.StaticConfig, extensions *config.Config) {
+			extensions.Authentication.APIKeys = []config.APIKeyConfig{{Name: "ci", Hash: apiKeyHash("secret")}}
+		}},
+		{name: "oauth", configure: func(staticConfig *staticconfig.StaticConfig, _ *config.Config) {
+			staticConfig.RequireOAuth = true
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			socket := filepath.Join(t.TempDir(), "mcp.sock")
+			staticConfig := staticconfig.Default()
+			staticConfig.KubeConfig = fakeAPIServer(t, "valid", func(*authorizationv1.ResourceAttributes) bool { return false })
+			extensions := config.Default()
+			extensions.Listen = config.ListenConfig{Address: "unix://" + socket, SocketMode: "0600"}
+			staticConfig.Toolsets = []string{"test-greeting"}
+			tt.configure(staticConfig, extensions)
+			stop := serveUnixStatic(t, staticConfig, extensions)
+			defer func() { _ = stop() }()
+			client := unixHTTPClient(socket)
+
+			status, _ := probe(t, client, "/mcp")
+			assert.Equal(t, http.StatusUnauthorized, status, "The MCP endpoint should require authentication")
+			status, body := probe(t, client, "/livez")
+			assert.Equal(t, http.StatusOK, status, "The probes should not require authentication")
+			assert.Equal(t, "ok", body)
+			status, body = probe(t, client, "/readyz")
+			assert.Equal(t, http.StatusOK, status)
+			assert.Equal(t, "ok", body)
+			status, body = probe(t, client, "/readyz?verbose")
+			assert.Equal(t, http.StatusOK, status)
+			assert.Equal(t, "ok", body, "The details should not be listed to the unauthenticated clients")
+			if len(extensions.Authentication.APIKeys) > 0 {
+				status, body = probe(t, client, "/readyz?verbose", "Bearer secret")
+				assert.Equal(t, http.StatusOK, status)
+				assert.Equal(t, "[+]ping ok\n[+]kubeconfig ok\n[+]cluster ok\nreadyz check passed\n", body)
+			}
+		})
+	}
+}
+
+func TestServeReadinessCache(t *testing.T) {
+	var versions atomic.Int32
+	apiServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/version" {
+			versions.Add(1)
+		}
+		_, _ = w.Write([]byte(`{"major":"1","minor":"34","gitVersion":"v1.34.0"}`))
+	}))
+	defer apiServer.Close()
+	kubeconfig := strings.ReplaceAll(impersonationKubeconfig, "https://cluster-1.example.com:6443",
+		apiServer.URL+"\n    insecure-skip-tls-verify: true")
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(kubeconfig), 0600))
+	socket := filepath.Join(t.TempDir(), "mcp.sock")
+	staticConfig := staticconfig.Default()
+	staticConfig.KubeConfig = kubeconfigPath
+	staticConfig.Toolsets = []string{"test-greeting"}
+	extensions := config.Default()
+	extensions.Listen = config.ListenConfig{Address: "unix://" + socket, SocketMode: "0600"}
+	stop := serveUnixStatic(t, staticConfig, extensions)
+	defer func() { _ = stop() }()
+	client := unixHTTPClient(socket)
+
+	status, _ := probe(t, client, "/readyz")
+	require.Equal(t, http.StatusOK, status)
+	checked := versions.Load()
+	require.NotZero(t, checked)
+	for range 3 {
+		status, _ = probe(t, client, "/readyz")
+		assert.Equal(t, http.StatusOK, status)
+	}
+	assert.Equal(t, checked, versions.Load(), "The results of the checks should be reused by the next probes")
+}
+
+func TestServeReadinessFailures(t *testing.T) {
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+	kubeconfig := strings.ReplaceAll(impersonationKubeconfig, "https://cluster-1.example.com:6443", unreachable.URL)
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(kubeconfig), 0600))
+	socket := filepath.Join(t.TempDir(), "mcp.sock")
+	staticConfig := staticconfig.Default()
+	staticConfig.KubeConfig = kubeconfigPath
+	// the OIDC provider isn't discovered by Serve, which is provided a nil provider
+	staticConfig.AuthorizationURL = "https://issuer.example.com"
+	staticConfig.Toolsets = []string{"test-greeting"}
+	extensions := config.Default()
+	extensions.Listen = config.ListenConfig{Address: "unix://" + socket, SocketMode: "0600"}
+	stop := serveUnixStatic(t, staticConfig, extensions)
+	defer func() { _ = stop() }()
+	client := unixHTTPClient(socket)
+
+	status, body := probe(t, client, "/livez")
+	assert.Equal(t, http.StatusOK, status, "The liveness should not depend on the cluster")
+	assert.Equal(t, "ok", body)
+
+	status, body = probe(t, client, "/readyz")
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Equal(t, "readyz check failed\n", body, "The checks should only be listed if verbose")
+
+	_, body = probe(t, client, "/readyz?verbose")
+	assert.Contains(t, body, "[+]kubeconfig ok\n")
+	assert.Contains(t, body, "[-]cluster failed: the default cluster is unreachable")
+	assert.Contains(t, body, "[-]oidc failed: the OIDC provider of https://issuer.example.com wasn't discovered")
+
+	status, body = probe(t, client, "/readyz?exclude=cluster&exclude=oidc")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ok", body)
+	_, body = probe(t, client, "/readyz?verbose&exclude=cluster")
+	assert.Contains(t, body, "[+]cluster excluded: ok\n")
+}
+
+func TestServerCheckKubeconfig(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(impersonationKubeconfig), 0600))
+	staticConfig := staticconfig.Default()
+	staticConfig.KubeConfig = kubeconfigPath
+	staticConfig.Toolsets = []string{"test-greeting"}
+	server, err := localmcp.NewServer(localmcp.Configuration{StaticConfig: staticConfig})
+	require.NoError(t, err)
+	defer server.Close()
+	assert.NoError(t, server.CheckKubeconfig(context.Background()))
+
+	broken := *staticConfig
+	broken.KubeConfig = filepath.Join(t.TempDir(), "missing")
+	require.Error(t, server.Reload(localmcp.Configuration{StaticConfig: &broken}))
+	assert.ErrorContains(t, server.CheckKubeconfig(context.Background()), "failed to load the kubeconfig",
+		"A failed kubeconfig reload should be reported even though the previous provider is kept")
+
+	require.NoError(t, server.Reload(localmcp.Configuration{StaticConfig: staticConfig}))
+	assert.NoError(t, server.CheckKubeconfig(context.Background()))
+}
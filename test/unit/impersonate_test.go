@@ -0,0 +1,182 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the impersonation of the authenticated MCP user.
+package unit
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/containers/kubernetes-mcp-server/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientcmdapiv1 "k8s.io/client-go/tools/clientcmd/api/v1"
+
+	localconfig "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/impersonate"
+)
+
+const impersonationKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: cluster-1
+  cluster:
+    server: https://cluster-1.example.com:6443
+- name: cluster-2
+  cluster:
+    server: https://cluster-2.example.com:6443
+contexts:
+- name: ctx-1
+  context:
+    cluster: cluster-1
+    user: admin
+- name: ctx-2
+  context:
+    cluster: cluster-2
+current-context: ctx-1
+users:
+- name: admin
+  user:
+    token: server-token
+`
+
+// newTestJWT creates an unsigned JWT with the provided claims, the signature isn't verified when mapping claims.
+func newTestJWT(t *testing.T, claims map[string]any) string {
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	return header + "." + base64.RawURLEncoding.EncodeToString(payload) + ".signature"
+}
+
+func TestIdentityFromTokenDefaultClaims(t *testing.T) {
+	cfg := localconfig.Default().Impersonation
+	token := newTestJWT(t, map[string]any{"sub": "alice", "groups": []string{"dev", "ops"}})
+
+	identity, err := impersonate.IdentityFromToken(cfg, token)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", identity.User, "User should be mapped from the sub claim")
+	assert.Equal(t, []string{"dev", "ops"}, identity.Groups, "Groups should be mapped from the groups claim")
+}
+
+func TestIdentityFromTokenClaimMapping(t *testing.T) {
+	cfg := localconfig.ImpersonationConfig{
+		UserClaim:    "email",
+		UserPrefix:   "oidc:",
+		GroupsClaim:  "realm_access.roles",
+		GroupsPrefix: "oidc:",
+		ExtraGroups:  []string{"mcp-users"},
+	}
+	token := newTestJWT(t, map[string]any{
+		"sub":          "1234",
+		"email":        "alice@example.com",
+		"realm_access": map[string]any{"roles": []string{"admin"}},
+	})
+
+	identity, err := impersonate.IdentityFromToken(cfg, token)
+	require.NoError(t, err)
+	assert.Equal(t, "oidc:alice@example.com", identity.User, "User should be mapped from the configured claim with prefix")
+	assert.Equal(t, []string{"oidc:admin", "mcp-users"}, identity.Groups, "Nested groups claim and extra groups should be mapped")
+}
+
+func TestIdentityFromTokenErrors(t *testing.T) {
+	cfg := localconfig.Default().Impersonation
+
+	_, err := impersonate.IdentityFromToken(cfg, "opaque-token")
+	assert.Error(t, err, "Non JWT tokens should be rejected")
+
+	_, err = impersonate.IdentityFromToken(cfg, newTestJWT(t, map[string]any{"email": "alice@example.com"}))
+	assert.Error(t, err, "Tokens without the user claim should be rejected")
+}
+
+func TestImpersonatingClients(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(impersonationKubeconfig), 0600))
+	staticConfig := config.Default()
+	staticConfig.KubeConfig = kubeconfigPath
+	staticConfig.RequireOAuth = true
+	clients := impersonate.NewClients(staticConfig)
+	defer clients.Reset()
+
+	identity := &impersonate.Identity{User: "alice", Groups: []string{"dev"}}
+	for _, target := range []string{"", "ctx-2"} {
+		k, err := clients.Kubernetes(target, identity)
+		require.NoError(t, err, "Impersonating client should be created for target %q", target)
+
+		view, err := k.ConfigurationView(true)
+		require.NoError(t, err)
+		kubeconfig, ok := view.(*clientcmdapiv1.Config)
+		require.True(t, ok, "ConfigurationView should return a kubeconfig")
+		require.Len(t, kubeconfig.AuthInfos, 1)
+		assert.Equal(t, "alice", kubeconfig.AuthInfos[0].AuthInfo.Impersonate, "User should be impersonated for target %q", target)
+		assert.Equal(t, []string{"dev"}, kubeconfig.AuthInfos[0].AuthInfo.ImpersonateGroups, "Groups should be impersonated for target %q", target)
+	}
+
+	_, err := clients.Kubernetes("unknown", identity)
+	assert.Error(t, err, "Unknown targets should be rejected")
+}
+
+func TestImpersonatingClientsRequests(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	apiServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"kind":"PodList","apiVersion":"v1","items":[]}`))
+	}))
+	defer apiServer.Close()
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	kubeconfig := strings.ReplaceAll(impersonationKubeconfig, "https://cluster-1.example.com:6443",
+		apiServer.URL+"\n    insecure-skip-tls-verify: true")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(kubeconfig), 0600))
+	staticConfig := config.Default()
+	staticConfig.KubeConfig = kubeconfigPath
+	clients := impersonate.NewClients(staticConfig)
+	defer clients.Reset()
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	k, err := clients.Kubernetes("", &impersonate.Identity{User: "alice", Groups: []string{"dev", "ops"}})
+	require.NoError(t, err)
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "The kubeconfig with the server credentials should be removed once loaded")
+	pods, err := k.AccessControlClientset().Pods("default")
+	require.NoError(t, err)
+	_, err = pods.List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	header := <-headers
+	assert.Equal(t, "Bearer server-token", header.Get("Authorization"), "The server credentials should be used")
+	assert.Equal(t, "alice", header.Get("Impersonate-User"), "The user should be impersonated")
+	assert.Equal(t, []string{"dev", "ops"}, header.Values("Impersonate-Group"), "The groups should be impersonated")
+}
+
+func TestImpersonationRequiresVerifiedTokens(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(configPath, []byte("[impersonation]\nenabled = true\n"), 0600))
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "unverified tokens", wantErr: "impersonation of the OAuth users requires authorization-url or validate-token"},
+		{name: "token review", args: []string{"--validate-token"}},
+		{name: "authorization server", args: []string{"--authorization-url", "https://auth.example.com/realms/mcp"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"config", "validate", "--config", configPath, "--port", "8080", "--require-oauth"}, tt.args...)
+			_, err := runCommand(t, args...)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the interactive inspect subcommand.
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	staticconfig "github.com/containers/kubernetes-mcp-server/pkg/config"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	localmcp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/mcp"
+)
+
+func TestInspectCommand(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(impersonationKubeconfig), 0600))
+	input := strings.Join([]string{
+		"tools",
+		"call test_echo",
+		"",     // count (integer) is skipped
+		"yes",  // loud (boolean) is invalid
+		"true", // loud
+		"",     // tags (array) is skipped
+		"a b",  // text
+		"raw on",
+		`call test_echo {"count":2}`,
+		"raw off",
+		"prompts",
+		"unknown",
+		"exit",
+	}, "\n")
+
+	out, err := runCommandWithInput(t, input, "inspect", "--kubeconfig", kubeconfigPath, "--toolsets", "test-echo")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Connected to kubernetes-mcp-server")
+	assert.Regexp(t, `(?m)^test_echo\s+read-only\s+count,loud,tags,text$`, out, "The tools should be listed")
+	assert.Contains(t, out, "loud (boolean): Invalid value", "Invalid argument values should be prompted again")
+	assert.Contains(t, out, `{"loud":true,"text":"a b"}`, "The prompted arguments should be converted to their types")
+	assert.Regexp(t, `--> \{"jsonrpc":"2.0","id":\d+,"method":"tools/call","params":\{"name":"test_echo","arguments":\{"count":2\}\}\}`, out,
+		"The raw JSON-RPC requests should be displayed")
+	assert.Regexp(t, `<-- \{"jsonrpc":"2.0","id":\d+,"result":`, out, "The raw JSON-RPC responses should be displayed")
+	assert.Equal(t, 2, strings.Count(out, "--> {")+strings.Count(out, "<-- {"), "The raw view should be disabled")
+	assert.Contains(t, out, "The server has no prompts")
+	assert.Contains(t, out, `unknown command "unknown"`)
+}
+
+func TestInspectCommandRemote(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(impersonationKubeconfig), 0600))
+	staticConfig := staticconfig.Default()
+	staticConfig.KubeConfig = kubeconfigPath
+	staticConfig.Toolsets = []string{"test-greeting"}
+	server, err := localmcp.NewServer(localmcp.Configuration{StaticConfig: staticConfig})
+	require.NoError(t, err)
+	defer server.Close()
+	handler := mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return server.McpServer() }, nil)
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer httpServer.Close()
+
+	out, err := runCommandWithInput(t, "call test_greet\nexit\n", "inspect", "--url", httpServer.URL, "--header", "Authorization: Bearer token")
+	require.NoError(t, err)
+	assert.Contains(t, out, "hello", "Tools of remote servers should be called")
+
+	_, err = runCommandWithInput(t, "exit\n", "inspect", "--url", httpServer.URL)
+	assert.ErrorContains(t, err, "failed to connect to the MCP server")
+}
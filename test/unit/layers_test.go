@@ -0,0 +1,91 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the layered configuration from files, drop-in directories and environment variables.
+package unit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	staticconfig "github.com/containers/kubernetes-mcp-server/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/cmd"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+)
+
+func TestMergeConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.toml")
+	overlay := filepath.Join(dir, "overlay.toml")
+	require.NoError(t, os.WriteFile(base, []byte(`
+read_only = false
+toolsets = ["core", "config", "helm"]
+list_output = "table"
+
+[rate_limit]
+enabled = true
+requests_per_second = 5
+burst = 10
+`), 0600))
+	require.NoError(t, os.WriteFile(overlay, []byte(`
+read_only = true
+toolsets = ["core"]
+
+[rate_limit]
+burst = 20
+`), 0600))
+
+	data, err := config.Merge(base, overlay)
+	require.NoError(t, err)
+	staticConfig, err := staticconfig.ReadToml(data)
+	require.NoError(t, err)
+	extensions, err := config.ReadToml(data)
+	require.NoError(t, err)
+
+	assert.True(t, staticConfig.ReadOnly, "Later files should take precedence")
+	assert.Equal(t, []string{"core"}, staticConfig.Toolsets, "Arrays should be replaced, not appended")
+	assert.Equal(t, "table", staticConfig.ListOutput, "Values only set in earlier files should be kept")
+	assert.True(t, extensions.RateLimit.Enabled, "Tables should be merged recursively")
+	assert.Equal(t, 5.0, extensions.RateLimit.RequestsPerSecond, "Tables should be merged recursively")
+	assert.Equal(t, 20, extensions.RateLimit.Burst, "Later files should take precedence within tables")
+}
+
+func TestMergeConfigFilesInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.toml")
+	require.NoError(t, os.WriteFile(path, []byte("read_only = "), 0600))
+	_, err := config.Merge(path)
+	assert.ErrorContains(t, err, path, "Errors should name the invalid file")
+}
+
+func TestDropInFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"20-limits.toml", "10-base.toml", "README.md", ".hidden.toml"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(""), 0600))
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "nested.toml"), 0700))
+
+	files, err := config.DropInFiles(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "10-base.toml"), filepath.Join(dir, "20-limits.toml")}, files,
+		"Only toml files should be returned, in lexical order")
+
+	files, err = config.DropInFiles(filepath.Join(dir, "missing"))
+	require.NoError(t, err, "Missing drop-in directories should be ignored")
+	assert.Empty(t, files)
+}
+
+func TestEnvironmentBinding(t *testing.T) {
+	t.Setenv("EXTENDABLE_K8S_MCP_LOG_LEVEL", "not-a-number")
+	var output bytes.Buffer
+	rootCmd := cmd.NewExtendableMCPServer(genericiooptions.IOStreams{In: os.Stdin, Out: &output, ErrOut: &output})
+	rootCmd.SetOut(&output)
+	rootCmd.SetErr(&output)
+	rootCmd.SetArgs([]string{})
+
+	err := rootCmd.Execute()
+	assert.ErrorContains(t, err, "EXTENDABLE_K8S_MCP_LOG_LEVEL", "Environment variables should be bound to the flags")
+}
@@ -0,0 +1,166 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the HTTP server listening on a Unix domain socket.
+package unit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	staticconfig "github.com/containers/kubernetes-mcp-server/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	localhttp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/http"
+	localmcp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/mcp"
+)
+
+func TestListenConfigValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		listen  config.ListenConfig
+		wantErr string
+	}{
+		{name: "disabled", listen: config.ListenConfig{SocketMode: "0660"}},
+		{name: "unix socket", listen: config.ListenConfig{Address: "unix:///run/mcp/mcp.sock", SocketMode: "0600"}},
+		{name: "tcp address", listen: config.ListenConfig{Address: "tcp://localhost:8080", SocketMode: "0660"}, wantErr: "invalid listen address"},
+		{name: "missing path", listen: config.ListenConfig{Address: "unix://", SocketMode: "0660"}, wantErr: "invalid listen address"},
+		{name: "non octal mode", listen: config.ListenConfig{Address: "unix:///mcp.sock", SocketMode: "rw"}, wantErr: "invalid listen socket_mode"},
+		{name: "mode out of range", listen: config.ListenConfig{Address: "unix:///mcp.sock", SocketMode: "1777"}, wantErr: "invalid listen socket_mode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Listen = tt.listen
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+
+	_, err := runCommand(t, "config", "validate", "--port", "8080", "--listen", "unix:///run/mcp/mcp.sock")
+	assert.ErrorContains(t, err, "port and listen are mutually exclusive")
+}
+
+// serveUnix serves the test-greeting toolset, and the provided ones, on the Unix domain socket of the extensions
+// configuration until the returned function is called, which returns the Serve error.
+func serveUnix(t *testing.T, extensions *config.Config, toolsets ...string) func() error {
+	t.Helper()
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(impersonationKubeconfig), 0600))
+	staticConfig := staticconfig.Default()
+	staticConfig.KubeConfig = kubeconfigPath
+	staticConfig.Toolsets = append([]string{"test-greeting"}, toolsets...)
+	return serveUnixStatic(t, staticConfig, extensions)
+}
+
+// serveUnixStatic is serveUnix with the provided static configuration.
+func serveUnixStatic(t *testing.T, staticConfig *staticconfig.StaticConfig, extensions *config.Config) func() error {
+	t.Helper()
+	server, err := localmcp.NewServer(localmcp.Configuration{StaticConfig: staticConfig, Extensions: extensions})
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+	return serveUnixServer(t, server, staticConfig, extensions)
+}
+
+// serveUnixServer is serveUnixStatic with the provided server.
+func serveUnixServer(t *testing.T, server *localmcp.Server, staticConfig *staticconfig.StaticConfig, extensions *config.Config) func() error {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- localhttp.Serve(ctx, server, staticConfig, extensions, nil, nil) }()
+	stop := func() error {
+		cancel()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(10 * time.Second):
+			return context.DeadlineExceeded
+		}
+	}
+	require.Eventually(t, func() bool {
+		select {
+		case err := <-done:
+			done <- err
+			return true
+		default:
+		}
+		conn, err := net.Dial("unix", extensions.Listen.SocketPath())
+		if err == nil {
+			_ = conn.Close()
+		}
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond, "The server should listen on the socket")
+	return stop
+}
+
+// unixHTTPClient returns an HTTP client connecting to the Unix domain socket whatever the request host.
+func unixHTTPClient(socket string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+}
+
+func TestServeUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "mcp.sock")
+	listen := config.ListenConfig{Address: "unix://" + socket, SocketMode: "0600"}
+	extensions := config.Default()
+	extensions.Listen = listen
+	stop := serveUnix(t, extensions)
+
+	info, err := os.Stat(socket)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "The socket should have the configured mode")
+	entries, err := os.ReadDir(filepath.Dir(socket))
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "The private directory the socket is created in should be removed")
+	assert.Equal(t, "mcp.sock", entries[0].Name())
+
+	resp, err := unixHTTPClient(socket).Get("http://localhost/healthz")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	out, err := runCommandWithInput(t, "call test_greet\nexit\n", "inspect", "--listen", listen.Address)
+	require.NoError(t, err)
+	assert.Contains(t, out, "hello", "The inspector should connect through the socket")
+
+	secondServer := config.Default()
+	secondServer.Listen = listen
+	err = localhttp.Serve(context.Background(), nil, staticconfig.Default(), secondServer, nil, nil)
+	assert.ErrorContains(t, err, "the socket is in use by another server")
+
+	require.NoError(t, stop())
+	_, err = os.Stat(socket)
+	assert.ErrorIs(t, err, os.ErrNotExist, "The socket should be removed on shutdown")
+}
+
+func TestServeUnixSocketStale(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "mcp.sock")
+	stale, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	extensions := config.Default()
+	extensions.Listen = config.ListenConfig{Address: "unix://" + socket, SocketMode: "0660"}
+	stop := serveUnix(t, extensions)
+	info, err := os.Stat(socket)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0660), info.Mode().Perm())
+	require.NoError(t, stop())
+
+	require.NoError(t, os.WriteFile(socket, []byte("not a socket"), 0600))
+	err = localhttp.Serve(context.Background(), nil, staticconfig.Default(), extensions, nil, nil)
+	assert.ErrorContains(t, err, "the file exists and is not a socket", "Regular files should not be replaced")
+}
@@ -0,0 +1,205 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the additional HTTP listeners.
+package unit
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	staticconfig "github.com/containers/kubernetes-mcp-server/pkg/config"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	localhttp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/http"
+	localmcp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/mcp"
+)
+
+func TestListenersConfigValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		listeners string
+		wantErr   string
+	}{
+		{name: "tcp and socket", listeners: `
+[[listeners]]
+name = "admin"
+address = "127.0.0.1:8081"
+auth = "none"
+[[listeners]]
+name = "local"
+address = "unix:///run/mcp/admin.sock"
+profile = "ro"`},
+		{name: "missing name", listeners: "[[listeners]]\naddress = \":8081\"", wantErr: "name is required"},
+		{name: "duplicate name", listeners: "[[listeners]]\nname = \"a\"\naddress = \":8081\"\n[[listeners]]\nname = \"a\"\naddress = \":8082\"", wantErr: "name is already used"},
+		{name: "missing address", listeners: "[[listeners]]\nname = \"a\"", wantErr: "address is required"},
+		{name: "invalid address", listeners: "[[listeners]]\nname = \"a\"\naddress = \"8081\"", wantErr: "expected host:port"},
+		{name: "duplicate address", listeners: "[[listeners]]\nname = \"a\"\naddress = \":8081\"\n[[listeners]]\nname = \"b\"\naddress = \":8081\"", wantErr: "already used by another listener"},
+		{name: "unknown profile", listeners: "[[listeners]]\nname = \"a\"\naddress = \":8081\"\nprofile = \"missing\"", wantErr: "unknown profile \"missing\""},
+		{name: "invalid auth", listeners: "[[listeners]]\nname = \"a\"\naddress = \":8081\"\nauth = \"basic\"", wantErr: "invalid auth \"basic\""},
+		{name: "api keys without keys", listeners: "[[listeners]]\nname = \"a\"\naddress = \":8081\"\nauth = \"api_keys\"", wantErr: "requires API keys or mTLS"},
+		{name: "tls without certificate", listeners: "[[listeners]]\nname = \"a\"\naddress = \":8081\"\ntls = true", wantErr: "tls requires the tls cert_file and key_file"},
+		{name: "mtls without tls", listeners: `
+[tls]
+cert_file = "/tls/tls.crt"
+key_file = "/tls/tls.key"
+[authentication.mtls]
+enabled = true
+client_ca_file = "/tls/ca.crt"
+[[listeners]]
+name = "a"
+address = ":8081"`, wantErr: "tls is required by the mtls authentication"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.ReadToml([]byte("[profiles.ro]\nread_only = true\n" + tt.listeners))
+			require.NoError(t, err)
+			err = cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+
+	_, err := runCommand(t, "config", "validate", "--config", writeConfig(t, "[[listeners]]\nname = \"a\"\naddress = \":8081\""))
+	assert.ErrorContains(t, err, "listeners are only served in HTTP mode")
+}
+
+func TestApplyListener(t *testing.T) {
+	extensions := config.Default()
+	extensions.Authentication.APIKeys = []config.APIKeyConfig{{Name: "ci", Hash: apiKeyHash("secret")}}
+	extensions.Impersonation.Enabled = true
+	extensions.TLS = config.TLSConfig{CertFile: "/tls/tls.crt", KeyFile: "/tls/tls.key", MinVersion: "1.2"}
+	extensions.Listeners = []config.ListenerConfig{
+		{Name: "admin", Address: "127.0.0.1:8081", Auth: config.ListenerAuthNone},
+		{Name: "public", Address: "unix:///run/mcp/public.sock", Auth: config.ListenerAuthOAuth, BaseURL: "https://mcp.example.com", TLS: true},
+		{Name: "ci", Address: ":8082", Auth: config.ListenerAuthAPIKeys},
+	}
+	staticConfig := staticconfig.Default()
+	staticConfig.Port = "8080"
+	staticConfig.RequireOAuth = true
+	staticConfig.SSEBaseURL = "https://internal.example.com"
+
+	static, ext, err := extensions.ApplyListener("admin", staticConfig)
+	require.NoError(t, err)
+	assert.Empty(t, static.Port, "The listener should not listen on the server port")
+	assert.False(t, static.RequireOAuth)
+	assert.False(t, ext.Authentication.Enabled())
+	assert.False(t, ext.Impersonation.Enabled, "The unauthenticated listeners should use the server credentials")
+	assert.False(t, ext.TLS.Enabled())
+	assert.Equal(t, "https://internal.example.com", static.SSEBaseURL, "The server base URL should be kept if not set")
+
+	static, ext, err = extensions.ApplyListener("public", staticConfig)
+	require.NoError(t, err)
+	assert.True(t, static.RequireOAuth)
+	assert.False(t, ext.Authentication.Enabled())
+	assert.True(t, ext.Impersonation.Enabled)
+	assert.True(t, ext.TLS.Enabled())
+	assert.Equal(t, "unix:///run/mcp/public.sock", ext.Listen.Address)
+	assert.Equal(t, "https://mcp.example.com", static.SSEBaseURL)
+	assert.Equal(t, "https://mcp.example.com", static.ServerURL)
+
+	static, ext, err = extensions.ApplyListener("ci", staticConfig)
+	require.NoError(t, err)
+	assert.False(t, static.RequireOAuth)
+	assert.True(t, ext.Authentication.Enabled())
+
+	assert.True(t, staticConfig.RequireOAuth, "The server configuration should not be modified")
+	assert.Len(t, extensions.Authentication.APIKeys, 1)
+
+	_, _, err = extensions.ApplyListener("missing", staticConfig)
+	assert.ErrorContains(t, err, `unknown listener "missing"`)
+}
+
+func TestServeListeners(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(impersonationKubeconfig), 0600))
+	staticConfig := staticconfig.Default()
+	staticConfig.KubeConfig = kubeconfigPath
+	staticConfig.Toolsets = []string{"test-greeting"}
+	publicSocket := filepath.Join(t.TempDir(), "public.sock")
+	adminSocket := filepath.Join(t.TempDir(), "admin.sock")
+	extensions := config.Default()
+	extensions.Listen = config.ListenConfig{Address: "unix://" + publicSocket, SocketMode: "0600"}
+	extensions.Authentication.APIKeys = []config.APIKeyConfig{{Name: "ci", Hash: apiKeyHash("secret")}}
+	extensions.Profiles = map[string]config.ProfileConfig{"greet": {EnabledTools: []string{"test_greet"}}}
+	extensions.Listeners = []config.ListenerConfig{
+		{Name: "admin", Address: "unix://" + adminSocket, Profile: "greet", Auth: config.ListenerAuthNone, BaseURL: "https://admin.example.com"},
+	}
+	require.NoError(t, extensions.Validate())
+
+	server, err := localmcp.NewServer(localmcp.Configuration{StaticConfig: staticConfig, Extensions: extensions})
+	require.NoError(t, err)
+	defer server.Close()
+	profileStatic, profileExtensions, err := extensions.ApplyProfile("greet", staticConfig)
+	require.NoError(t, err)
+	adminStatic, adminExtensions, err := profileExtensions.ApplyListener("admin", profileStatic)
+	require.NoError(t, err)
+	adminServer, err := localmcp.NewServer(localmcp.Configuration{StaticConfig: adminStatic, Extensions: adminExtensions})
+	require.NoError(t, err)
+	defer adminServer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- localhttp.ServeListeners(ctx, nil, nil,
+			localhttp.Listener{Name: "default", Server: server, StaticConfig: staticConfig, Extensions: extensions},
+			localhttp.Listener{Name: "admin", Server: adminServer, StaticConfig: adminStatic, Extensions: adminExtensions},
+		)
+	}()
+	defer func() {
+		cancel()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(10 * time.Second):
+			t.Error("The listeners should shut down")
+		}
+	}()
+	require.Eventually(t, func() bool {
+		for _, socket := range []string{publicSocket, adminSocket} {
+			conn, err := net.Dial("unix", socket)
+			if err != nil {
+				return false
+			}
+			_ = conn.Close()
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond, "The listeners should listen on their sockets")
+
+	status, _ := probe(t, unixHTTPClient(publicSocket), "/mcp")
+	assert.Equal(t, http.StatusUnauthorized, status, "The server listener should require an API key")
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "1.0.0"}, nil)
+	session, err := client.Connect(ctx, &mcp.StreamableClientTransport{Endpoint: "http://localhost/mcp", HTTPClient: unixHTTPClient(adminSocket)}, nil)
+	require.NoError(t, err, "The admin listener should not require authentication")
+	defer func() { _ = session.Close() }()
+	assert.Equal(t, []string{"test_greet"}, toolNames(t, session), "The admin listener should serve its profile")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/sse", nil)
+	require.NoError(t, err)
+	resp, err := unixHTTPClient(adminSocket).Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	reader := bufio.NewReader(resp.Body)
+	var endpoint string
+	for endpoint == "" {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		if data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: "); ok {
+			endpoint = data
+		}
+	}
+	assert.True(t, strings.HasPrefix(endpoint, "https://admin.example.com/message?sessionId="),
+		"The SSE endpoint should use the base URL of the listener, got %s", endpoint)
+}
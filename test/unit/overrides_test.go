@@ -0,0 +1,144 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the per-cluster read-only, toolset and tool overrides.
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+)
+
+// targetParameter returns the JSON schema of the cluster parameter of the tools and whether it's required.
+func targetParameter(t *testing.T, session *mcp.ClientSession) map[string]struct {
+	Property map[string]any
+	Required bool
+} {
+	tools, err := session.ListTools(context.Background(), nil)
+	require.NoError(t, err)
+	parameters := map[string]struct {
+		Property map[string]any
+		Required bool
+	}{}
+	for _, tool := range tools.Tools {
+		data, err := json.Marshal(tool.InputSchema)
+		require.NoError(t, err)
+		var schema struct {
+			Properties map[string]map[string]any `json:"properties"`
+			Required   []string                  `json:"required"`
+		}
+		require.NoError(t, json.Unmarshal(data, &schema))
+		parameter := parameters[tool.Name]
+		parameter.Property = schema.Properties["cluster"]
+		parameter.Required = slices.Contains(schema.Required, "cluster")
+		parameters[tool.Name] = parameter
+	}
+	return parameters
+}
+
+func callCluster(t *testing.T, session *mcp.ClientSession, tool string, arguments map[string]any) (string, bool) {
+	result, err := session.CallTool(context.Background(), &mcp.CallToolParams{Name: tool, Arguments: arguments})
+	require.NoError(t, err)
+	require.Len(t, result.Content, 1)
+	return result.Content[0].(*mcp.TextContent).Text, result.IsError
+}
+
+func TestClusterOverridesConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		toml    string
+		wantErr string
+	}{
+		{name: "valid", toml: "[cluster_overrides.prod]\nread_only = true\ntoolsets = [\"core\"]\ndisabled_tools = [\"pods_exec\"]"},
+		{name: "invalid toolset", toml: "[cluster_overrides.prod]\ntoolsets = [\"unknown\"]", wantErr: "invalid cluster_overrides.prod configuration: invalid toolset name unknown"},
+		{name: "empty name", toml: "[cluster_overrides.\"\"]\nread_only = true", wantErr: "cluster_overrides names must not be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.ReadToml([]byte(tt.toml))
+			require.NoError(t, err)
+			err = cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClusterOverrides(t *testing.T) {
+	session := connectFanOut(t, `
+[cluster_overrides.prod-eu-1]
+read_only = true
+[cluster_overrides.prod-us-1]
+read_only = true
+[cluster_overrides.prod-eu-2]
+disabled_tools = ["test_cluster_write"]
+`)
+	parameters := targetParameter(t, session)
+	assert.Equal(t, []any{"dev-1"}, parameters["test_cluster_write"].Property["enum"], "Only the allowed clusters should be offered")
+	assert.Contains(t, parameters["test_cluster_write"].Property["description"], "Defaults to dev-1")
+	assert.False(t, parameters["test_cluster_write"].Required, "The cluster should be optional if the default cluster is allowed")
+	assert.Equal(t, []any{"dev-1", "prod-eu-1", "prod-eu-2", "prod-us-1"}, parameters["test_cluster_server"].Property["enum"],
+		"The tools allowed on all the clusters should not be restricted")
+
+	text, isError := callCluster(t, session, "test_cluster_write", nil)
+	assert.False(t, isError)
+	assert.Equal(t, "written", text, "The tool should be allowed on the default cluster")
+	text, isError = callCluster(t, session, "test_cluster_write", map[string]any{"cluster": "prod-eu-1"})
+	assert.True(t, isError, "The read-only clusters should reject the other tools")
+	assert.Equal(t, `tool test_cluster_write is not allowed on the cluster "prod-eu-1", it's only allowed on: dev-1`, text)
+	text, isError = callCluster(t, session, "test_cluster_server", map[string]any{"cluster": "prod-eu-1"})
+	assert.False(t, isError, "The read-only clusters should allow the read-only tools")
+	assert.Equal(t, "https://prod-eu-1.example.com:6443", text)
+}
+
+func TestClusterOverridesDefaultCluster(t *testing.T) {
+	session := connectFanOut(t, `
+[fan_out]
+cluster_timeout = "100ms"
+[cluster_overrides.dev-1]
+toolsets = ["core"]
+[cluster_overrides.prod-eu-1]
+read_only = true
+[cluster_overrides.prod-eu-2]
+enabled_tools = ["test_cluster_server"]
+[cluster_overrides.prod-us-1]
+disable_destructive = true
+`)
+	parameters := targetParameter(t, session)
+	assert.Equal(t, []any{"prod-us-1"}, parameters["test_cluster_write"].Property["enum"])
+	assert.Equal(t, []any{"prod-eu-1", "prod-eu-2", "prod-us-1"}, parameters["test_cluster_server"].Property["enum"])
+	assert.True(t, parameters["test_cluster_server"].Required, "The cluster should be required if the default cluster isn't allowed")
+
+	text, isError := callCluster(t, session, "test_cluster_server", nil)
+	assert.True(t, isError, "The default cluster should reject the tools it doesn't allow")
+	assert.Contains(t, text, `tool test_cluster_server is not allowed on the cluster "dev-1"`)
+
+	text, isError = callCluster(t, session, "test_cluster_server", map[string]any{"cluster_selector": "env in (dev,prod),region!=us"})
+	assert.False(t, isError, text)
+	assert.Contains(t, text, "--- cluster: dev-1 (failed) ---\ntool test_cluster_server is not allowed on the cluster \"dev-1\"",
+		"The fanned out calls should be restricted too")
+	assert.Contains(t, text, "--- cluster: prod-eu-1 ---\nhttps://prod-eu-1.example.com:6443\n")
+}
+
+func TestClusterOverridesRemoveTools(t *testing.T) {
+	session := connectFanOut(t, `
+[cluster_overrides.dev-1]
+read_only = true
+[cluster_overrides.prod-eu-1]
+read_only = true
+[cluster_overrides.prod-eu-2]
+toolsets = ["core"]
+[cluster_overrides.prod-us-1]
+enabled_tools = ["test_cluster_server"]
+`)
+	assert.Equal(t, []string{"test_cluster_server"}, toolNames(t, session), "The tools not allowed on any cluster should be removed")
+}
@@ -0,0 +1,117 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the named configuration profiles and the namespace scopes.
+package unit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	staticconfig "github.com/containers/kubernetes-mcp-server/pkg/config"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	localmcp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/mcp"
+)
+
+const profilesConfig = `
+toolsets = ["core", "config", "helm"]
+read_only = false
+
+[profiles.dev]
+toolsets = ["core", "config"]
+
+[profiles.prod-readonly]
+read_only = true
+namespaces = ["prod", "prod-monitoring"]
+cluster_provider_strategy = "disabled"
+path = "/readonly"
+`
+
+func TestApplyProfile(t *testing.T) {
+	staticConfig, err := staticconfig.ReadToml([]byte(profilesConfig))
+	require.NoError(t, err)
+	extensions, err := config.ReadToml([]byte(profilesConfig))
+	require.NoError(t, err)
+	require.NoError(t, extensions.Validate())
+
+	static, ext, err := extensions.ApplyProfile("prod-readonly", staticConfig)
+	require.NoError(t, err)
+	assert.True(t, static.ReadOnly, "Profile settings should be applied")
+	assert.Equal(t, []string{"prod", "prod-monitoring"}, ext.Namespaces, "Profile settings should be applied")
+	assert.Equal(t, staticconfig.ClusterProviderDisabled, static.ClusterProviderStrategy, "Profile settings should be applied")
+	assert.Equal(t, []string{"core", "config", "helm"}, static.Toolsets, "Settings not set in the profile should be kept")
+	assert.Equal(t, "prod-readonly", ext.Profile)
+	assert.False(t, staticConfig.ReadOnly, "The original configuration should not be modified")
+	assert.Empty(t, extensions.Namespaces, "The original configuration should not be modified")
+
+	static, _, err = extensions.ApplyProfile("dev", staticConfig)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"core", "config"}, static.Toolsets, "Profile settings should be applied")
+	assert.False(t, static.ReadOnly, "Settings not set in the profile should be kept")
+
+	_, _, err = extensions.ApplyProfile("missing", staticConfig)
+	assert.ErrorContains(t, err, "valid profiles are: dev, prod-readonly")
+
+	assert.Equal(t, []string{"prod-readonly"}, extensions.EndpointProfiles(), "Profiles with a path should be served as endpoints")
+}
+
+func TestProfileValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{"unknown selected profile", "profile = \"missing\"", "unknown profile"},
+		{"invalid toolset", "[profiles.a]\ntoolsets = [\"missing\"]", "invalid toolset name missing"},
+		{"relative path", "[profiles.a]\npath = \"readonly\"", "must start"},
+		{"trailing slash", "[profiles.a]\npath = \"/readonly/\"", "must not end"},
+		{"reserved path", "[profiles.a]\npath = \"/mcp\"", "conflicts with the server endpoints"},
+		{"duplicate path", "[profiles.a]\npath = \"/ro\"\n[profiles.b]\npath = \"/ro\"", "already used by profile a"},
+		{"empty namespace", "[profiles.a]\nnamespaces = [\"\"]", "must not be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.ReadToml([]byte(tt.config))
+			require.NoError(t, err)
+			assert.ErrorContains(t, cfg.Validate(), tt.err)
+		})
+	}
+}
+
+func TestNamespaceScopes(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(impersonationKubeconfig), 0600))
+	staticConfig := staticconfig.Default()
+	staticConfig.KubeConfig = kubeconfigPath
+	staticConfig.Toolsets = []string{"core"}
+	extensions := config.Default()
+	extensions.Namespaces = []string{"dev"}
+	server, err := localmcp.NewServer(localmcp.Configuration{StaticConfig: staticConfig, Extensions: extensions})
+	require.NoError(t, err)
+	defer server.Close()
+
+	ctx := context.Background()
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	_, err = server.Connect(ctx, serverTransport, nil)
+	require.NoError(t, err)
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "test"}, nil).Connect(ctx, clientTransport, nil)
+	require.NoError(t, err)
+	defer func() { _ = session.Close() }()
+
+	names := toolNames(t, session)
+	assert.NotContains(t, names, "pods_list", "Tools that can't be restricted to the namespaces should be removed")
+	assert.Contains(t, names, "pods_list_in_namespace", "Tools with a namespace argument should be kept")
+
+	result, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "pods_list_in_namespace", Arguments: map[string]any{"namespace": "prod"}})
+	require.NoError(t, err)
+	assert.True(t, result.IsError, "Calls for other namespaces should be rejected")
+	assert.Contains(t, result.Content[0].(*mcp.TextContent).Text, `namespace "prod" is not allowed`)
+
+	result, err = session.CallTool(ctx, &mcp.CallToolParams{Name: "events_list", Arguments: map[string]any{"namespace": "kube-system"}})
+	require.NoError(t, err)
+	assert.True(t, result.IsError, "Calls for other namespaces should be rejected")
+}
@@ -0,0 +1,125 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the rate limits and concurrency quotas per client.
+package unit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	staticconfig "github.com/containers/kubernetes-mcp-server/pkg/config"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	localmcp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/mcp"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/ratelimit"
+)
+
+func TestRateLimitDisabled(t *testing.T) {
+	assert.Nil(t, ratelimit.New(config.Default().RateLimit), "Limiter should be nil when disabled")
+}
+
+func TestRateLimitRequestsPerSecond(t *testing.T) {
+	limiter := ratelimit.New(config.RateLimitConfig{Enabled: true, RequestsPerSecond: 1, Burst: 2})
+
+	assert.NoError(t, limiter.Allow("alice"))
+	assert.NoError(t, limiter.Allow("alice"), "Requests within the burst should be allowed")
+	err := limiter.Allow("alice")
+	var limitErr *ratelimit.Error
+	require.True(t, errors.As(err, &limitErr), "Requests above the burst should be rejected")
+	assert.Equal(t, 1, limitErr.RetryAfterSeconds(), "Retry hint should be provided")
+	assert.NoError(t, limiter.Allow("bob"), "Clients should be limited independently")
+}
+
+func TestRateLimitConcurrentToolCalls(t *testing.T) {
+	limiter := ratelimit.New(config.RateLimitConfig{Enabled: true, MaxConcurrentToolCalls: 1})
+
+	_, done, err := limiter.StartToolCall(context.Background(), "alice")
+	require.NoError(t, err)
+	_, _, err = limiter.StartToolCall(context.Background(), "alice")
+	assert.Error(t, err, "Tool calls above the concurrency quota should be rejected")
+
+	done()
+	done()
+	_, _, err = limiter.StartToolCall(context.Background(), "alice")
+	assert.NoError(t, err, "Tool calls should be allowed once the previous calls completed")
+}
+
+func TestRateLimitKubernetesAPICalls(t *testing.T) {
+	limiter := ratelimit.New(config.RateLimitConfig{Enabled: true, KubernetesAPICallsPerMinute: 2})
+
+	ctx, done, err := limiter.StartToolCall(context.Background(), "alice")
+	require.NoError(t, err)
+	ratelimit.RecordAPICall(ctx)
+	ratelimit.RecordAPICall(ctx)
+	done()
+
+	_, _, err = limiter.StartToolCall(context.Background(), "alice")
+	var limitErr *ratelimit.Error
+	require.True(t, errors.As(err, &limitErr), "Tool calls should be rejected once the API calls budget is spent")
+	assert.Equal(t, 60, limitErr.RetryAfterSeconds(), "Retry hint should be the time until the oldest call leaves the window")
+
+	_, _, err = limiter.StartToolCall(context.Background(), "bob")
+	assert.NoError(t, err, "Other clients should have their own budget")
+}
+
+func TestRateLimitToolCallResult(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(impersonationKubeconfig), 0600))
+	staticConfig := staticconfig.Default()
+	staticConfig.KubeConfig = kubeconfigPath
+	staticConfig.Toolsets = []string{"config"}
+	extensions := config.Default()
+	extensions.RateLimit = config.RateLimitConfig{Enabled: true, KeyBy: config.RateLimitKeyBySession, RequestsPerSecond: 0.01, Burst: 2}
+	server, err := localmcp.NewServer(localmcp.Configuration{StaticConfig: staticConfig, Extensions: extensions})
+	require.NoError(t, err)
+	defer server.Close()
+
+	ctx := context.Background()
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	_, err = server.Connect(ctx, serverTransport, nil)
+	require.NoError(t, err)
+	// initialize is the first request of the burst
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "test"}, nil).Connect(ctx, clientTransport, nil)
+	require.NoError(t, err)
+	defer func() { _ = session.Close() }()
+
+	result, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "configuration_contexts_list"})
+	require.NoError(t, err)
+	assert.False(t, result.IsError, "Tool calls within the burst should succeed")
+
+	result, err = session.CallTool(ctx, &mcp.CallToolParams{Name: "configuration_contexts_list"})
+	require.NoError(t, err)
+	assert.True(t, result.IsError, "Tool calls above the rate should return an error result")
+	assert.Contains(t, result.Content[0].(*mcp.TextContent).Text, "rate limit exceeded")
+	assert.Contains(t, result.Meta, "retryAfterSeconds", "Rate limited tool calls should include the retry hint")
+}
+
+func TestRateLimitUnauthenticatedClients(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "mcp.sock")
+	extensions := config.Default()
+	extensions.Listen = config.ListenConfig{Address: "unix://" + socket, SocketMode: "0600"}
+	extensions.RateLimit = config.RateLimitConfig{Enabled: true, KeyBy: config.RateLimitKeyByIdentity, RequestsPerSecond: 0.01, Burst: 3}
+	stop := serveUnix(t, extensions)
+	defer func() { _ = stop() }()
+
+	call := func() *mcp.CallToolResult {
+		client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "1.0.0"}, nil)
+		transport := &mcp.StreamableClientTransport{Endpoint: "http://localhost/mcp", HTTPClient: unixHTTPClient(socket)}
+		session, err := client.Connect(context.Background(), transport, nil)
+		require.NoError(t, err)
+		defer func() { _ = session.Close() }()
+		result, err := session.CallTool(context.Background(), &mcp.CallToolParams{Name: "test_greet"})
+		require.NoError(t, err)
+		return result
+	}
+	// the initialize requests are part of the burst
+	assert.False(t, call().IsError, "Tool calls within the burst should succeed")
+	result := call()
+	assert.True(t, result.IsError, "The stateless sessions of a client should share its rate limits")
+	assert.Contains(t, toolResultTexts(result), "rate limit exceeded")
+}
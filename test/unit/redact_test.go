@@ -0,0 +1,161 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the redaction of sensitive data in tool and resource output.
+package unit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/redact"
+)
+
+func newTestRedactor(t *testing.T, mutate func(cfg *config.RedactionConfig)) *redact.Redactor {
+	cfg := config.Default().Redaction
+	if mutate != nil {
+		mutate(&cfg)
+	}
+	redactor, err := redact.New(cfg)
+	require.NoError(t, err, "Redactor should be created")
+	require.NotNil(t, redactor, "Redactor should not be nil when enabled")
+	return redactor
+}
+
+func TestRedactionDisabled(t *testing.T) {
+	cfg := config.Default().Redaction
+	assert.True(t, cfg.Enabled, "Redaction should be enabled by default")
+	cfg.Enabled = false
+	redactor, err := redact.New(cfg)
+	require.NoError(t, err)
+	assert.Nil(t, redactor, "Redactor should be nil when disabled")
+
+	text := "apiVersion: v1\nkind: Secret\ndata:\n  password: c2VjcmV0\n"
+	assert.Equal(t, text, redactor.Redact(text), "nil Redactor should return the input unchanged")
+}
+
+func TestRedactSecretData(t *testing.T) {
+	redactor := newTestRedactor(t, nil)
+
+	text := "# The following resources (YAML) have been created or updated successfully\n" +
+		"apiVersion: v1\nkind: Secret\nmetadata:\n  name: db\ndata:\n  password: c2VjcmV0\nstringData:\n  user: admin\n"
+	redacted := redactor.Redact(text)
+
+	assert.Contains(t, redacted, "# The following resources (YAML) have been created or updated successfully\n",
+		"Leading comments should be preserved")
+	assert.NotContains(t, redacted, "c2VjcmV0", "Secret data should be masked")
+	assert.NotContains(t, redacted, "admin", "Secret stringData should be masked")
+	assert.Contains(t, redacted, "password: '***REDACTED***'", "Secret data keys should be preserved")
+	assert.Contains(t, redacted, "name: db", "Non sensitive fields should be preserved")
+}
+
+func TestRedactSecretList(t *testing.T) {
+	redactor := newTestRedactor(t, nil)
+
+	text := "- apiVersion: v1\n  kind: Secret\n  data:\n    token: dG9rZW4=\n" +
+		"- apiVersion: v1\n  kind: ConfigMap\n  data:\n    setting: visible\n"
+	redacted := redactor.Redact(text)
+
+	assert.NotContains(t, redacted, "dG9rZW4=", "Secret data in lists should be masked")
+	assert.Contains(t, redacted, "setting: visible", "ConfigMap data should not be masked")
+}
+
+func TestRedactMultipleDocuments(t *testing.T) {
+	redactor := newTestRedactor(t, nil)
+
+	text := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\ndata:\n  setting: visible\n" +
+		"---\napiVersion: v1\nkind: Secret\nmetadata:\n  name: db\ndata:\n  password: c2VjcmV0\n"
+	redacted := redactor.Redact(text)
+
+	assert.NotContains(t, redacted, "c2VjcmV0", "Secret data in later documents should be masked")
+	assert.Equal(t, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\ndata:\n  setting: visible\n"+
+		"---\napiVersion: v1\nkind: Secret\nmetadata:\n  name: db\ndata:\n  password: '***REDACTED***'\n", redacted,
+		"The documents and the order of their keys should be preserved")
+}
+
+func TestRedactJSON(t *testing.T) {
+	redactor := newTestRedactor(t, nil)
+
+	text := `{"kind":"Secret","apiVersion":"v1","metadata":{"name":"db"},"data":{"password":"c2VjcmV0","port":5432}}`
+	redacted := redactor.Redact(text)
+
+	assert.NotContains(t, redacted, "c2VjcmV0")
+	assert.JSONEq(t, `{"kind":"Secret","apiVersion":"v1","metadata":{"name":"db"},"data":{"password":"***REDACTED***","port":"***REDACTED***"}}`,
+		redacted, "JSON output should stay JSON")
+	assert.Less(t, strings.Index(redacted, `"kind"`), strings.Index(redacted, `"apiVersion"`), "The order of the keys should be preserved")
+}
+
+func TestRedactEnvVars(t *testing.T) {
+	redactor := newTestRedactor(t, nil)
+
+	text := "apiVersion: v1\nkind: Pod\nspec:\n  containers:\n  - name: app\n    env:\n" +
+		"    - name: DB_PASSWORD\n      value: hunter2\n" +
+		"    - name: LOG_LEVEL\n      value: debug\n"
+	redacted := redactor.Redact(text)
+
+	assert.NotContains(t, redacted, "hunter2", "Env vars matching the patterns should be masked")
+	assert.Contains(t, redacted, "value: debug", "Env vars not matching the patterns should be preserved")
+}
+
+func TestRedactKubeconfigCredentials(t *testing.T) {
+	redactor := newTestRedactor(t, nil)
+
+	text := "apiVersion: v1\nkind: Config\nusers:\n- name: admin\n  user:\n    token: my-token\n    client-key-data: a2V5\n" +
+		"- name: oidc\n  user:\n    auth-provider:\n      config:\n        id-token: my-id-token\n        client-id: visible\n"
+	redacted := redactor.Redact(text)
+
+	assert.NotContains(t, redacted, "my-token", "kubeconfig tokens should be masked")
+	assert.NotContains(t, redacted, "a2V5", "kubeconfig client keys should be masked")
+	assert.NotContains(t, redacted, "my-id-token", "kubeconfig auth-provider tokens should be masked")
+	assert.Contains(t, redacted, "client-id: visible", "Non credential fields should be preserved")
+}
+
+func TestRedactJSONPaths(t *testing.T) {
+	redactor := newTestRedactor(t, func(cfg *config.RedactionConfig) {
+		cfg.JSONPaths = []string{
+			`$.metadata.annotations.example\.com/token`,
+			"spec.containers[*].args",
+		}
+	})
+
+	text := "apiVersion: v1\nkind: Pod\nmetadata:\n  annotations:\n    example.com/token: abc123\n    other: visible\n" +
+		"spec:\n  containers:\n  - name: app\n    args:\n    - --password=hunter2\n"
+	redacted := redactor.Redact(text)
+
+	assert.NotContains(t, redacted, "abc123", "Configured JSONPaths should be masked")
+	assert.NotContains(t, redacted, "hunter2", "Configured JSONPaths with wildcards should be masked")
+	assert.Contains(t, redacted, "other: visible", "Other annotations should be preserved")
+}
+
+func TestRedactPatterns(t *testing.T) {
+	redactor := newTestRedactor(t, func(cfg *config.RedactionConfig) {
+		cfg.Patterns = []string{`(?i)bearer\s+[A-Za-z0-9._-]+`}
+	})
+
+	text := "2025-01-01 request sent with Authorization: Bearer abc.def.ghi\n2025-01-01 done"
+	redacted := redactor.Redact(text)
+
+	assert.NotContains(t, redacted, "abc.def.ghi", "Patterns should be masked in unstructured output")
+	assert.Contains(t, redacted, "2025-01-01 done", "Unstructured output should otherwise be preserved")
+}
+
+func TestRedactUnstructuredOutputUnchanged(t *testing.T) {
+	redactor := newTestRedactor(t, nil)
+
+	text := "NAMESPACE   APIVERSION   KIND     NAME\ndefault     v1           Secret   db\n"
+	assert.Equal(t, text, redactor.Redact(text), "Table output without sensitive data should be unchanged")
+}
+
+func TestRedactionInvalidConfig(t *testing.T) {
+	cfg := config.Default().Redaction
+	cfg.EnvPatterns = []string{"("}
+	_, err := redact.New(cfg)
+	assert.Error(t, err, "Invalid env patterns should be rejected")
+
+	cfg = config.Default().Redaction
+	cfg.JSONPaths = []string{"$."}
+	_, err = redact.New(cfg)
+	assert.Error(t, err, "Empty JSONPaths should be rejected")
+}
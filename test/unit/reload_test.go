@@ -0,0 +1,187 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the configuration hot-reload.
+package unit
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	staticconfig "github.com/containers/kubernetes-mcp-server/pkg/config"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	localmcp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/mcp"
+)
+
+func toolNames(t *testing.T, session *mcp.ClientSession) []string {
+	result, err := session.ListTools(context.Background(), &mcp.ListToolsParams{})
+	require.NoError(t, err)
+	names := make([]string, 0, len(result.Tools))
+	for _, tool := range result.Tools {
+		names = append(names, tool.Name)
+	}
+	return names
+}
+
+func TestServerReload(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(impersonationKubeconfig), 0600))
+	staticConfig := staticconfig.Default()
+	staticConfig.KubeConfig = kubeconfigPath
+	staticConfig.Toolsets = []string{"core"}
+	server, err := localmcp.NewServer(localmcp.Configuration{StaticConfig: staticConfig, Extensions: config.Default()})
+	require.NoError(t, err)
+	defer server.Close()
+
+	ctx := context.Background()
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	_, err = server.Connect(ctx, serverTransport, nil)
+	require.NoError(t, err)
+	listChanged := make(chan struct{}, 16)
+	client := mcp.NewClient(&mcp.Implementation{Name: "test"}, &mcp.ClientOptions{
+		ToolListChangedHandler: func(context.Context, *mcp.ToolListChangedRequest) { listChanged <- struct{}{} },
+	})
+	session, err := client.Connect(ctx, clientTransport, nil)
+	require.NoError(t, err)
+	defer func() { _ = session.Close() }()
+	require.Contains(t, toolNames(t, session), "pods_delete")
+
+	reloaded := staticconfig.Default()
+	reloaded.KubeConfig = kubeconfigPath
+	reloaded.Toolsets = []string{"core"}
+	reloaded.ReadOnly = true
+	require.NoError(t, server.Reload(localmcp.Configuration{StaticConfig: reloaded, Extensions: config.Default()}))
+
+	select {
+	case <-listChanged:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Clients should be notified of the tools list change")
+	}
+	names := toolNames(t, session)
+	assert.NotContains(t, names, "pods_delete", "Write tools should be removed when switching to read-only")
+	assert.True(t, slices.Contains(names, "pods_list"), "Read-only tools should be kept")
+}
+
+func TestServerReloadFailure(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(impersonationKubeconfig), 0600))
+	staticConfig := staticconfig.Default()
+	staticConfig.KubeConfig = kubeconfigPath
+	staticConfig.Toolsets = []string{"config"}
+	server, err := localmcp.NewServer(localmcp.Configuration{StaticConfig: staticConfig, Extensions: config.Default()})
+	require.NoError(t, err)
+	defer server.Close()
+	session, err := connectInMemory(t, server)
+	require.NoError(t, err)
+	defer func() { _ = session.Close() }()
+
+	reloaded := staticconfig.Default()
+	reloaded.KubeConfig = filepath.Join(t.TempDir(), "missing")
+	reloaded.Toolsets = []string{"config"}
+	extensions := config.Default()
+	extensions.RateLimit = config.RateLimitConfig{Enabled: true, KeyBy: config.RateLimitKeyBySession, RequestsPerSecond: 0.01, Burst: 1}
+	assert.Error(t, server.Reload(localmcp.Configuration{StaticConfig: reloaded, Extensions: extensions}),
+		"The configuration whose kubeconfig can't be loaded should be rejected")
+
+	for range 3 {
+		result, err := session.CallTool(context.Background(), &mcp.CallToolParams{Name: "configuration_contexts_list"})
+		require.NoError(t, err)
+		assert.False(t, result.IsError, "The previous configuration should be kept")
+	}
+}
+
+func TestConfigWatch(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	require.NoError(t, os.WriteFile(configPath, []byte("read_only = false"), 0600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan struct{}, 16)
+	require.NoError(t, config.Watch(ctx, []string{configPath}, func() { changes <- struct{}{} }))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "unrelated.toml"), []byte(""), 0600))
+	require.NoError(t, os.WriteFile(configPath, []byte("read_only = true"), 0600))
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Changes to the config file should be notified")
+	}
+	select {
+	case <-changes:
+		t.Fatal("Several events for a single change should be notified once")
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+func TestServerReloadAuthentication(t *testing.T) {
+	headers := make(chan http.Header, 10)
+	apiServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api":
+			_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":["v1"]}`))
+		case "/apis":
+			_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[]}`))
+		case "/api/v1":
+			_, _ = w.Write([]byte(`{"kind":"APIResourceList","groupVersion":"v1","resources":[{"name":"pods","namespaced":true,"kind":"Pod","verbs":["list"]}]}`))
+		default:
+			headers <- r.Header.Clone()
+			_, _ = w.Write([]byte(`{"kind":"PodList","apiVersion":"v1","items":[]}`))
+		}
+	}))
+	defer apiServer.Close()
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	kubeconfig := strings.ReplaceAll(impersonationKubeconfig, "https://cluster-1.example.com:6443",
+		apiServer.URL+"\n    insecure-skip-tls-verify: true")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(kubeconfig), 0600))
+	staticConfig := staticconfig.Default()
+	staticConfig.KubeConfig = kubeconfigPath
+	staticConfig.Toolsets = []string{"core"}
+	staticConfig.RequireOAuth = true
+	socket := filepath.Join(t.TempDir(), "mcp.sock")
+	extensions := config.Default()
+	extensions.Listen = config.ListenConfig{Address: "unix://" + socket, SocketMode: "0600"}
+	server, err := localmcp.NewServer(localmcp.Configuration{StaticConfig: staticConfig, Extensions: extensions})
+	require.NoError(t, err)
+	defer server.Close()
+
+	reloaded := *staticConfig
+	reloaded.ValidateToken = true
+	reloadedExtensions := *extensions
+	reloadedExtensions.Impersonation.Enabled = true
+	err = server.Reload(localmcp.Configuration{StaticConfig: &reloaded, Extensions: &reloadedExtensions})
+	assert.ErrorContains(t, err, "changes to validate_token, impersonation require a restart of the server",
+		"The tokens are still verified with the startup configuration")
+
+	stop := serveUnixServer(t, server, staticConfig, extensions)
+	defer func() { _ = stop() }()
+	// parsed by the OAuth middleware, but signed by no one
+	token := strings.TrimSuffix(newTestJWT(t, map[string]any{"sub": "mallory", "exp": time.Now().Add(time.Hour).Unix()}), "signature") +
+		base64.RawURLEncoding.EncodeToString([]byte("forged"))
+	httpClient := &http.Client{Transport: &authorizedTransport{base: unixHTTPClient(socket).Transport, authorization: "Bearer " + token}}
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "1.0.0"}, nil)
+	session, err := client.Connect(context.Background(), &mcp.StreamableClientTransport{Endpoint: "http://localhost/mcp", HTTPClient: httpClient}, nil)
+	require.NoError(t, err)
+	defer func() { _ = session.Close() }()
+	result, err := session.CallTool(context.Background(), &mcp.CallToolParams{Name: "pods_list_in_namespace", Arguments: map[string]any{"namespace": "default"}})
+	require.NoError(t, err)
+	assert.False(t, result.IsError, toolResultTexts(result))
+	select {
+	case header := <-headers:
+		assert.Empty(t, header.Get("Impersonate-User"), "The unsigned token should not be impersonated")
+		assert.Equal(t, "Bearer "+token, header.Get("Authorization"), "The token should be passed to the API server")
+	case <-time.After(5 * time.Second):
+		t.Fatal("The tool should call the API server")
+	}
+}
@@ -0,0 +1,162 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the JSON Schema of the config file.
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+)
+
+// compileSchema compiles the generated configuration schema.
+func compileSchema(t *testing.T) *jsonschema.Schema {
+	t.Helper()
+	data, err := config.Schema()
+	require.NoError(t, err)
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	require.NoError(t, err)
+	compiler := jsonschema.NewCompiler()
+	require.NoError(t, compiler.AddResource(config.SchemaID, doc))
+	schema, err := compiler.Compile(config.SchemaID)
+	require.NoError(t, err)
+	return schema
+}
+
+// tomlDocument returns the TOML document as a JSON value to be validated against the schema.
+func tomlDocument(t *testing.T, document string) any {
+	t.Helper()
+	values := make(map[string]any)
+	_, err := toml.Decode(document, &values)
+	require.NoError(t, err)
+	data, err := json.Marshal(values)
+	require.NoError(t, err)
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	require.NoError(t, err)
+	return doc
+}
+
+func TestConfigSchema(t *testing.T) {
+	schema := compileSchema(t)
+
+	valid := `
+log_level = 2
+port = "8080"
+toolsets = ["core", "helm", "test-greeting"]
+read_only = true
+
+[redaction]
+enabled = true
+env_patterns = ["(?i)token"]
+
+[authentication]
+[[authentication.api_keys]]
+name = "ci"
+hash = "sha256:abc"
+scopes = ["read"]
+
+[rate_limit]
+key_by = "session"
+requests_per_second = 2.5
+
+[toolset_configs.core]
+pods_log_tail = 50
+
+[toolset_configs.test-greeting]
+greeting = "hi"
+
+[profiles.ro]
+read_only = true
+namespaces = ["dev"]
+path = "/ro"
+
+[cluster_provider_configs.kubeconfig]
+anything = "goes"
+`
+	assert.NoError(t, schema.Validate(tomlDocument(t, valid)), "Valid configurations should match the schema")
+
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{"unknown setting", "read_onl = true"},
+		{"unknown section setting", "[rate_limit]\nburts = 10"},
+		{"invalid type", "read_only = \"yes\""},
+		{"unknown toolset", "toolsets = [\"nope\"]"},
+		{"invalid enum", "[rate_limit]\nkey_by = \"ip\""},
+		{"invalid scope", "[[authentication.api_keys]]\nname = \"a\"\nscopes = [\"admin\"]"},
+		{"unknown toolset section", "[toolset_configs.config]\nfoo = 1"},
+		{"unknown toolset setting", "[toolset_configs.core]\npods_log_tails = 10"},
+		{"unknown profile setting", "[profiles.a]\nreadonly = true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, schema.Validate(tomlDocument(t, tt.config)), "Invalid configurations should not match the schema")
+		})
+	}
+}
+
+func TestConfigSchemaDefaults(t *testing.T) {
+	data, err := config.Schema()
+	require.NoError(t, err)
+	var schema struct {
+		Properties struct {
+			ListOutput struct {
+				Default string `json:"default"`
+			} `json:"list_output"`
+			ToolsetConfigs struct {
+				Properties map[string]struct {
+					Properties map[string]struct {
+						Default any `json:"default"`
+					} `json:"properties"`
+				} `json:"properties"`
+			} `json:"toolset_configs"`
+		} `json:"properties"`
+	}
+	require.NoError(t, json.Unmarshal(data, &schema))
+	assert.Equal(t, "table", schema.Properties.ListOutput.Default, "Settings should document their defaults")
+	assert.Equal(t, float64(100), schema.Properties.ToolsetConfigs.Properties["core"].Properties["pods_log_tail"].Default, "Toolset settings should document their defaults")
+	assert.Equal(t, "hello", schema.Properties.ToolsetConfigs.Properties["test-greeting"].Properties["greeting"].Default, "Custom toolset sections should be included")
+}
+
+func TestPublishedConfigSchema(t *testing.T) {
+	published, err := os.ReadFile(filepath.Join("..", "..", "config.schema.json"))
+	require.NoError(t, err)
+	data, err := config.Schema()
+	require.NoError(t, err)
+
+	var expected, actual map[string]any
+	require.NoError(t, json.Unmarshal(published, &expected))
+	require.NoError(t, json.Unmarshal(data, &actual))
+	// the toolsets registered by the tests are not part of the published schema
+	properties := actual["properties"].(map[string]any)
+	delete(properties["toolset_configs"].(map[string]any)["properties"].(map[string]any), "test-greeting")
+	for _, path := range [][]string{
+		{"toolsets"},
+		{"profiles", "additionalProperties", "properties", "toolsets"},
+		{"cluster_overrides", "additionalProperties", "properties", "toolsets"},
+	} {
+		property := properties
+		for _, key := range path {
+			property = property[key].(map[string]any)
+		}
+		items := property["items"].(map[string]any)
+		var enum []any
+		for _, name := range items["enum"].([]any) {
+			if !strings.HasPrefix(name.(string), "test-") {
+				enum = append(enum, name)
+			}
+		}
+		items["enum"] = enum
+	}
+	assert.Equal(t, expected, actual, "config.schema.json is outdated, regenerate it with make schema")
+}
@@ -0,0 +1,489 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the session resumption and the session stores of the streamable HTTP transport.
+package unit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"iter"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/sessions"
+)
+
+// eventSource is the event part of the event and session stores.
+type eventSource interface {
+	After(ctx context.Context, sessionID, streamID string, index int) iter.Seq2[[]byte, error]
+}
+
+// storedEvents returns the events of the stream after the index.
+func storedEvents(store eventSource, sessionID, streamID string, index int) ([]string, error) {
+	var events []string
+	for data, err := range store.After(context.Background(), sessionID, streamID, index) {
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, string(data))
+	}
+	return events, nil
+}
+
+func TestEventStore(t *testing.T) {
+	ctx := context.Background()
+	store := sessions.NewEventStore(3, 1024)
+	require.NoError(t, store.Open(ctx, "session-1", "a"))
+	require.NoError(t, store.Open(ctx, "session-2", "a"))
+	for _, event := range []string{"a0", "b0", "a1", "b1"} {
+		require.NoError(t, store.Append(ctx, "session-1", event[:1], []byte(event)))
+	}
+	require.NoError(t, store.Append(ctx, "session-2", "a", []byte("other")))
+
+	events, err := storedEvents(store, "session-1", "a", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a1"}, events)
+	_, err = storedEvents(store, "session-1", "a", -1)
+	assert.ErrorIs(t, err, mcp.ErrEventsPurged, "The oldest event of the session should be dropped")
+	events, err = storedEvents(store, "session-1", "b", -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b0", "b1"}, events)
+	events, err = storedEvents(store, "session-2", "a", -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"other"}, events, "The sessions should be bounded independently")
+
+	require.NoError(t, store.SessionClosed(ctx, "session-1"))
+	_, err = storedEvents(store, "session-1", "b", -1)
+	assert.ErrorContains(t, err, "unknown session")
+
+	large := sessions.NewEventStore(100, 10)
+	require.NoError(t, large.Append(ctx, "session", "a", []byte("123456")))
+	require.NoError(t, large.Append(ctx, "session", "a", []byte("7890123")))
+	_, err = storedEvents(large, "session", "a", -1)
+	assert.ErrorIs(t, err, mcp.ErrEventsPurged, "The oldest events beyond the bytes bound should be dropped")
+	events, err = storedEvents(large, "session", "a", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"7890123"}, events, "The last event should be kept even if larger than the bound")
+}
+
+func TestSessionStores(t *testing.T) {
+	stores := map[string]func(t *testing.T, maxEvents, maxBytes int, idleTimeout time.Duration) sessions.Store{
+		"memory": func(_ *testing.T, maxEvents, maxBytes int, idleTimeout time.Duration) sessions.Store {
+			return sessions.NewMemoryStore(maxEvents, maxBytes, idleTimeout)
+		},
+		"file": func(t *testing.T, maxEvents, maxBytes int, idleTimeout time.Duration) sessions.Store {
+			store, err := sessions.NewFileStore(t.TempDir(), maxEvents, maxBytes, idleTimeout)
+			require.NoError(t, err)
+			return store
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			t.Run("sessions", func(t *testing.T) {
+				store := newStore(t, 10, 1024, time.Hour)
+				_, err := store.Load(ctx, "session-1")
+				assert.ErrorIs(t, err, sessions.ErrSessionNotFound)
+				saved := &sessions.Session{
+					ID:               "session-1",
+					Endpoint:         "/mcp",
+					InitializeParams: &mcp.InitializeParams{ProtocolVersion: "2025-06-18", ClientInfo: &mcp.Implementation{Name: "client"}},
+					LastActive:       time.Now(),
+				}
+				require.NoError(t, store.Save(ctx, saved))
+				require.NoError(t, store.Append(ctx, "session-1", "a", []byte("a0")))
+				loaded, err := store.Load(ctx, "session-1")
+				require.NoError(t, err)
+				assert.Equal(t, "/mcp", loaded.Endpoint)
+				assert.Equal(t, "2025-06-18", loaded.InitializeParams.ProtocolVersion)
+				assert.Equal(t, "client", loaded.InitializeParams.ClientInfo.Name)
+
+				require.NoError(t, store.Delete(ctx, "session-1"))
+				_, err = store.Load(ctx, "session-1")
+				assert.ErrorIs(t, err, sessions.ErrSessionNotFound)
+				_, err = storedEvents(store, "session-1", "a", -1)
+				assert.ErrorContains(t, err, "unknown session", "The events should be deleted with the session")
+
+				require.NoError(t, store.Save(ctx, &sessions.Session{ID: "idle", LastActive: time.Now().Add(-2 * time.Hour)}))
+				_, err = store.Load(ctx, "idle")
+				assert.ErrorIs(t, err, sessions.ErrSessionNotFound, "The sessions idle for longer than the idle timeout should expire")
+			})
+			t.Run("events", func(t *testing.T) {
+				store := newStore(t, 3, 1024, 0)
+				require.NoError(t, store.Open(ctx, "session-1", "a"))
+				require.NoError(t, store.Open(ctx, "session-1", ""))
+				for _, event := range []string{"a0", "b0", "a1", "b1"} {
+					require.NoError(t, store.Append(ctx, "session-1", event[:1], []byte(event)))
+				}
+				require.NoError(t, store.Open(ctx, "session-1", "a"), "Opening a stream again should keep its events")
+				events, err := storedEvents(store, "session-1", "a", 0)
+				require.NoError(t, err)
+				assert.Equal(t, []string{"a1"}, events)
+				_, err = storedEvents(store, "session-1", "a", -1)
+				assert.ErrorIs(t, err, mcp.ErrEventsPurged, "The oldest event of the session should be dropped")
+				events, err = storedEvents(store, "session-1", "b", -1)
+				require.NoError(t, err)
+				assert.Equal(t, []string{"b0", "b1"}, events)
+				events, err = storedEvents(store, "session-1", "", -1)
+				require.NoError(t, err)
+				assert.Empty(t, events, "The standalone stream should be known")
+				_, err = storedEvents(store, "session-1", "c", -1)
+				assert.ErrorContains(t, err, "unknown stream")
+
+				for range 3 {
+					require.NoError(t, store.Append(ctx, "session-1", "b", []byte("b")))
+				}
+				events, err = storedEvents(store, "session-1", "a", 1)
+				require.NoError(t, err)
+				assert.Empty(t, events, "The stream whose events were all dropped should be resumed after its last event")
+				_, err = storedEvents(store, "session-1", "a", 0)
+				assert.ErrorIs(t, err, mcp.ErrEventsPurged)
+				require.NoError(t, store.Append(ctx, "session-1", "a", []byte("a2")))
+				events, err = storedEvents(store, "session-1", "a", 1)
+				require.NoError(t, err)
+				assert.Equal(t, []string{"a2"}, events, "The indexes should continue after the dropped events")
+			})
+		})
+	}
+
+	store, err := sessions.NewFileStore(t.TempDir(), 10, 1024, 0)
+	require.NoError(t, err)
+	ctx := context.Background()
+	assert.ErrorContains(t, store.Save(ctx, &sessions.Session{ID: "../escape"}), "invalid session ID")
+	assert.ErrorContains(t, store.Append(ctx, "session", "../escape", nil), "invalid stream ID")
+	_, err = store.Load(ctx, "../escape")
+	assert.ErrorIs(t, err, sessions.ErrSessionNotFound)
+}
+
+func TestFileStoreInstances(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	// the stores of several server instances sharing the directory, which only share the lock files
+	var stores []*sessions.FileStore
+	for range 4 {
+		store, err := sessions.NewFileStore(dir, 50, 1<<20, 0)
+		require.NoError(t, err)
+		stores = append(stores, store)
+	}
+	var wg sync.WaitGroup
+	for _, store := range stores {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 25 {
+				assert.NoError(t, store.Append(ctx, "session-1", "a", []byte("event")))
+			}
+		}()
+	}
+	wg.Wait()
+	events, err := storedEvents(stores[0], "session-1", "a", 49)
+	require.NoError(t, err)
+	assert.Len(t, events, 50, "The events appended concurrently by the instances should all be indexed")
+	events, err = storedEvents(stores[0], "session-1", "a", 99)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	lock := filepath.Join(dir, "session-1", "lock")
+	require.NoError(t, os.WriteFile(lock, nil, 0o600))
+	stale := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(lock, stale, stale))
+	require.NoError(t, stores[0].Append(ctx, "session-1", "a", []byte("event")), "The lock left by a crashed instance should be removed")
+	require.NoError(t, os.WriteFile(lock, nil, 0o600))
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, stores[0].Append(timeout, "session-1", "a", []byte("event")), context.DeadlineExceeded,
+		"The session should not be written while locked by another instance")
+}
+
+func TestNewSessionStore(t *testing.T) {
+	cfg := config.Default().Sessions
+	store, err := sessions.NewStore(cfg)
+	require.NoError(t, err)
+	assert.IsType(t, &sessions.MemoryStore{}, store)
+
+	cfg.Store = config.SessionsStoreFile
+	cfg.StoreDir = filepath.Join(t.TempDir(), "sessions")
+	store, err = sessions.NewStore(cfg)
+	require.NoError(t, err)
+	assert.IsType(t, &sessions.FileStore{}, store)
+	assert.DirExists(t, cfg.StoreDir)
+
+	cfg.Store = "test-external"
+	_, err = sessions.NewStore(cfg)
+	assert.ErrorContains(t, err, `unknown sessions store "test-external"`)
+	external := sessions.NewMemoryStore(10, 1024, 0)
+	sessions.RegisterStore("test-external", func(config.SessionsConfig) (sessions.Store, error) { return external, nil })
+	store, err = sessions.NewStore(cfg)
+	require.NoError(t, err)
+	assert.Same(t, external, store, "The registered external store should be created")
+}
+
+// alternatingTransport sends each request to the other server instance, as a load balancer without sticky sessions.
+type alternatingTransport struct {
+	bases []http.RoundTripper
+
+	mu   sync.Mutex
+	next int
+}
+
+func (a *alternatingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	a.mu.Lock()
+	base := a.bases[a.next%len(a.bases)]
+	a.next++
+	a.mu.Unlock()
+	return base.RoundTrip(r)
+}
+
+func TestServeSharedSessionStore(t *testing.T) {
+	ctx := context.Background()
+	storeDir := t.TempDir()
+	var sockets []string
+	var bases []http.RoundTripper
+	for _, name := range []string{"a.sock", "b.sock"} {
+		socket := filepath.Join(t.TempDir(), name)
+		extensions := config.Default()
+		extensions.Listen = config.ListenConfig{Address: "unix://" + socket, SocketMode: "0600"}
+		extensions.Sessions.Resumption = true
+		extensions.Sessions.Store = config.SessionsStoreFile
+		extensions.Sessions.StoreDir = storeDir
+		stop := serveUnix(t, extensions, "test-wait")
+		defer func() { _ = stop() }()
+		sockets = append(sockets, socket)
+		bases = append(bases, unixHTTPClient(socket).Transport)
+	}
+
+	transport := &droppingTransport{base: &alternatingTransport{bases: bases}}
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "1.0.0"}, nil)
+	session, err := client.Connect(ctx, &mcp.StreamableClientTransport{
+		Endpoint:   "http://localhost/mcp",
+		HTTPClient: &http.Client{Transport: transport},
+		MaxRetries: 3,
+	}, nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, session.ID())
+	assert.DirExists(t, filepath.Join(storeDir, session.ID()), "The session should be recorded in the shared store")
+
+	for range 2 {
+		result, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "test_greet"})
+		require.NoError(t, err, "Both instances should serve the session")
+		assert.False(t, result.IsError)
+	}
+	result, err := session.CallTool(ctx, &mcp.CallToolParams{
+		Meta:      mcp.Meta{"progressToken": "wait"},
+		Name:      "test_wait",
+		Arguments: map[string]any{"duration": "300ms"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "waited 300ms", toolResultTexts(result), "The stream should be resumed by the other instance")
+	transport.mu.Lock()
+	assert.True(t, transport.dropped)
+	assert.True(t, slices.ContainsFunc(transport.resumed, func(id string) bool { return id != "" }))
+	transport.mu.Unlock()
+
+	require.NoError(t, session.Close())
+	_, err = os.Stat(filepath.Join(storeDir, session.ID()))
+	assert.True(t, os.IsNotExist(err), "The closed session should be deleted from the shared store")
+
+	req, err := http.NewRequest(http.MethodPost, "http://localhost/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	require.NoError(t, err)
+	req.Header.Set("Mcp-Session-Id", "UNKNOWN")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := unixHTTPClient(sockets[0]).Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "The sessions missing from the store should not be found")
+}
+
+// droppingTransport drops the connection of the first test_wait call response after its first event,
+// recording the Last-Event-ID of the requests resuming the streams.
+type droppingTransport struct {
+	base http.RoundTripper
+
+	mu      sync.Mutex
+	dropped bool
+	resumed []string
+}
+
+func (d *droppingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	var body []byte
+	if r.Body != nil {
+		body, _ = io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	d.mu.Lock()
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		d.resumed = append(d.resumed, id)
+	}
+	drop := !d.dropped && bytes.Contains(body, []byte(`"test_wait"`))
+	d.dropped = d.dropped || drop
+	d.mu.Unlock()
+	resp, err := d.base.RoundTrip(r)
+	if err == nil && drop {
+		resp.Body = &droppedBody{ReadCloser: resp.Body}
+	}
+	return resp, err
+}
+
+// droppedBody fails the reads after the first server-sent event.
+type droppedBody struct {
+	io.ReadCloser
+	read []byte
+}
+
+func (b *droppedBody) Read(p []byte) (int, error) {
+	if end := bytes.Index(b.read, []byte("\n\n")); end >= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.read = append(b.read, p[:n]...)
+	if end := bytes.Index(b.read, []byte("\n\n")); end >= 0 {
+		n -= len(b.read) - end - 2
+		b.read = b.read[:end+2]
+	}
+	return n, err
+}
+
+func TestServeSessionResumption(t *testing.T) {
+	ctx := context.Background()
+	socket := filepath.Join(t.TempDir(), "mcp.sock")
+	extensions := config.Default()
+	extensions.Listen = config.ListenConfig{Address: "unix://" + socket, SocketMode: "0600"}
+	extensions.Sessions.Resumption = true
+	stop := serveUnix(t, extensions, "test-wait")
+	defer func() { _ = stop() }()
+
+	transport := &droppingTransport{base: unixHTTPClient(socket).Transport}
+	progress := make(chan string, 10)
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "1.0.0"}, &mcp.ClientOptions{
+		ProgressNotificationHandler: func(_ context.Context, request *mcp.ProgressNotificationClientRequest) {
+			progress <- request.Params.Message
+		},
+	})
+	session, err := client.Connect(ctx, &mcp.StreamableClientTransport{
+		Endpoint:   "http://localhost/mcp",
+		HTTPClient: &http.Client{Transport: transport},
+		MaxRetries: 3,
+	}, nil)
+	require.NoError(t, err)
+	defer func() { _ = session.Close() }()
+	assert.NotEmpty(t, session.ID(), "The sessions should be stateful")
+
+	result, err := session.CallTool(ctx, &mcp.CallToolParams{
+		Meta:      mcp.Meta{"progressToken": "wait"},
+		Name:      "test_wait",
+		Arguments: map[string]any{"duration": "300ms"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "waited 300ms", toolResultTexts(result), "The result should be replayed once the stream is resumed")
+	select {
+	case message := <-progress:
+		assert.Equal(t, "started test_wait", message)
+	case <-time.After(5 * time.Second):
+		t.Fatal("The start of the call should be notified")
+	}
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	assert.True(t, transport.dropped)
+	assert.True(t, slices.ContainsFunc(transport.resumed, func(id string) bool { return id != "" }),
+		"The client should resume the stream with Last-Event-ID")
+}
+
+func TestSessionsConfigValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		sessions config.SessionsConfig
+		wantErr  string
+	}{
+		{name: "defaults", sessions: config.Default().Sessions},
+		{name: "no idle timeout", sessions: config.SessionsConfig{Resumption: true, MaxEvents: 10, MaxBytes: 1024, IdleTimeout: "0s"}},
+		{name: "no events", sessions: config.SessionsConfig{MaxEvents: 0, MaxBytes: 1024, IdleTimeout: "30m"}, wantErr: "sessions max_events must be positive"},
+		{name: "no bytes", sessions: config.SessionsConfig{MaxEvents: 10, MaxBytes: 0, IdleTimeout: "30m"}, wantErr: "sessions max_bytes must be positive"},
+		{name: "file store", sessions: config.SessionsConfig{Store: "file", StoreDir: "/var/lib/mcp/sessions", MaxEvents: 10, MaxBytes: 1024, IdleTimeout: "30m"}},
+		{name: "file store without dir", sessions: config.SessionsConfig{Store: "file", MaxEvents: 10, MaxBytes: 1024, IdleTimeout: "30m"}, wantErr: "sessions store_dir is required by the file store"},
+		{name: "invalid idle timeout", sessions: config.SessionsConfig{MaxEvents: 10, MaxBytes: 1024, IdleTimeout: "later"}, wantErr: "invalid sessions idle_timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Sessions = tt.sessions
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServeSessionOwner(t *testing.T) {
+	ctx := context.Background()
+	storeDir := t.TempDir()
+	var sockets []string
+	for _, name := range []string{"a.sock", "b.sock"} {
+		socket := filepath.Join(t.TempDir(), name)
+		extensions := config.Default()
+		extensions.Listen = config.ListenConfig{Address: "unix://" + socket, SocketMode: "0600"}
+		extensions.Authentication.APIKeys = []config.APIKeyConfig{
+			{Name: "alice", Hash: apiKeyHash("alice-key")},
+			{Name: "bob", Hash: apiKeyHash("bob-key")},
+		}
+		extensions.Sessions.Resumption = true
+		extensions.Sessions.Store = config.SessionsStoreFile
+		extensions.Sessions.StoreDir = storeDir
+		stop := serveUnix(t, extensions)
+		defer func() { _ = stop() }()
+		sockets = append(sockets, socket)
+	}
+
+	httpClient := &http.Client{Transport: &authorizedTransport{base: unixHTTPClient(sockets[0]).Transport, authorization: "Bearer alice-key"}}
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "1.0.0"}, nil)
+	session, err := client.Connect(ctx, &mcp.StreamableClientTransport{Endpoint: "http://localhost/mcp", HTTPClient: httpClient}, nil)
+	require.NoError(t, err)
+	defer func() { _ = session.Close() }()
+	require.NoError(t, session.SetLoggingLevel(ctx, &mcp.SetLoggingLevelParams{Level: "debug"}))
+
+	store, err := sessions.NewFileStore(storeDir, 10, 1024, 0)
+	require.NoError(t, err)
+	record, err := store.Load(ctx, session.ID())
+	require.NoError(t, err)
+	assert.Equal(t, "api-key:alice", record.Owner, "The session should be bound to the principal that created it")
+	assert.Equal(t, mcp.LoggingLevel("debug"), record.LogLevel, "The log level should be recorded to restore the session with it")
+
+	request := func(socket, method, key string) int {
+		req, err := http.NewRequest(method, "http://localhost/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+key)
+		req.Header.Set("Mcp-Session-Id", session.ID())
+		req.Header.Set("Accept", "application/json, text/event-stream")
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := unixHTTPClient(socket).Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	for _, socket := range sockets {
+		assert.Equal(t, http.StatusNotFound, request(socket, http.MethodPost, "bob-key"), "Other principals should not use the session")
+		assert.Equal(t, http.StatusNotFound, request(socket, http.MethodDelete, "bob-key"), "Other principals should not delete the session")
+	}
+	assert.Equal(t, http.StatusOK, request(sockets[1], http.MethodPost, "alice-key"), "The owner should use the session through any instance")
+	record, err = store.Load(ctx, session.ID())
+	require.NoError(t, err)
+	assert.Equal(t, mcp.LoggingLevel("debug"), record.LogLevel, "The restored session should keep its log level")
+
+	result, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "test_greet"})
+	require.NoError(t, err)
+	assert.False(t, result.IsError)
+}
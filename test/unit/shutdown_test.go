@@ -0,0 +1,255 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the graceful shutdown draining the in-flight tool calls.
+package unit
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	k8sapi "github.com/containers/kubernetes-mcp-server/pkg/api"
+	staticconfig "github.com/containers/kubernetes-mcp-server/pkg/config"
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/ptr"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	localhttp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/http"
+	localmcp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/mcp"
+)
+
+// waitTools are the tools of the test-wait toolset, whose tool waits for a duration, or until the call is cancelled.
+func waitTools() []k8sapi.ServerTool {
+	return []k8sapi.ServerTool{{
+		Tool: k8sapi.Tool{
+			Name:        "test_wait",
+			Description: "Waits for the duration, or until the call is cancelled",
+			InputSchema: &jsonschema.Schema{Type: "object", Properties: map[string]*jsonschema.Schema{
+				"duration": {Type: "string"},
+			}},
+			Annotations: k8sapi.ToolAnnotations{ReadOnlyHint: ptr.To(true)},
+		},
+		Handler: func(params k8sapi.ToolHandlerParams) (*k8sapi.ToolCallResult, error) {
+			value, _ := params.GetArguments()["duration"].(string)
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				return k8sapi.NewToolCallResult("", err), nil
+			}
+			select {
+			case testWaitStarted <- struct{}{}:
+			default:
+			}
+			select {
+			case <-time.After(duration):
+				return k8sapi.NewToolCallResult("waited "+duration.String(), nil), nil
+			case <-params.Done():
+				return k8sapi.NewToolCallResult("", params.Err()), nil
+			}
+		},
+		ClusterAware: ptr.To(false),
+	}}
+}
+
+// testWaitStarted receives a value when a test_wait call starts (dropped if the previous one wasn't received).
+var testWaitStarted = make(chan struct{}, 1)
+
+// toolCall is the outcome of a tool call made in the background.
+type toolCall struct {
+	result *mcp.CallToolResult
+	err    error
+}
+
+// callInBackground calls the tool, returning once the test_wait call started.
+func callInBackground(ctx context.Context, session *mcp.ClientSession, arguments map[string]any) <-chan toolCall {
+	// discard the start of test_wait calls made without waiting for it
+	select {
+	case <-testWaitStarted:
+	default:
+	}
+	call := make(chan toolCall, 1)
+	go func() {
+		result, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "test_wait", Arguments: arguments})
+		call <- toolCall{result: result, err: err}
+	}()
+	<-testWaitStarted
+	return call
+}
+
+func newShutdownTestServer(t *testing.T) *localmcp.Server {
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(impersonationKubeconfig), 0600))
+	staticConfig := staticconfig.Default()
+	staticConfig.KubeConfig = kubeconfigPath
+	staticConfig.Toolsets = []string{"test-greeting", "test-wait"}
+	server, err := localmcp.NewServer(localmcp.Configuration{StaticConfig: staticConfig})
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func connectInMemory(t *testing.T, server *localmcp.Server) (*mcp.ClientSession, error) {
+	ctx := context.Background()
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	_, err := server.Connect(ctx, serverTransport, nil)
+	require.NoError(t, err)
+	return mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "1.0.0"}, nil).Connect(ctx, clientTransport, nil)
+}
+
+func TestServerShutdown(t *testing.T) {
+	ctx := context.Background()
+	server := newShutdownTestServer(t)
+	session, err := connectInMemory(t, server)
+	require.NoError(t, err)
+	defer func() { _ = session.Close() }()
+
+	call := callInBackground(ctx, session, map[string]any{"duration": "300ms"})
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		shutdown <- server.Shutdown(shutdownCtx)
+	}()
+	require.Eventually(t, func() bool {
+		result, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "test_greet"})
+		return err == nil && result.IsError
+	}, 5*time.Second, 10*time.Millisecond, "New tool calls should be refused once shutting down")
+	result, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "test_greet"})
+	require.NoError(t, err)
+	assert.Contains(t, toolResultTexts(result), localmcp.ErrShuttingDown.Error())
+	_, err = connectInMemory(t, server)
+	assert.ErrorContains(t, err, localmcp.ErrShuttingDown.Error(), "New sessions should be refused once shutting down")
+
+	completed := <-call
+	require.NoError(t, completed.err)
+	assert.False(t, completed.result.IsError, "The in-flight tool call should complete")
+	assert.Equal(t, "waited 300ms", toolResultTexts(completed.result))
+	assert.NoError(t, <-shutdown)
+}
+
+func TestServerShutdownGracePeriodExpired(t *testing.T) {
+	ctx := context.Background()
+	server := newShutdownTestServer(t)
+	session, err := connectInMemory(t, server)
+	require.NoError(t, err)
+	defer func() { _ = session.Close() }()
+
+	callCtx, cancelCall := context.WithCancel(ctx)
+	call := callInBackground(callCtx, session, map[string]any{"duration": "1m"})
+	shutdownCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorContains(t, server.Shutdown(shutdownCtx), "1 tool calls still in progress")
+	cancelCall()
+	<-call
+}
+
+func TestServeGracefulShutdown(t *testing.T) {
+	ctx := context.Background()
+	socket := filepath.Join(t.TempDir(), "mcp.sock")
+	extensions := config.Default()
+	extensions.Listen = config.ListenConfig{Address: "unix://" + socket, SocketMode: "0600"}
+	stop := serveUnix(t, extensions, "test-wait")
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "1.0.0"}, nil)
+	session, err := client.Connect(ctx, &mcp.StreamableClientTransport{Endpoint: "http://localhost/mcp", HTTPClient: unixHTTPClient(socket)}, nil)
+	require.NoError(t, err)
+	defer func() { _ = session.Close() }()
+	sseSession, err := client.Connect(ctx, &mcp.SSEClientTransport{Endpoint: "http://localhost/sse", HTTPClient: unixHTTPClient(socket)}, nil)
+	require.NoError(t, err)
+	defer func() { _ = sseSession.Close() }()
+
+	call := callInBackground(ctx, session, map[string]any{"duration": "500ms"})
+	start := time.Now()
+	require.NoError(t, stop())
+	assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond, "The shutdown should wait for the in-flight tool call")
+
+	completed := <-call
+	require.NoError(t, completed.err)
+	assert.Equal(t, "waited 500ms", toolResultTexts(completed.result), "The in-flight tool call should complete")
+	sseClosed := make(chan error, 1)
+	go func() { sseClosed <- sseSession.Wait() }()
+	select {
+	case <-sseClosed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The SSE session should be closed by the shutdown")
+	}
+}
+
+func TestServeGracefulShutdownGracePeriodExpired(t *testing.T) {
+	ctx := context.Background()
+	socket := filepath.Join(t.TempDir(), "mcp.sock")
+	extensions := config.Default()
+	extensions.Listen = config.ListenConfig{Address: "unix://" + socket, SocketMode: "0600"}
+	extensions.Shutdown.GracePeriod = "200ms"
+	stop := serveUnix(t, extensions, "test-wait")
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "1.0.0"}, nil)
+	session, err := client.Connect(ctx, &mcp.StreamableClientTransport{Endpoint: "http://localhost/mcp", HTTPClient: unixHTTPClient(socket)}, nil)
+	require.NoError(t, err)
+	defer func() { _ = session.Close() }()
+
+	call := callInBackground(ctx, session, map[string]any{"duration": "1m"})
+	start := time.Now()
+	require.NoError(t, stop())
+	assert.Less(t, time.Since(start), 5*time.Second, "The shutdown should not wait longer than the grace period")
+	completed := <-call
+	assert.True(t, completed.err != nil || completed.result.IsError, "The tool call should be interrupted after the grace period")
+}
+
+func TestServeListenersGracePeriod(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(impersonationKubeconfig), 0600))
+	staticConfig := staticconfig.Default()
+	staticConfig.KubeConfig = kubeconfigPath
+	staticConfig.Toolsets = []string{"test-greeting", "test-wait"}
+	server, err := localmcp.NewServer(localmcp.Configuration{StaticConfig: staticConfig})
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+	listener := func(name, gracePeriod string) localhttp.Listener {
+		extensions := config.Default()
+		extensions.Listen = config.ListenConfig{Address: "unix://" + filepath.Join(t.TempDir(), name+".sock"), SocketMode: "0600"}
+		extensions.Shutdown.GracePeriod = gracePeriod
+		return localhttp.Listener{Name: name, Server: server, StaticConfig: staticConfig, Extensions: extensions}
+	}
+	short, long := listener("short", "100ms"), listener("long", "5s")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- localhttp.ServeListeners(ctx, nil, nil, short, long) }()
+	socket := long.Extensions.Listen.SocketPath()
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("unix", socket)
+		if err == nil {
+			_ = conn.Close()
+		}
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond, "The listeners should listen on their sockets")
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "1.0.0"}, nil)
+	session, err := client.Connect(ctx, &mcp.StreamableClientTransport{Endpoint: "http://localhost/mcp", HTTPClient: unixHTTPClient(socket)}, nil)
+	require.NoError(t, err)
+	defer func() { _ = session.Close() }()
+
+	call := callInBackground(context.Background(), session, map[string]any{"duration": "500ms"})
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("The listeners should shut down")
+	}
+	completed := <-call
+	require.NoError(t, completed.err)
+	assert.Equal(t, "waited 500ms", toolResultTexts(completed.result), "The longest grace period of the listeners should apply")
+}
+
+func TestShutdownConfigValidation(t *testing.T) {
+	_, err := runCommand(t, "config", "validate", "--shutdown-grace-period", "1m30s")
+	assert.NoError(t, err)
+	_, err = runCommand(t, "config", "validate", "--shutdown-grace-period", "soon")
+	assert.ErrorContains(t, err, `invalid shutdown grace_period "soon"`)
+	_, err = runCommand(t, "config", "validate", "--shutdown-grace-period", "-1s")
+	assert.ErrorContains(t, err, "invalid shutdown grace_period")
+}
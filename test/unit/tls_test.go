@@ -0,0 +1,92 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the TLS serving configuration and certificate reloading.
+package unit
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	localhttp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/http"
+)
+
+// writeTestCertificate writes a self-signed certificate and key with the provided serial number.
+func writeTestCertificate(t *testing.T, certFile, keyFile string, serial int64) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+}
+
+func servedSerial(t *testing.T, reloader *localhttp.CertificateReloader) int64 {
+	cert, err := reloader.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.SerialNumber.Int64()
+}
+
+func TestCertificateReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeTestCertificate(t, certFile, keyFile, 1)
+
+	reloader, err := localhttp.NewCertificateReloader(certFile, keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), servedSerial(t, reloader))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, reloader.Watch(ctx))
+
+	writeTestCertificate(t, certFile, keyFile, 2)
+	assert.Eventually(t, func() bool { return servedSerial(t, reloader) == 2 }, 5*time.Second, 50*time.Millisecond,
+		"Certificate should be reloaded when the files change")
+
+	require.NoError(t, os.WriteFile(certFile, []byte("invalid"), 0600))
+	assert.Error(t, reloader.Reload(), "Invalid certificates should not be loaded")
+	assert.Equal(t, int64(2), servedSerial(t, reloader), "Previous certificate should be kept if the files are invalid")
+}
+
+func TestNewCertificateReloaderMissingFiles(t *testing.T) {
+	_, err := localhttp.NewCertificateReloader("missing.crt", "missing.key")
+	assert.Error(t, err, "Missing certificate files should be rejected")
+}
+
+func TestTLSConfigValidation(t *testing.T) {
+	version, err := config.TLSVersion(config.Default().TLS.MinVersion)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), version, "Minimum TLS version should default to 1.2")
+
+	cfg := config.Default()
+	cfg.TLS.MinVersion = "1.1"
+	assert.Error(t, cfg.Validate(), "TLS versions below 1.2 should be rejected")
+
+	cfg = config.Default()
+	cfg.TLS.CertFile = "tls.crt"
+	assert.Error(t, cfg.Validate(), "TLS certificate without key should be rejected")
+}
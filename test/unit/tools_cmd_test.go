@@ -0,0 +1,47 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the tools list subcommand.
+package unit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolsListCommand(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(impersonationKubeconfig), 0600))
+
+	out, err := runCommand(t, "tools", "list", "--kubeconfig", kubeconfigPath, "--toolsets", "core", "--read-only", "--output", "json")
+	require.NoError(t, err)
+	var tools []*mcp.Tool
+	require.NoError(t, json.Unmarshal([]byte(out), &tools))
+	names := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+		assert.True(t, tool.Annotations.ReadOnlyHint, "Only read-only tools should be listed for read-only servers")
+		assert.NotNil(t, tool.InputSchema, "The input schemas should be listed")
+	}
+	assert.Contains(t, names, "pods_list")
+	assert.NotContains(t, names, "pods_delete", "Only read-only tools should be listed for read-only servers")
+	assert.NotContains(t, names, "helm_list", "Only the tools of the enabled toolsets should be listed")
+
+	out, err = runCommand(t, "tools", "list", "--kubeconfig", kubeconfigPath, "--toolsets", "core", "--disable-destructive")
+	require.NoError(t, err)
+	assert.Regexp(t, `(?m)^NAME\s+ANNOTATIONS\s+ARGUMENTS$`, out)
+	assert.Regexp(t, `(?m)^pods_get\s+read-only,open-world\s+name\*,context,namespace$`, out, "Required arguments should be marked")
+	assert.NotContains(t, out, "pods_delete", "Destructive tools should not be listed if disabled")
+
+	out, err = runCommand(t, "tools", "list", "--kubeconfig", kubeconfigPath, "--toolsets", "helm", "-o", "markdown")
+	require.NoError(t, err)
+	assert.Contains(t, out, "## helm_install")
+	assert.Contains(t, out, "| `chart` | string | true |")
+
+	_, err = runCommand(t, "tools", "list", "--kubeconfig", kubeconfigPath, "-o", "xml")
+	assert.ErrorContains(t, err, "invalid output format")
+}
@@ -0,0 +1,143 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the per-toolset configuration sections.
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	k8sapi "github.com/containers/kubernetes-mcp-server/pkg/api"
+	staticconfig "github.com/containers/kubernetes-mcp-server/pkg/config"
+	internalk8s "github.com/containers/kubernetes-mcp-server/pkg/kubernetes"
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/ptr"
+
+	localapi "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/api"
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	localmcp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/mcp"
+	localtoolsets "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/toolsets"
+)
+
+type greetingConfig struct {
+	Greeting string `toml:"greeting"`
+}
+
+func (c *greetingConfig) Validate() error {
+	if c.Greeting == "" {
+		return errors.New("greeting must not be empty")
+	}
+	return nil
+}
+
+// greetingToolset is a custom toolset receiving its configuration.
+type greetingToolset struct{}
+
+func (greetingToolset) GetName() string        { return "test-greeting" }
+func (greetingToolset) GetDescription() string { return "Configurable test toolset" }
+func (greetingToolset) GetTools(internalk8s.Openshift) []k8sapi.ServerTool {
+	return nil
+}
+func (greetingToolset) DefaultConfig() localapi.ToolsetConfig {
+	return &greetingConfig{Greeting: "hello"}
+}
+func (greetingToolset) GetConfiguredTools(_ internalk8s.Openshift, cfg localapi.ToolsetConfig) []k8sapi.ServerTool {
+	greeting := cfg.(*greetingConfig).Greeting
+	return []k8sapi.ServerTool{{
+		Tool: k8sapi.Tool{
+			Name:        "test_greet",
+			Description: "Greets",
+			InputSchema: &jsonschema.Schema{Type: "object"},
+			Annotations: k8sapi.ToolAnnotations{ReadOnlyHint: ptr.To(true)},
+		},
+		Handler: func(k8sapi.ToolHandlerParams) (*k8sapi.ToolCallResult, error) {
+			return k8sapi.NewToolCallResult(greeting, nil), nil
+		},
+		ClusterAware: ptr.To(false),
+	}}
+}
+
+func TestToolsetConfigDefaults(t *testing.T) {
+	cfg, err := config.Default().ToolsetConfig(localtoolsets.ToolsetFromString("core").(localapi.ConfigurableToolset))
+	require.NoError(t, err)
+	assert.Equal(t, &localtoolsets.CoreConfig{PodsLogTail: 100}, cfg, "Missing sections should use the defaults")
+
+	cfg, err = config.Default().ToolsetConfig(localtoolsets.ToolsetFromString("helm").(localapi.ConfigurableToolset))
+	require.NoError(t, err)
+	assert.Equal(t, &localtoolsets.HelmConfig{}, cfg, "Missing sections should use the defaults")
+}
+
+func TestToolsetConfigValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{"unknown toolset", "[toolset_configs.unknown]\nfoo = 1", "unknown toolset"},
+		{"toolset without settings", "[toolset_configs.config]\nfoo = 1", "the toolset has no settings"},
+		{"unknown setting", "[toolset_configs.core]\npods_log_tails = 10", "unknown settings pods_log_tails"},
+		{"invalid type", "[toolset_configs.core]\npods_log_tail = \"ten\"", "toolset_configs.core"},
+		{"invalid value", "[toolset_configs.core]\npods_log_tail = 200\npods_log_max_tail = 100", "must not exceed"},
+		{"custom toolset", "[toolset_configs.test-greeting]\ngreeting = \"\"", "greeting must not be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.ReadToml([]byte(tt.config))
+			require.NoError(t, err)
+			assert.ErrorContains(t, cfg.Validate(), tt.err)
+		})
+	}
+
+	valid := []byte("toolsets = [\"core\", \"helm\"]\n[toolset_configs.core]\npods_log_tail = 50\npods_log_max_tail = 500\n" +
+		"[toolset_configs.helm]\ndefault_repository = \"oci://ghcr.io/example/charts\"")
+	cfg, err := config.ReadToml(valid)
+	require.NoError(t, err)
+	assert.NoError(t, cfg.Validate(), "Valid toolset sections should be accepted")
+	_, err = staticconfig.ReadToml(valid)
+	assert.NoError(t, err, "Toolset sections should not conflict with the enabled toolsets")
+}
+
+func TestConfiguredToolsets(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(impersonationKubeconfig), 0600))
+	staticConfig := staticconfig.Default()
+	staticConfig.KubeConfig = kubeconfigPath
+	staticConfig.Toolsets = []string{"core", "test-greeting"}
+	extensions, err := config.ReadToml([]byte("[toolset_configs.core]\npods_log_tail = 50\npods_log_max_tail = 500\n[toolset_configs.test-greeting]\ngreeting = \"hi\""))
+	require.NoError(t, err)
+	server, err := localmcp.NewServer(localmcp.Configuration{StaticConfig: staticConfig, Extensions: extensions})
+	require.NoError(t, err)
+	defer server.Close()
+
+	ctx := context.Background()
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	_, err = server.Connect(ctx, serverTransport, nil)
+	require.NoError(t, err)
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "test"}, nil).Connect(ctx, clientTransport, nil)
+	require.NoError(t, err)
+	defer func() { _ = session.Close() }()
+
+	result, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "test_greet"})
+	require.NoError(t, err)
+	assert.Equal(t, "hi", result.Content[0].(*mcp.TextContent).Text, "Custom toolsets should be built with their configuration")
+
+	tools, err := session.ListTools(ctx, &mcp.ListToolsParams{})
+	require.NoError(t, err)
+	for _, tool := range tools.Tools {
+		if tool.Name != "pods_log" {
+			continue
+		}
+		schema, err := json.Marshal(tool.InputSchema)
+		require.NoError(t, err)
+		assert.Contains(t, string(schema), `"default":50`, "Built-in toolsets should be configured")
+		assert.Contains(t, string(schema), `"maximum":500`, "Built-in toolsets should be configured")
+		return
+	}
+	t.Fatal("pods_log tool should be registered")
+}
@@ -0,0 +1,33 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file registers the test toolsets shared by the tests.
+package unit
+
+import (
+	k8sapi "github.com/containers/kubernetes-mcp-server/pkg/api"
+	internalk8s "github.com/containers/kubernetes-mcp-server/pkg/kubernetes"
+	"github.com/containers/kubernetes-mcp-server/pkg/toolsets"
+)
+
+// testToolset is a test toolset serving the tools of its function.
+type testToolset struct {
+	name        string
+	description string
+	tools       func() []k8sapi.ServerTool
+}
+
+func (t testToolset) GetName() string        { return t.name }
+func (t testToolset) GetDescription() string { return t.description }
+func (t testToolset) GetTools(internalk8s.Openshift) []k8sapi.ServerTool {
+	return t.tools()
+}
+
+func init() {
+	for _, toolset := range []k8sapi.Toolset{
+		greetingToolset{},
+		testToolset{name: "test-echo", description: "Echoing test toolset", tools: echoTools},
+		testToolset{name: "test-wait", description: "Waiting test toolset", tools: waitTools},
+		testToolset{name: "test-clusters", description: "Cluster aware test toolset", tools: clusterTools},
+	} {
+		toolsets.Register(toolset)
+	}
+}
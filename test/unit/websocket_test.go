@@ -0,0 +1,118 @@
+// Package unit contains unit tests for the extendable Kubernetes MCP server.
+// This file tests the WebSocket transport.
+package unit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	staticconfig "github.com/containers/kubernetes-mcp-server/pkg/config"
+	"github.com/gorilla/websocket"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/config"
+	localhttp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/http"
+	localmcp "github.com/friedrichwilken/extendable-kubernetes-mcp-server/pkg/mcp"
+)
+
+func TestServeWebSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "mcp.sock")
+	extensions := config.Default()
+	extensions.Listen = config.ListenConfig{Address: "unix://" + socket, SocketMode: "0600"}
+	extensions.Authentication.APIKeys = []config.APIKeyConfig{{Name: "ci", Hash: apiKeyHash("secret")}}
+	stop := serveUnix(t, extensions)
+	defer func() { _ = stop() }()
+	dialer := &websocket.Dialer{
+		NetDialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+		Subprotocols: []string{localhttp.WebSocketSubprotocol},
+	}
+	ctx := context.Background()
+
+	_, resp, err := dialer.DialContext(ctx, "ws://localhost/ws", nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "The upgrade requests should be authenticated")
+
+	conn, resp, err := dialer.DialContext(ctx, "ws://localhost/ws", http.Header{"Authorization": {"Bearer secret"}})
+	require.NoError(t, err)
+	assert.Equal(t, localhttp.WebSocketSubprotocol, resp.Header.Get("Sec-Websocket-Protocol"))
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "1.0.0"}, nil)
+	session, err := client.Connect(ctx, &localhttp.WebSocketTransport{Conn: conn}, nil)
+	require.NoError(t, err)
+	defer func() { _ = session.Close() }()
+
+	tools, err := session.ListTools(ctx, &mcp.ListToolsParams{})
+	require.NoError(t, err)
+	names := make([]string, 0, len(tools.Tools))
+	for _, tool := range tools.Tools {
+		names = append(names, tool.Name)
+	}
+	assert.Contains(t, names, "test_greet")
+	result, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "test_greet"})
+	require.NoError(t, err)
+	assert.False(t, result.IsError)
+	assert.Equal(t, "hello", toolResultTexts(result))
+
+	large, _, err := dialer.DialContext(ctx, "ws://localhost/ws", http.Header{"Authorization": {"Bearer secret"}})
+	require.NoError(t, err)
+	defer func() { _ = large.Close() }()
+	err = large.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"ping","params":{"_meta":{"padding":"`+
+		strings.Repeat("x", 5<<20)+`"}}}`))
+	if err == nil {
+		_, _, err = large.ReadMessage()
+	}
+	assert.Error(t, err, "The messages over the size limit should close the connection")
+
+	req, err := http.NewRequest(http.MethodPost, "http://localhost/mcp", strings.NewReader(strings.Repeat("x", 5<<20)))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = unixHTTPClient(socket).Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode, "The request bodies over the size limit should be rejected")
+}
+
+func TestInspectCommandWebSocket(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(impersonationKubeconfig), 0600))
+	staticConfig := staticconfig.Default()
+	staticConfig.KubeConfig = kubeconfigPath
+	staticConfig.Toolsets = []string{"test-greeting"}
+	server, err := localmcp.NewServer(localmcp.Configuration{StaticConfig: staticConfig})
+	require.NoError(t, err)
+	defer server.Close()
+	mux := http.NewServeMux()
+	mux.Handle("/ws", localhttp.NewWebSocketHandler(server))
+	httpServer := httptest.NewServer(mux)
+	defer httpServer.Close()
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/ws"
+
+	out, err := runCommandWithInput(t, "call test_greet\nexit\n", "inspect", "--url", url, "--raw")
+	require.NoError(t, err)
+	assert.Contains(t, out, "hello", "Tools should be called over the WebSocket transport")
+	assert.Contains(t, out, `--> {"jsonrpc":"2.0","id":1,"method":"initialize"`)
+
+	_, err = runCommandWithInput(t, "exit\n", "inspect", "--url", strings.TrimSuffix(url, "/ws")+"/missing")
+	assert.ErrorContains(t, err, "failed to connect to the MCP server")
+}
+
+func toolResultTexts(result *mcp.CallToolResult) string {
+	texts := make([]string, 0, len(result.Content))
+	for _, content := range result.Content {
+		if text, ok := content.(*mcp.TextContent); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}